@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"go-hex-forum/config"
+	"go-hex-forum/internal/adapters/postgres"
+	"go-hex-forum/internal/adapters/postgres/migrations"
+	apiserver "go-hex-forum/internal/app"
+	"go-hex-forum/internal/core/service"
+	"go-hex-forum/pkg/passhash"
+)
+
+func runCommand(ctx context.Context, args []string, cfg *config.Config, db *sql.DB, logger *slog.Logger) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(ctx, args[1:], db, logger)
+	case "archive":
+		return runArchive(ctx, cfg, db, logger)
+	case "images":
+		return runImages(ctx, args[1:], cfg, db, logger)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runMigrate implements `migrate up|down [-steps N]|status`.
+func runMigrate(ctx context.Context, args []string, db *sql.DB, logger *slog.Logger) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down|status")
+	}
+
+	migrator, err := migrations.NewMigrator(db, logger)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("migrations up finished", "applied", applied)
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return fmt.Errorf("steps must be positive, got %d", *steps)
+		}
+		reverted, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			return err
+		}
+		logger.Info("migrations down finished", "reverted", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d  %-32s %s\n", st.Version, st.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate action %q, expected up|down|status", args[0])
+	}
+
+	return nil
+}
+
+// runArchive performs a single archive pass immediately.
+func runArchive(ctx context.Context, cfg *config.Config, db *sql.DB, logger *slog.Logger) error {
+	postService := service.NewPostService(postgres.NewPostRepository(db), nil, apiserver.NewLifetimePolicy(cfg.PostConfig), apiserver.NewDeletionPolicy(cfg.PostConfig), apiserver.NewEditPolicy(cfg.PostConfig), time.Now)
+	worker := service.NewArchiveWorker(postService, cfg.PostConfig.ArchiveInterval, logger)
+
+	_, err := worker.RunOnce(ctx)
+	return err
+}
+
+// runImages implements `images gc [-dry-run]`.
+func runImages(ctx context.Context, args []string, cfg *config.Config, db *sql.DB, logger *slog.Logger) error {
+	if len(args) == 0 || args[0] != "gc" {
+		return fmt.Errorf("usage: images gc [-dry-run]")
+	}
+
+	fs := flag.NewFlagSet("images gc", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report what would be removed")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	imageStorage, _, err := apiserver.NewImageStorage(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	sweeper := service.NewImageSweeper(postgres.NewImageRepository(db), imageStorage, cfg.Images.GCGrace, cfg.Images.GCInterval, time.Now, logger)
+
+	result, err := sweeper.RunOnce(ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "removed"
+	if result.DryRun {
+		verb = "would remove"
+	}
+	fmt.Fprintf(os.Stdout, "%s %d image records and %d objects (%d bytes)\n", verb, result.Records, result.Objects, result.Bytes)
+
+	return nil
+}
+
+// runHashPassword reads a password from the first line of stdin and prints its hash for ADMIN_MODERATORS.
+func runHashPassword(in io.Reader) error {
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return fmt.Errorf("usage: echo <password> | hash-password")
+	}
+
+	hash, err := passhash.Hash(password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, hash)
+	return nil
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"os"
 
 	"go-hex-forum/config"
+	"go-hex-forum/internal/adapters/postgres/migrations"
 	apiserver "go-hex-forum/internal/app"
 	"go-hex-forum/pkg/lib/prettyslog"
 
@@ -12,23 +14,65 @@ import (
 )
 
 func main() {
+	if !run() {
+		os.Exit(1)
+	}
+}
+
+// run does the work of main and reports whether it succeeded. Exiting only after it returned lets the
+// database be closed first, while scripts still see failed commands and migrations in the exit status.
+func run() bool {
 	// Load the configuration
 	cfg := config.NewConfig()
 
 	// Initialize the logger
 	logger := prettyslog.SetupPrettySlog(os.Stdout)
 
+	// hash-password works offline, before there is a database to connect to
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		if err := runHashPassword(os.Stdin); err != nil {
+			logger.Error("Command failed", "command", os.Args[1], "error", err.Error())
+			return false
+		}
+		return true
+	}
+
 	// Initialize the database object and ping the database
 	db, err := initDB(cfg)
 	if err != nil {
 		logger.Error("Failed to connect to the database", "error", err.Error())
-		return
+		return false
 	}
 	defer db.Close()
 
+	// Subcommands (e.g. `myapp migrate up`) run and exit instead of starting the server
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), os.Args[1:], cfg, db, logger); err != nil {
+			logger.Error("Command failed", "command", os.Args[1], "error", err.Error())
+			return false
+		}
+		return true
+	}
+
+	if cfg.DataBase.MigrateOnStart {
+		migrator, err := migrations.NewMigrator(db, logger)
+		if err != nil {
+			logger.Error("Failed to load migrations", "error", err.Error())
+			return false
+		}
+		if _, err := migrator.Up(context.Background()); err != nil {
+			logger.Error("Failed to apply migrations", "error", err.Error())
+			return false
+		}
+	}
+
 	// Defining new REST API server
 	server := apiserver.NewAPIServer(cfg, db, logger)
-	server.Run()
+	if err := server.Run(); err != nil {
+		logger.Error("Server stopped with error", "error", err.Error())
+		return false
+	}
+	return true
 }
 
 func initDB(cfg *config.Config) (*sql.DB, error) {
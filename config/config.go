@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,55 +14,208 @@ type (
 		Server        Server
 		DataBase      DataBase
 		SessionConfig SessionConfig
+		PostConfig    PostConfig
 		Storage       Storage
+		Images        Images
+		Admin         Admin
+		RateLimit     RateLimit
+		Challenge     Challenge
 	}
 
 	Server struct {
-		Address string
-		Port    string
+		Address           string
+		Port              string
+		ReadTimeout       time.Duration
+		ReadHeaderTimeout time.Duration
+		WriteTimeout      time.Duration
+		IdleTimeout       time.Duration
+		RequestTimeout    time.Duration
+		ShutdownTimeout   time.Duration
 	}
 
 	DataBase struct {
-		DBUser     string
-		DBPassword string
-		DBHost     string
-		DBPort     string
-		DBName     string
+		DBUser         string
+		DBPassword     string
+		DBHost         string
+		DBPort         string
+		DBName         string
+		MigrateOnStart bool
 	}
 
 	SessionConfig struct {
 		DefaultTTL    time.Duration
 		MaxNameLength int64
+		// RotateInterval is how often a used session gets a new token, zero disables rotation
+		RotateInterval time.Duration
+		// RotateGrace is how long the replaced token keeps working, so requests already in flight are not rejected
+		RotateGrace time.Duration
+		// CleanupInterval is how often expired sessions and their users are deleted, CleanupBatchSize how many rows per statement
+		CleanupInterval  time.Duration
+		CleanupBatchSize int
+	}
+
+	PostConfig struct {
+		NoCommentsTTL   time.Duration
+		CommentTTL      time.Duration
+		MaxLifetime     time.Duration
+		BumpLimit       int64
+		ArchiveInterval time.Duration
+		// DeleteWindow is how long authors may delete their posts and comments, zero leaves deleting to staff
+		DeleteWindow time.Duration
+		// EditWindow is how long authors may edit their posts and comments, zero turns editing off
+		EditWindow time.Duration
 	}
 
 	Storage struct {
-		Host          string
-		Port          string
-		MaxNameLength int64
+		// Driver selects the image backend: "http" bucket server or "local" filesystem
+		Driver   string
+		Host     string
+		Port     string
+		LocalDir string
+	}
+
+	Images struct {
+		MaxBytes     int64
+		MaxWidth     int64
+		MaxHeight    int64
+		AllowedTypes []string
+		// ThumbnailSize and PreviewSize are the longest sides of the downscaled variants, zero disables a variant
+		ThumbnailSize int64
+		PreviewSize   int64
+		// GCGrace is how long an unreferenced image is kept, GCInterval how often the sweeper runs
+		GCGrace    time.Duration
+		GCInterval time.Duration
+		// MaxAttachments is how many images a post or comment may carry
+		MaxAttachments int
+		// MaxDecodeMemory is how much memory decoding and resizing an upload may take
+		MaxDecodeMemory int64
+	}
+
+	Admin struct {
+		// Token authenticates operational endpoints, an empty token disables them
+		Token string
+		// Moderators are the staff accounts as "login:role:hash" entries, role being moderator or admin and
+		// hash made by the hash-password command
+		Moderators []string
+		// SessionTTL is how long staff stay signed in to the admin panel
+		SessionTTL time.Duration
+	}
+
+	RateLimit struct {
+		// Driver selects where buckets are kept: "memory" for a single instance or "postgres" to share them between instances
+		Driver string
+		// TrustProxy takes the client address from X-Forwarded-For, only enable it behind a reverse proxy setting the header
+		TrustProxy bool
+		Posts      RateRule
+		Comments   RateRule
+		Uploads    RateRule
+		Sessions   RateRule
+		Logins     RateRule
+		Reports    RateRule
+		// Strikes remembers up to Burst refused requests and forgives them over Window, challenges get harder with every strike
+		Strikes RateRule
+	}
+
+	Challenge struct {
+		// Kind selects what forms have to solve: "pow" for a proof of work, "captcha" for an image captcha or "none"
+		Kind string
+		// TTL is how long an issued challenge can be answered
+		TTL time.Duration
+		// PowBits is how many leading zero bits a proof of work needs, PowStepBits what every strike adds
+		PowBits     int
+		PowStepBits int
+		// CaptchaLength is how many digits a captcha shows, every strike adds one
+		CaptchaLength int
+		// MaxEscalation caps how many strikes make a challenge harder
+		MaxEscalation int
+	}
+
+	// RateRule allows Burst actions at once, refilled completely over Window. A zero Burst disables the limit.
+	RateRule struct {
+		Burst  int
+		Window time.Duration
 	}
 )
 
 func NewConfig() *Config {
 	return &Config{
 		Server{
-			Address: getEnvStr("ADDRESS", ""),
-			Port:    getEnvStr("PORT", "8080"),
+			Address:           getEnvStr("ADDRESS", ""),
+			Port:              getEnvStr("PORT", "8080"),
+			ReadTimeout:       time.Duration(getEnvInt64("SERVER_READ_TIMEOUT", 30)) * time.Second,
+			ReadHeaderTimeout: time.Duration(getEnvInt64("SERVER_READ_HEADER_TIMEOUT", 5)) * time.Second,
+			WriteTimeout:      time.Duration(getEnvInt64("SERVER_WRITE_TIMEOUT", 30)) * time.Second,
+			IdleTimeout:       time.Duration(getEnvInt64("SERVER_IDLE_TIMEOUT", 120)) * time.Second,
+			RequestTimeout:    time.Duration(getEnvInt64("SERVER_REQUEST_TIMEOUT", 15)) * time.Second,
+			ShutdownTimeout:   time.Duration(getEnvInt64("SERVER_SHUTDOWN_TIMEOUT", 20)) * time.Second,
 		},
 		DataBase{
-			DBUser:     getEnvStr("DB_USER", "hacker"),
-			DBPassword: getEnvStr("DB_PASSWORD", "0000"),
-			DBHost:     getEnvStr("DB_HOST", "localhost"),
-			DBPort:     getEnvStr("DB_PORT", "5432"),
-			DBName:     getEnvStr("DB_NAME", "forum"),
+			DBUser:         getEnvStr("DB_USER", "hacker"),
+			DBPassword:     getEnvStr("DB_PASSWORD", "0000"),
+			DBHost:         getEnvStr("DB_HOST", "localhost"),
+			DBPort:         getEnvStr("DB_PORT", "5432"),
+			DBName:         getEnvStr("DB_NAME", "forum"),
+			MigrateOnStart: getEnvBool("DB_MIGRATE_ON_START", false),
 		},
 		SessionConfig{
-			DefaultTTL:    time.Duration(getEnvInt64("SESSION_TTL", 7*24*60*60)) * time.Second,
-			MaxNameLength: getEnvInt64("SESSION_TOKEN_LENGTH", 10),
+			DefaultTTL:       time.Duration(getEnvInt64("SESSION_TTL", 7*24*60*60)) * time.Second,
+			MaxNameLength:    getEnvInt64("SESSION_TOKEN_LENGTH", 10),
+			RotateInterval:   time.Duration(getEnvInt64("SESSION_ROTATE_INTERVAL", 60*60)) * time.Second,
+			RotateGrace:      time.Duration(getEnvInt64("SESSION_ROTATE_GRACE", 60)) * time.Second,
+			CleanupInterval:  time.Duration(getEnvInt64("SESSION_CLEANUP_INTERVAL", 60*60)) * time.Second,
+			CleanupBatchSize: int(getEnvInt64("SESSION_CLEANUP_BATCH_SIZE", 1000)),
+		},
+		PostConfig{
+			NoCommentsTTL:   time.Duration(getEnvInt64("POST_TTL", 10*60)) * time.Second,
+			CommentTTL:      time.Duration(getEnvInt64("POST_COMMENT_TTL", 15*60)) * time.Second,
+			MaxLifetime:     time.Duration(getEnvInt64("POST_MAX_LIFETIME", 0)) * time.Second,
+			BumpLimit:       getEnvInt64("POST_BUMP_LIMIT", 0),
+			ArchiveInterval: time.Duration(getEnvInt64("POST_ARCHIVE_INTERVAL", 60)) * time.Second,
+			DeleteWindow:    time.Duration(getEnvInt64("POST_DELETE_WINDOW", 15*60)) * time.Second,
+			EditWindow:      time.Duration(getEnvInt64("POST_EDIT_WINDOW", 15*60)) * time.Second,
 		},
 		Storage{
-			Host:          getEnvStr("STORAGE_HOST", "http://localhost"),
-			Port:          getEnvStr("STORAGE_PORT", "6969"),
-			MaxNameLength: getEnvInt64("STORAGE_CODE_LENGTH", 6),
+			Driver:   getEnvStr("STORAGE_DRIVER", "http"),
+			Host:     getEnvStr("STORAGE_HOST", "http://localhost"),
+			Port:     getEnvStr("STORAGE_PORT", "6969"),
+			LocalDir: getEnvStr("STORAGE_LOCAL_DIR", filepath.Join("data", "media")),
+		},
+		Images{
+			MaxBytes:       getEnvInt64("IMAGE_MAX_BYTES", 5<<20),
+			MaxWidth:       getEnvInt64("IMAGE_MAX_WIDTH", 4096),
+			MaxHeight:      getEnvInt64("IMAGE_MAX_HEIGHT", 4096),
+			AllowedTypes:   getEnvList("IMAGE_ALLOWED_TYPES", []string{"image/jpeg", "image/png", "image/gif", "image/webp"}),
+			ThumbnailSize:  getEnvInt64("IMAGE_THUMBNAIL_SIZE", 240),
+			PreviewSize:    getEnvInt64("IMAGE_PREVIEW_SIZE", 720),
+			GCGrace:        time.Duration(getEnvInt64("IMAGE_GC_GRACE", 24*60*60)) * time.Second,
+			GCInterval:     time.Duration(getEnvInt64("IMAGE_GC_INTERVAL", 60*60)) * time.Second,
+			MaxAttachments: int(getEnvInt64("IMAGE_MAX_ATTACHMENTS", 4)),
+			// 4096x4096 pixels at 16 bytes each: a 16-bit PNG plus the working copies
+			MaxDecodeMemory: getEnvInt64("IMAGE_MAX_DECODE_BYTES", 256<<20),
+		},
+		Admin{
+			Token:      getEnvStr("ADMIN_TOKEN", ""),
+			Moderators: getEnvList("ADMIN_MODERATORS", nil),
+			SessionTTL: time.Duration(getEnvInt64("ADMIN_SESSION_TTL", 8*60*60)) * time.Second,
+		},
+		RateLimit{
+			Driver:     getEnvStr("RATE_LIMIT_DRIVER", "memory"),
+			TrustProxy: getEnvBool("RATE_LIMIT_TRUST_PROXY", false),
+			Posts:      getEnvRateRule("RATE_LIMIT_POSTS", 5, 10*60),
+			Comments:   getEnvRateRule("RATE_LIMIT_COMMENTS", 20, 10*60),
+			Uploads:    getEnvRateRule("RATE_LIMIT_UPLOADS", 30, 10*60),
+			Sessions:   getEnvRateRule("RATE_LIMIT_SESSIONS", 10, 60*60),
+			Logins:     getEnvRateRule("RATE_LIMIT_LOGINS", 5, 15*60),
+			Reports:    getEnvRateRule("RATE_LIMIT_REPORTS", 10, 60*60),
+			Strikes:    getEnvRateRule("RATE_LIMIT_STRIKES", 10, 60*60),
+		},
+		Challenge{
+			Kind:          getEnvStr("CHALLENGE_KIND", "pow"),
+			TTL:           time.Duration(getEnvInt64("CHALLENGE_TTL", 15*60)) * time.Second,
+			PowBits:       int(getEnvInt64("CHALLENGE_POW_BITS", 16)),
+			PowStepBits:   int(getEnvInt64("CHALLENGE_POW_STEP_BITS", 2)),
+			CaptchaLength: int(getEnvInt64("CHALLENGE_CAPTCHA_LENGTH", 5)),
+			MaxEscalation: int(getEnvInt64("CHALLENGE_MAX_ESCALATION", 4)),
 		},
 	}
 }
@@ -99,3 +254,39 @@ func getEnvInt64(key string, fallback int64) int64 {
 
 	return fallback
 }
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fallback
+		}
+
+		return b
+	}
+
+	return fallback
+}
+
+func getEnvList(key string, fallback []string) []string {
+	if value, ok := os.LookupEnv(key); ok {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+
+		return list
+	}
+
+	return fallback
+}
+
+// getEnvRateRule reads <prefix>_BURST and <prefix>_WINDOW, the window in seconds.
+func getEnvRateRule(prefix string, burst, window int64) RateRule {
+	return RateRule{
+		Burst:  int(getEnvInt64(prefix+"_BURST", burst)),
+		Window: time.Duration(getEnvInt64(prefix+"_WINDOW", window)) * time.Second,
+	}
+}
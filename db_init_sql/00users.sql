@@ -1,5 +0,0 @@
-CREATE TABLE users(
-    id SERIAL PRIMARY KEY,
-    name TEXT NOT NULL,
-    avatar_url TEXT NOT NULL
-);
\ No newline at end of file
@@ -1,10 +0,0 @@
-CREATE TABLE posts (
-    id SERIAL PRIMARY KEY,
-    user_id INTEGER REFERENCES users(id),
-    title TEXT NOT NULL,
-    content TEXT NOT NULL,
-    image_path TEXT,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    expires_at TIMESTAMPTZ NOT NULL,
-    is_archived BOOLEAN NOT NULL DEFAULT false
-);
\ No newline at end of file
@@ -1,8 +0,0 @@
-CREATE TABLE comments (
-    id SERIAL PRIMARY KEY,
-    post_id INTEGER REFERENCES posts(id),
-    user_id INTEGER REFERENCES users(id),
-    content TEXT NOT NULL,
-    image_path TEXT,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-);
\ No newline at end of file
@@ -2,4 +2,6 @@ module go-hex-forum
 
 go 1.24.1
 
-require github.com/lib/pq v1.10.9 // indirect
+require github.com/lib/pq v1.10.9
+
+require golang.org/x/image v0.36.0
@@ -0,0 +1,38 @@
+package challenge
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestCaptcha(t *testing.T) {
+	captcha := NewCaptcha(5)
+
+	challenge, err := captcha.challenge("04217")
+	if err != nil {
+		t.Fatalf("challenge: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(challenge.Image))
+	if err != nil {
+		t.Fatalf("expected a png, got %v", err)
+	}
+	if img.Bounds().Dx() != 2*captchaPadding+5*captchaAdvance {
+		t.Fatalf("unexpected width %d", img.Bounds().Dx())
+	}
+	if challenge.Answer == "" || bytes.Contains([]byte(challenge.Answer), []byte("04217")) {
+		t.Fatalf("expected the answer to be stored hashed, got %q", challenge.Answer)
+	}
+
+	if !captcha.Verify(challenge, "042 17") {
+		t.Fatalf("expected the digits to be accepted with spaces")
+	}
+	if captcha.Verify(challenge, "04218") {
+		t.Fatalf("expected wrong digits to be rejected")
+	}
+
+	escalated, err := captcha.Issue(2)
+	if err != nil || escalated.Difficulty != 7 {
+		t.Fatalf("expected 7 digits for two strikes, got %d (%v)", escalated.Difficulty, err)
+	}
+}
@@ -0,0 +1,63 @@
+package challenge
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+const (
+	// maxPowBits keeps escalated proofs of work solvable in a browser within a minute or so.
+	maxPowBits = 26
+	// maxNonceLength bounds the answers hashed, a solution is a decimal counter.
+	maxNonceLength = 32
+)
+
+// ProofOfWork is a hashcash-style puzzle: the client searches for a nonce such that SHA-256 of seed and nonce
+// starts with Difficulty zero bits. Solving takes about 2^Difficulty hashes, checking takes one.
+type ProofOfWork struct {
+	bits     int
+	stepBits int
+}
+
+func NewProofOfWork(bits, stepBits int) *ProofOfWork {
+	return &ProofOfWork{bits: bits, stepBits: stepBits}
+}
+
+func (p *ProofOfWork) Kind() domain.ChallengeKind {
+	return domain.ChallengeProofOfWork
+}
+
+func (p *ProofOfWork) Issue(level int) (domain.Challenge, error) {
+	seed := make([]byte, 16)
+	if _, err := rand.Read(seed); err != nil {
+		return domain.Challenge{}, err
+	}
+	return domain.Challenge{
+		Kind:       domain.ChallengeProofOfWork,
+		Difficulty: min(max(p.bits+level*p.stepBits, 1), maxPowBits),
+		Seed:       hex.EncodeToString(seed),
+	}, nil
+}
+
+func (p *ProofOfWork) Verify(challenge domain.Challenge, answer string) bool {
+	if len(answer) > maxNonceLength {
+		return false
+	}
+	sum := sha256.Sum256([]byte(challenge.Seed + answer))
+	return leadingZeroBits(sum[:]) >= challenge.Difficulty
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,51 @@
+package challenge
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestProofOfWork(t *testing.T) {
+	pow := NewProofOfWork(8, 2)
+
+	challenge, err := pow.Issue(1)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if challenge.Difficulty != 10 || challenge.Seed == "" {
+		t.Fatalf("expected 10 bits for one strike with a seed, got %+v", challenge)
+	}
+
+	solution := ""
+	for nonce := 0; solution == ""; nonce++ {
+		if pow.Verify(challenge, strconv.Itoa(nonce)) {
+			solution = strconv.Itoa(nonce)
+		}
+	}
+
+	// the same nonce hardly ever satisfies a harder puzzle with another seed
+	harder := challenge
+	harder.Difficulty = 24
+	if pow.Verify(harder, solution) {
+		t.Fatalf("expected %q not to solve 24 bits", solution)
+	}
+
+	if challenge, _ := NewProofOfWork(20, 4).Issue(10); challenge.Difficulty != maxPowBits {
+		t.Fatalf("expected escalation to be capped at %d bits, got %d", maxPowBits, challenge.Difficulty)
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	cases := map[int][]byte{
+		0:  {0x80},
+		7:  {0x01},
+		8:  {0x00, 0xff},
+		12: {0x00, 0x0f},
+		16: {0x00, 0x00},
+	}
+	for want, b := range cases {
+		if got := leadingZeroBits(b); got != want {
+			t.Fatalf("%x: expected %d, got %d", b, want, got)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+// RateLimitStore keeps token buckets in process memory. It is only correct for a single instance,
+// deployments running several instances share their buckets through Postgres instead.
+type RateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]domain.Bucket
+}
+
+func NewRateLimitStore() *RateLimitStore {
+	return &RateLimitStore{buckets: make(map[string]domain.Bucket)}
+}
+
+func (s *RateLimitStore) Take(_ context.Context, keys []string, limit domain.RateLimit, cost int, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets := make([]domain.Bucket, len(keys))
+	for i, key := range keys {
+		buckets[i] = s.buckets[key]
+	}
+	buckets, retryAfter := limit.TakeAll(buckets, cost, now)
+	if retryAfter > 0 {
+		return retryAfter, nil
+	}
+	for i, key := range keys {
+		s.buckets[key] = buckets[i]
+	}
+	return 0, nil
+}
+
+func (s *RateLimitStore) Peek(_ context.Context, key string) (domain.Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buckets[key], nil
+}
+
+func (s *RateLimitStore) Prune(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pruned int64
+	for key, bucket := range s.buckets {
+		if bucket.UpdatedAt.Before(before) {
+			delete(s.buckets, key)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
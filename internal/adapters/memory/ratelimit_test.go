@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+func TestRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	limit := domain.RateLimit{Burst: 1, Window: time.Minute}
+	store := NewRateLimitStore()
+
+	if retry, _ := store.Take(ctx, []string{"post:ip:1.2.3.4"}, limit, 1, now); retry != 0 {
+		t.Fatalf("expected the first take to pass, got retry %v", retry)
+	}
+	if retry, _ := store.Take(ctx, []string{"post:ip:1.2.3.4"}, limit, 1, now); retry != time.Minute {
+		t.Fatalf("expected the second take to wait a minute, got %v", retry)
+	}
+	if retry, _ := store.Take(ctx, []string{"post:ip:5.6.7.8"}, limit, 1, now); retry != 0 {
+		t.Fatalf("expected keys to have their own buckets, got retry %v", retry)
+	}
+
+	// nothing is taken unless every bucket has a token left
+	if retry, _ := store.Take(ctx, []string{"post:ip:9.9.9.9", "post:ip:1.2.3.4"}, limit, 1, now); retry != time.Minute {
+		t.Fatalf("expected the empty bucket to refuse, got retry %v", retry)
+	}
+	if bucket, _ := store.Peek(ctx, "post:ip:9.9.9.9"); !bucket.UpdatedAt.IsZero() {
+		t.Fatalf("expected the refused take to leave the other bucket alone, got %+v", bucket)
+	}
+
+	store.Take(ctx, []string{"post:ip:9.9.9.9"}, limit, 1, now.Add(time.Hour))
+	pruned, _ := store.Prune(ctx, now.Add(time.Minute))
+	if pruned != 2 || len(store.buckets) != 1 {
+		t.Fatalf("expected the two idle buckets to be pruned, got %d with %d left", pruned, len(store.buckets))
+	}
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+// attachmentOwner is the attachments column pointing to a post or a comment.
+type attachmentOwner string
+
+const (
+	postAttachment    attachmentOwner = "post_id"
+	commentAttachment attachmentOwner = "comment_id"
+)
+
+func (r *BaseRepository) insertAttachments(ctx context.Context, owner attachmentOwner, ownerID int64, attachments []domain.Attachment) error {
+	query := `INSERT INTO attachments (` + string(owner) + `, position, image_hash, image_path, thumbnail_path, preview_path, alt_text)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          RETURNING id`
+
+	for i := range attachments {
+		a := &attachments[i]
+		err := r.queryRowContext(ctx, query,
+			ownerID,
+			a.Position,
+			nullString(a.Image.Hash),
+			a.Image.Path,
+			nullString(a.Image.ThumbnailPath),
+			nullString(a.Image.PreviewPath),
+			a.AltText,
+		).Scan(&a.ID)
+		if err != nil {
+			return fmt.Errorf("insert attachment %d: %w", a.Position, err)
+		}
+	}
+
+	return nil
+}
+
+// loadAttachments fetches the attachments of several posts or comments in one query, keyed by owner id.
+func (r *BaseRepository) loadAttachments(ctx context.Context, owner attachmentOwner, ownerIDs []int64) (map[int64][]domain.Attachment, error) {
+	attachments := make(map[int64][]domain.Attachment)
+	if len(ownerIDs) == 0 {
+		return attachments, nil
+	}
+
+	query := `SELECT ` + string(owner) + `, id, position, COALESCE(image_hash, ''), image_path,
+	                 COALESCE(thumbnail_path, ''), COALESCE(preview_path, ''), alt_text
+	          FROM attachments
+	          WHERE ` + string(owner) + ` = ANY($1)
+	          ORDER BY ` + string(owner) + `, position`
+
+	rows, err := r.queryContext(ctx, query, pq.Array(ownerIDs))
+	if err != nil {
+		return nil, fmt.Errorf("query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			ownerID int64
+			a       domain.Attachment
+		)
+		err := rows.Scan(
+			&ownerID,
+			&a.ID,
+			&a.Position,
+			&a.Image.Hash,
+			&a.Image.Path,
+			&a.Image.ThumbnailPath,
+			&a.Image.PreviewPath,
+			&a.AltText,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan attachment: %w", err)
+		}
+		attachments[ownerID] = append(attachments[ownerID], a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate attachments: %w", err)
+	}
+
+	return attachments, nil
+}
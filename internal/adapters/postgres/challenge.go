@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/core/service"
+)
+
+type ChallengeRepository struct {
+	br BaseRepository
+}
+
+func NewChallengeRepository(db *sql.DB) *ChallengeRepository {
+	return &ChallengeRepository{BaseRepository{db}}
+}
+
+func (r *ChallengeRepository) Store(ctx context.Context, challenge domain.Challenge) error {
+	const op = "ChallengeRepository.Store"
+
+	_, err := r.br.execContext(ctx, `
+		INSERT INTO challenges(id, kind, difficulty, seed, answer, expires_at)
+		VALUES($1, $2, $3, $4, $5, $6)
+	`, challenge.ID, string(challenge.Kind), challenge.Difficulty, challenge.Seed, challenge.Answer, challenge.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Consume deletes the row it returns, two requests answering the same challenge cannot both get it.
+func (r *ChallengeRepository) Consume(ctx context.Context, id string) (domain.Challenge, error) {
+	const op = "ChallengeRepository.Consume"
+
+	var challenge domain.Challenge
+	var kind string
+	err := r.br.queryRowContext(ctx, `
+		DELETE FROM challenges WHERE id = $1
+		RETURNING id, kind, difficulty, seed, answer, expires_at
+	`, id).Scan(&challenge.ID, &kind, &challenge.Difficulty, &challenge.Seed, &challenge.Answer, &challenge.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Challenge{}, service.ErrChallengeNotFound
+		}
+		return domain.Challenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	challenge.Kind = domain.ChallengeKind(kind)
+
+	return challenge, nil
+}
+
+func (r *ChallengeRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	const op = "ChallengeRepository.DeleteExpired"
+
+	res, err := r.br.execContext(ctx, `DELETE FROM challenges WHERE expires_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return res.RowsAffected()
+}
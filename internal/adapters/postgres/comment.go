@@ -3,9 +3,13 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
+	"github.com/lib/pq"
+
 	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/core/service"
 )
 
 type CommentRepository struct {
@@ -20,23 +24,29 @@ func (r *CommentRepository) SaveComment(ctx context.Context, comment *domain.Com
 	const op = "CommentRepository.SaveComment"
 
 	query := `
-        INSERT INTO comments (post_id, user_id, content, image_path)
+        INSERT INTO comments (post_id, parent_comment_id, user_id, content)
         VALUES ($1, $2, $3, $4)
         RETURNING id
     `
 
-	imagePath := sql.NullString{
-		String: comment.ImagePath,
-		Valid:  comment.ImagePath != "",
+	var parentCommentID sql.NullInt64
+	if comment.ParentCommentID != nil {
+		parentCommentID = sql.NullInt64{Int64: *comment.ParentCommentID, Valid: true}
 	}
 
 	var id int64
-	err := r.br.queryRowContext(ctx, query,
-		comment.PostID,
-		comment.Author.ID,
-		comment.Content,
-		imagePath,
-	).Scan(&id)
+	err := r.br.withinTx(ctx, func(ctx context.Context) error {
+		err := r.br.queryRowContext(ctx, query,
+			comment.PostID,
+			parentCommentID,
+			comment.Author.ID,
+			comment.Content,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return r.br.insertAttachments(ctx, commentAttachment, id, comment.Attachments)
+	})
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
@@ -45,12 +55,87 @@ func (r *CommentRepository) SaveComment(ctx context.Context, comment *domain.Com
 	return id, nil
 }
 
+func (r *CommentRepository) GetByID(ctx context.Context, commentID int64) (*domain.Comment, error) {
+	const op = "CommentRepository.GetByID"
+
+	query := `
+        SELECT c.id, c.post_id, c.parent_comment_id, u.id, u.name,
+               COALESCE(u.avatar_url, '') AS avatar_url,
+               c.content, c.created_at, c.edited_at, c.deleted_at, c.deleted_by, c.delete_reason
+        FROM comments c
+        JOIN users u ON u.id = c.user_id
+        WHERE c.id = $1
+    `
+
+	c, err := scanComment(r.br.queryRowContext(ctx, query, commentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, service.ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	attachments, err := r.br.loadAttachments(ctx, commentAttachment, []int64{c.ID})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	c.Attachments = attachments[c.ID]
+
+	return c, nil
+}
+
+func (r *CommentRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Comment, error) {
+	const op = "CommentRepository.GetByIDs"
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+        SELECT c.id, c.post_id, c.parent_comment_id, u.id, u.name,
+               COALESCE(u.avatar_url, '') AS avatar_url,
+               c.content, c.created_at, c.edited_at, c.deleted_at, c.deleted_by, c.delete_reason
+        FROM comments c
+        JOIN users u ON u.id = c.user_id
+        WHERE c.id = ANY($1)
+    `
+
+	rows, err := r.br.queryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: querying comments: %w", op, err)
+	}
+	defer rows.Close()
+
+	var comments []*domain.Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scanning comment: %w", op, err)
+		}
+		comments = append(comments, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: iterating rows: %w", op, err)
+	}
+
+	attachments, err := r.br.loadAttachments(ctx, commentAttachment, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, c := range comments {
+		c.Attachments = attachments[c.ID]
+	}
+
+	return comments, nil
+}
+
 func (r *CommentRepository) GetByPostID(ctx context.Context, postID int64) ([]*domain.Comment, error) {
 	const op = "CommentRepository.GetByPostID"
 
 	query := `
-        SELECT c.id, u.name, COALESCE(u.avatar_url, '') AS avatar_url, 
-               c.content, c.image_path, c.created_at
+        SELECT c.id, c.post_id, c.parent_comment_id, u.id, u.name,
+               COALESCE(u.avatar_url, '') AS avatar_url,
+               c.content, c.created_at, c.edited_at, c.deleted_at, c.deleted_by, c.delete_reason
         FROM comments c
         JOIN users u ON u.id = c.user_id
         WHERE c.post_id = $1
@@ -65,23 +150,162 @@ func (r *CommentRepository) GetByPostID(ctx context.Context, postID int64) ([]*d
 
 	var comments []*domain.Comment
 	for rows.Next() {
-		var c domain.Comment
-		var imagePath sql.NullString
-		err := rows.Scan(&c.ID, &c.Author.Name, &c.Author.AvatarURL, &c.Content, &imagePath, &c.CreatedAt)
+		c, err := scanComment(rows)
 		if err != nil {
 			return nil, fmt.Errorf("%s: scanning comment: %w", op, err)
 		}
-		if imagePath.Valid {
-			c.ImagePath = imagePath.String
-		} else {
-			c.ImagePath = ""
-		}
-		comments = append(comments, &c)
+		comments = append(comments, c)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("%s: iterating rows: %w", op, err)
 	}
 
+	ids := make([]int64, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+	attachments, err := r.br.loadAttachments(ctx, commentAttachment, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, c := range comments {
+		c.Attachments = attachments[c.ID]
+	}
+
 	return comments, nil
 }
+
+func (r *CommentRepository) GetRecent(ctx context.Context, limit int) ([]*domain.Comment, error) {
+	const op = "CommentRepository.GetRecent"
+
+	query := `
+        SELECT c.id, c.post_id, c.parent_comment_id, u.id, u.name,
+               COALESCE(u.avatar_url, '') AS avatar_url,
+               c.content, c.created_at, c.edited_at, c.deleted_at, c.deleted_by, c.delete_reason
+        FROM comments c
+        JOIN users u ON u.id = c.user_id
+        ORDER BY c.created_at DESC, c.id DESC
+        LIMIT $1
+    `
+
+	rows, err := r.br.queryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: querying comments: %w", op, err)
+	}
+	defer rows.Close()
+
+	var comments []*domain.Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scanning comment: %w", op, err)
+		}
+		comments = append(comments, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: iterating rows: %w", op, err)
+	}
+
+	return comments, nil
+}
+
+func (r *CommentRepository) DeleteComment(ctx context.Context, commentID int64, deletion domain.Deletion) error {
+	const op = "CommentRepository.DeleteComment"
+
+	query := `UPDATE comments SET deleted_at = $1, deleted_by = $2, delete_reason = $3
+	          WHERE id = $4 AND deleted_at IS NULL`
+
+	err := r.br.withinTx(ctx, func(ctx context.Context) error {
+		if _, err := r.br.execContext(ctx, query, deletion.At, deletion.By, nullString(deletion.Reason), commentID); err != nil {
+			return err
+		}
+		_, err := r.br.closeReports(ctx, "comment_id = $1", commentID, deletionResolution(deletion))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *CommentRepository) EditComment(ctx context.Context, comment *domain.Comment, revision domain.Revision) error {
+	const op = "CommentRepository.EditComment"
+
+	query := `UPDATE comments SET content = $1, edited_at = $2 WHERE id = $3`
+
+	err := r.br.withinTx(ctx, func(ctx context.Context) error {
+		if _, err := r.br.execContext(ctx, query, comment.Content, comment.EditedAt, comment.ID); err != nil {
+			return err
+		}
+		return r.br.insertRevision(ctx, commentRevision, comment.ID, revision)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *CommentRepository) GetCommentRevisions(ctx context.Context, commentID int64) ([]domain.Revision, error) {
+	const op = "CommentRepository.GetCommentRevisions"
+
+	revisions, err := r.br.loadRevisions(ctx, commentRevision, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revisions, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanComment(row rowScanner) (*domain.Comment, error) {
+	var (
+		c                       domain.Comment
+		parentCommentID         sql.NullInt64
+		editedAt, deletedAt     sql.NullTime
+		deletedBy, deleteReason sql.NullString
+	)
+	err := row.Scan(
+		&c.ID,
+		&c.PostID,
+		&parentCommentID,
+		&c.Author.ID,
+		&c.Author.Name,
+		&c.Author.AvatarURL,
+		&c.Content,
+		&c.CreatedAt,
+		&editedAt,
+		&deletedAt,
+		&deletedBy,
+		&deleteReason,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if parentCommentID.Valid {
+		id := parentCommentID.Int64
+		c.ParentCommentID = &id
+	}
+	c.EditedAt = editedAt.Time
+	c.Deleted = toDeletion(deletedAt, deletedBy, deleteReason)
+
+	return &c, nil
+}
+
+// toDeletion reads the deletion columns of a post or comment, nil while it is not deleted.
+func toDeletion(at sql.NullTime, by, reason sql.NullString) *domain.Deletion {
+	if !at.Valid {
+		return nil
+	}
+	return &domain.Deletion{At: at.Time, By: by.String, Reason: reason.String}
+}
+
+// nullString stores empty optional paths as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
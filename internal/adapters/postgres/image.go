@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/core/service"
+)
+
+type ImageRepository struct {
+	br BaseRepository
+}
+
+func NewImageRepository(db *sql.DB) *ImageRepository {
+	return &ImageRepository{BaseRepository{db}}
+}
+
+// visibleAttachments selects the attachments of image i that belong to a visible post or comment. Attachments
+// of deleted posts and comments, and of comments under a deleted post, do not count.
+const visibleAttachments = `FROM attachments a
+	LEFT JOIN comments c ON c.id = a.comment_id
+	LEFT JOIN posts p ON p.id = COALESCE(a.post_id, c.post_id)
+	WHERE a.image_hash = i.hash AND c.deleted_at IS NULL AND p.deleted_at IS NULL`
+
+// imageColumns expects the images table to be aliased as i, the reference count is derived from the attachments.
+const imageColumns = `i.hash, COALESCE(i.owner_id, 0), i.size_bytes, i.mime_type, i.path,
+                      COALESCE(i.thumbnail_path, ''), COALESCE(i.preview_path, ''), i.created_at, i.last_used_at,
+                      (SELECT COUNT(*) ` + visibleAttachments + `)`
+
+func (r *ImageRepository) AcquireImage(ctx context.Context, hash string) (domain.ImageRecord, error) {
+	const op = "ImageRepository.AcquireImage"
+
+	query := `UPDATE images i SET last_used_at = NOW()
+	          WHERE i.hash = $1
+	          RETURNING ` + imageColumns
+
+	record, err := scanImage(r.br.queryRowContext(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ImageRecord{}, service.ErrImageNotFound
+		}
+		return domain.ImageRecord{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return record, nil
+}
+
+func (r *ImageRepository) SaveImage(ctx context.Context, record *domain.ImageRecord) error {
+	const op = "ImageRepository.SaveImage"
+
+	// a concurrent upload of the same content may have won the race, its row is returned then
+	query := `INSERT INTO images AS i (hash, owner_id, size_bytes, mime_type, path, thumbnail_path, preview_path)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          ON CONFLICT (hash) DO UPDATE SET last_used_at = NOW()
+	          RETURNING ` + imageColumns
+
+	saved, err := scanImage(r.br.queryRowContext(ctx, query,
+		record.Image.Hash,
+		record.OwnerID,
+		record.Size,
+		record.MIMEType,
+		record.Image.Path,
+		nullString(record.Image.ThumbnailPath),
+		nullString(record.Image.PreviewPath),
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	*record = saved
+	return nil
+}
+
+// unreferencedImage matches images no visible post or comment points to, so images of deleted content
+// stop being served.
+const unreferencedImage = `NOT EXISTS (SELECT 1 ` + visibleAttachments + `)`
+
+func (r *ImageRepository) ListUnreferencedImages(ctx context.Context, before time.Time) ([]string, error) {
+	const op = "ImageRepository.ListUnreferencedImages"
+
+	query := `SELECT i.hash FROM images i
+	          WHERE i.last_used_at < $1
+	          AND ` + unreferencedImage
+
+	return r.queryHashes(ctx, op, query, before)
+}
+
+func (r *ImageRepository) DeleteUnreferencedImage(ctx context.Context, hash string, before time.Time) (bool, error) {
+	const op = "ImageRepository.DeleteUnreferencedImage"
+
+	// conditions are checked again, the image may have been attached since it was listed
+	query := `DELETE FROM images i
+	          WHERE i.hash = $1 AND i.last_used_at < $2
+	          AND ` + unreferencedImage
+
+	res, err := r.br.execContext(ctx, query, hash, before)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: rows affected: %w", op, err)
+	}
+
+	return deleted > 0, nil
+}
+
+func (r *ImageRepository) ListImageHashes(ctx context.Context) ([]string, error) {
+	const op = "ImageRepository.ListImageHashes"
+
+	return r.queryHashes(ctx, op, `SELECT hash FROM images`)
+}
+
+func (r *ImageRepository) queryHashes(ctx context.Context, op, query string, args ...any) ([]string, error) {
+	rows, err := r.br.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return hashes, nil
+}
+
+func scanImage(row rowScanner) (domain.ImageRecord, error) {
+	var record domain.ImageRecord
+	err := row.Scan(
+		&record.Image.Hash,
+		&record.OwnerID,
+		&record.Size,
+		&record.MIMEType,
+		&record.Image.Path,
+		&record.Image.ThumbnailPath,
+		&record.Image.PreviewPath,
+		&record.CreatedAt,
+		&record.LastUsedAt,
+		&record.RefCount,
+	)
+	return record, err
+}
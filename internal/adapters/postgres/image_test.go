@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/adapters/postgres/migrations"
+	"go-hex-forum/internal/core/domain"
+)
+
+// testDB connects to the database in TEST_DATABASE_URL and migrates it. Tests needing it are skipped
+// when the variable is not set, point it at a scratch database, tests add rows and never clean up.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMigrator(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestImageRepository_sweepsDeletedContent(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	images := NewImageRepository(db)
+	posts := NewPostRepository(db)
+	comments := NewCommentRepository(db)
+
+	var userID int64
+	if err := db.QueryRowContext(ctx, `INSERT INTO users (name, avatar_url) VALUES ('sweeper', '') RETURNING id`).Scan(&userID); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	// hashes are unique per run, the database is shared between runs
+	run := time.Now().UnixNano()
+	image := func(name string) domain.Attachment {
+		record := domain.ImageRecord{Image: domain.Image{Hash: fmt.Sprintf("%s-%d", name, run), Path: "/media/" + name}, OwnerID: userID, MIMEType: "image/png"}
+		if err := images.SaveImage(ctx, &record); err != nil {
+			t.Fatalf("save image %s: %v", name, err)
+		}
+		return domain.Attachment{Image: record.Image}
+	}
+	post := func(attachments ...domain.Attachment) int64 {
+		now := time.Now()
+		id, err := posts.SavePost(ctx, &domain.Post{Title: "t", Content: "c", CreatedAt: now, ExpiresAt: now.Add(time.Hour), Attachments: attachments}, userID)
+		if err != nil {
+			t.Fatalf("save post: %v", err)
+		}
+		return id
+	}
+	comment := func(postID int64, attachments ...domain.Attachment) int64 {
+		id, err := comments.SaveComment(ctx, &domain.Comment{PostID: postID, Content: "c", Author: domain.UserData{ID: userID}, Attachments: attachments})
+		if err != nil {
+			t.Fatalf("save comment: %v", err)
+		}
+		return id
+	}
+
+	live, deletedPost := post(image("live")), post(image("deleted-post"))
+	comment(deletedPost, image("under-deleted-post"))
+	deletedComment := comment(live, image("deleted-comment"))
+	// an image shared with visible content stays
+	shared := image("shared")
+	comment(live, shared)
+	comment(deletedPost, shared)
+
+	deletion := domain.Deletion{At: time.Now(), By: "moderator:mod"}
+	if err := posts.DeletePost(ctx, deletedPost, deletion); err != nil {
+		t.Fatalf("delete post: %v", err)
+	}
+	if err := comments.DeleteComment(ctx, deletedComment, deletion); err != nil {
+		t.Fatalf("delete comment: %v", err)
+	}
+
+	// only visible content counts as a reference
+	for name, refs := range map[string]int64{"live": 1, "shared": 1, "deleted-post": 0, "deleted-comment": 0} {
+		record, err := images.AcquireImage(ctx, fmt.Sprintf("%s-%d", name, run))
+		if err != nil || record.RefCount != refs {
+			t.Fatalf("%s: expected %d references, got %d and %v", name, refs, record.RefCount, err)
+		}
+	}
+
+	before := time.Now().Add(time.Hour)
+	hashes, err := images.ListUnreferencedImages(ctx, before)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for name, swept := range map[string]bool{"live": false, "shared": false, "deleted-post": true, "under-deleted-post": true, "deleted-comment": true} {
+		if slices.Contains(hashes, fmt.Sprintf("%s-%d", name, run)) != swept {
+			t.Fatalf("%s: expected swept to be %v, got %v", name, swept, hashes)
+		}
+	}
+
+	// the attachment row of the deleted post outlives its image
+	deleted, err := images.DeleteUnreferencedImage(ctx, fmt.Sprintf("deleted-post-%d", run), before)
+	if err != nil || !deleted {
+		t.Fatalf("expected the image to be deleted, got %v and %v", deleted, err)
+	}
+	if _, err := images.DeleteUnreferencedImage(ctx, fmt.Sprintf("live-%d", run), before); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := images.AcquireImage(ctx, fmt.Sprintf("live-%d", run)); err != nil {
+		t.Fatalf("expected the live image to be kept, got %v", err)
+	}
+}
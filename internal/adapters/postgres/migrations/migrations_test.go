@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	sub, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	migrations, err := loadMigrations(sub)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatalf("expected embedded migrations, got none")
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i-1].Version >= migrations[i].Version {
+			t.Fatalf("migrations are not ordered: %d before %d", migrations[i-1].Version, migrations[i].Version)
+		}
+	}
+}
+
+func TestLoadMigrations_Ordered(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("SELECT 2;")},
+		"0002_second.down.sql": {Data: []byte("SELECT -2;")},
+		"0001_first.up.sql":    {Data: []byte("SELECT 1;")},
+		"0001_first.down.sql":  {Data: []byte("SELECT -1;")},
+	}
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Name != "first" || migrations[1].Name != "second" {
+		t.Fatalf("unexpected order: %v", migrations)
+	}
+	if migrations[1].Up != "SELECT 2;" || migrations[1].Down != "SELECT -2;" {
+		t.Fatalf("unexpected bodies: %v", migrations[1])
+	}
+}
+
+func TestLoadMigrations_Fail_missingDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_first.up.sql": {Data: []byte("SELECT 1;")},
+	}
+	if _, err := loadMigrations(fsys); err == nil {
+		t.Fatalf("expected error, got none")
+	}
+}
+
+func TestLoadMigrations_Fail_badName(t *testing.T) {
+	fsys := fstest.MapFS{
+		"first.up.sql":   {Data: []byte("SELECT 1;")},
+		"first.down.sql": {Data: []byte("SELECT -1;")},
+	}
+	if _, err := loadMigrations(fsys); err == nil {
+		t.Fatalf("expected error, got none")
+	}
+}
+
+func TestLoadMigrations_Fail_duplicateVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_first.up.sql":   {Data: []byte("SELECT 1;")},
+		"0001_first.down.sql": {Data: []byte("SELECT -1;")},
+		"0001_other.up.sql":   {Data: []byte("SELECT 1;")},
+		"0001_other.down.sql": {Data: []byte("SELECT -1;")},
+	}
+	if _, err := loadMigrations(fsys); err == nil {
+		t.Fatalf("expected error, got none")
+	}
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS comments_parent_comment_id_idx;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS parent_comment_id;
@@ -0,0 +1,4 @@
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS parent_comment_id INTEGER REFERENCES comments(id);
+
+CREATE INDEX IF NOT EXISTS comments_parent_comment_id_idx ON comments(parent_comment_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS posts_listing_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS posts_listing_idx ON posts (is_archived, created_at DESC, id DESC);
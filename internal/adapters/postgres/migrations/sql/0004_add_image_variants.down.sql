@@ -0,0 +1,7 @@
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS preview_path,
+    DROP COLUMN IF EXISTS thumbnail_path;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS preview_path,
+    DROP COLUMN IF EXISTS thumbnail_path;
@@ -0,0 +1,7 @@
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS thumbnail_path TEXT,
+    ADD COLUMN IF NOT EXISTS preview_path TEXT;
+
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS thumbnail_path TEXT,
+    ADD COLUMN IF NOT EXISTS preview_path TEXT;
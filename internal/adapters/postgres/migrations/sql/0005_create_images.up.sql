@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS images (
+    hash TEXT PRIMARY KEY,
+    owner_id INTEGER REFERENCES users(id),
+    size_bytes BIGINT NOT NULL,
+    mime_type TEXT NOT NULL,
+    path TEXT NOT NULL,
+    thumbnail_path TEXT,
+    preview_path TEXT,
+    ref_count INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS images_last_used_at_idx;
+DROP INDEX IF EXISTS comments_image_hash_idx;
+DROP INDEX IF EXISTS posts_image_hash_idx;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS image_hash;
+ALTER TABLE posts DROP COLUMN IF EXISTS image_hash;
+ALTER TABLE images DROP COLUMN IF EXISTS last_used_at;
@@ -0,0 +1,12 @@
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS image_hash TEXT REFERENCES images(hash);
+
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS image_hash TEXT REFERENCES images(hash);
+
+CREATE INDEX IF NOT EXISTS posts_image_hash_idx ON posts(image_hash) WHERE image_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS comments_image_hash_idx ON comments(image_hash) WHERE image_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS images_last_used_at_idx ON images(last_used_at);
@@ -0,0 +1,26 @@
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS image_path TEXT,
+    ADD COLUMN IF NOT EXISTS thumbnail_path TEXT,
+    ADD COLUMN IF NOT EXISTS preview_path TEXT,
+    ADD COLUMN IF NOT EXISTS image_hash TEXT REFERENCES images(hash);
+
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS image_path TEXT,
+    ADD COLUMN IF NOT EXISTS thumbnail_path TEXT,
+    ADD COLUMN IF NOT EXISTS preview_path TEXT,
+    ADD COLUMN IF NOT EXISTS image_hash TEXT REFERENCES images(hash);
+
+UPDATE posts p
+SET image_path = a.image_path, thumbnail_path = a.thumbnail_path, preview_path = a.preview_path, image_hash = a.image_hash
+FROM attachments a
+WHERE a.post_id = p.id AND a.position = 0;
+
+UPDATE comments c
+SET image_path = a.image_path, thumbnail_path = a.thumbnail_path, preview_path = a.preview_path, image_hash = a.image_hash
+FROM attachments a
+WHERE a.comment_id = c.id AND a.position = 0;
+
+CREATE INDEX IF NOT EXISTS posts_image_hash_idx ON posts(image_hash) WHERE image_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS comments_image_hash_idx ON comments(image_hash) WHERE image_hash IS NOT NULL;
+
+DROP TABLE IF EXISTS attachments;
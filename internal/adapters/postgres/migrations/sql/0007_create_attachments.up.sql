@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    image_hash TEXT REFERENCES images(hash),
+    image_path TEXT NOT NULL,
+    thumbnail_path TEXT,
+    preview_path TEXT,
+    alt_text TEXT NOT NULL DEFAULT '',
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS attachments_post_position_idx ON attachments(post_id, position) WHERE post_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS attachments_comment_position_idx ON attachments(comment_id, position) WHERE comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS attachments_image_hash_idx ON attachments(image_hash) WHERE image_hash IS NOT NULL;
+
+INSERT INTO attachments (post_id, position, image_hash, image_path, thumbnail_path, preview_path)
+SELECT id, 0, image_hash, image_path, thumbnail_path, preview_path
+FROM posts
+WHERE image_path IS NOT NULL AND image_path <> '';
+
+INSERT INTO attachments (comment_id, position, image_hash, image_path, thumbnail_path, preview_path)
+SELECT id, 0, image_hash, image_path, thumbnail_path, preview_path
+FROM comments
+WHERE image_path IS NOT NULL AND image_path <> '';
+
+DROP INDEX IF EXISTS posts_image_hash_idx;
+DROP INDEX IF EXISTS comments_image_hash_idx;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS image_path,
+    DROP COLUMN IF EXISTS thumbnail_path,
+    DROP COLUMN IF EXISTS preview_path,
+    DROP COLUMN IF EXISTS image_hash;
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS image_path,
+    DROP COLUMN IF EXISTS thumbnail_path,
+    DROP COLUMN IF EXISTS preview_path,
+    DROP COLUMN IF EXISTS image_hash;
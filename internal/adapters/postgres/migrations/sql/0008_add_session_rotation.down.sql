@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS sessions_user_id_idx;
+DROP INDEX IF EXISTS sessions_previous_hash_idx;
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS rotated_at,
+    DROP COLUMN IF EXISTS previous_hash;
@@ -0,0 +1,10 @@
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS previous_hash TEXT,
+    ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMP;
+
+UPDATE sessions SET rotated_at = created_at WHERE rotated_at IS NULL;
+
+ALTER TABLE sessions ALTER COLUMN rotated_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS sessions_previous_hash_idx ON sessions(previous_hash) WHERE previous_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions(user_id);
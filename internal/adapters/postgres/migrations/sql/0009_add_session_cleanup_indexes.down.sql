@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS images_owner_id_idx;
+DROP INDEX IF EXISTS comments_user_id_idx;
+DROP INDEX IF EXISTS posts_user_id_idx;
+DROP INDEX IF EXISTS sessions_expires_at_idx;
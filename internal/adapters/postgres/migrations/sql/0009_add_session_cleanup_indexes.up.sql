@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS sessions_expires_at_idx ON sessions(expires_at);
+CREATE INDEX IF NOT EXISTS posts_user_id_idx ON posts(user_id);
+CREATE INDEX IF NOT EXISTS comments_user_id_idx ON comments(user_id);
+CREATE INDEX IF NOT EXISTS images_owner_id_idx ON images(owner_id) WHERE owner_id IS NOT NULL;
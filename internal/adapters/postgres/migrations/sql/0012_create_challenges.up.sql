@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS challenges (
+    id         TEXT PRIMARY KEY,
+    kind       TEXT NOT NULL,
+    difficulty INTEGER NOT NULL,
+    seed       TEXT NOT NULL,
+    answer     TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS challenges_expires_at_idx ON challenges(expires_at);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS moderator_sessions (
+    token_hash TEXT PRIMARY KEY,
+    login      TEXT NOT NULL,
+    role       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS moderator_sessions_expires_at_idx ON moderator_sessions(expires_at);
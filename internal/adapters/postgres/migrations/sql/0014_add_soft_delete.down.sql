@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS posts_listing_idx;
+CREATE INDEX IF NOT EXISTS posts_listing_idx ON posts (is_archived, created_at DESC, id DESC);
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS delete_reason,
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS delete_reason,
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,13 @@
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deleted_by TEXT,
+    ADD COLUMN IF NOT EXISTS delete_reason TEXT;
+
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deleted_by TEXT,
+    ADD COLUMN IF NOT EXISTS delete_reason TEXT;
+
+-- listings never show deleted posts
+DROP INDEX IF EXISTS posts_listing_idx;
+CREATE INDEX IF NOT EXISTS posts_listing_idx ON posts (is_archived, created_at DESC, id DESC) WHERE deleted_at IS NULL;
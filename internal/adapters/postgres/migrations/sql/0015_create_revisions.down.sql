@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS revisions;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS edited_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS edited_at;
@@ -0,0 +1,16 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS revisions (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    title TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    edited_by TEXT NOT NULL,
+    edited_at TIMESTAMPTZ NOT NULL,
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS revisions_post_id_idx ON revisions(post_id, edited_at DESC) WHERE post_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS revisions_comment_id_idx ON revisions(comment_id, edited_at DESC) WHERE comment_id IS NOT NULL;
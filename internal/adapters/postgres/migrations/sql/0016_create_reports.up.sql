@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    -- reporters are anonymous users, cleaning them up must not take their reports along
+    reporter_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ,
+    resolved_by TEXT,
+    resolution TEXT,
+    resolution_note TEXT,
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+-- one open report per reader and target
+CREATE UNIQUE INDEX IF NOT EXISTS reports_open_post_idx ON reports(reporter_id, post_id) WHERE resolved_at IS NULL AND post_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS reports_open_comment_idx ON reports(reporter_id, comment_id) WHERE resolved_at IS NULL AND comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS reports_open_idx ON reports(created_at) WHERE resolved_at IS NULL;
@@ -0,0 +1 @@
+ALTER TABLE images ADD COLUMN IF NOT EXISTS ref_count INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- attachments are what keeps an image alive, the counter was never decremented and nothing read it
+ALTER TABLE images DROP COLUMN IF EXISTS ref_count;
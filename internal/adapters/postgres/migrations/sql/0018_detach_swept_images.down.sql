@@ -0,0 +1,3 @@
+ALTER TABLE attachments
+    DROP CONSTRAINT IF EXISTS attachments_image_hash_fkey,
+    ADD CONSTRAINT attachments_image_hash_fkey FOREIGN KEY (image_hash) REFERENCES images(hash);
@@ -0,0 +1,4 @@
+-- images of deleted posts and comments are swept, their attachment rows stay for moderators without the image
+ALTER TABLE attachments
+    DROP CONSTRAINT IF EXISTS attachments_image_hash_fkey,
+    ADD CONSTRAINT attachments_image_hash_fkey FOREIGN KEY (image_hash) REFERENCES images(hash) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS reports_open_comment_target_idx;
+DROP INDEX IF EXISTS reports_open_post_target_idx;
@@ -0,0 +1,3 @@
+-- the moderation queue and deletions look up the open reports of posts and comments
+CREATE INDEX IF NOT EXISTS reports_open_post_target_idx ON reports(post_id) WHERE resolved_at IS NULL AND post_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS reports_open_comment_target_idx ON reports(comment_id) WHERE resolved_at IS NULL AND comment_id IS NOT NULL;
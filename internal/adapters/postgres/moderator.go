@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/core/service"
+)
+
+type ModeratorSessionRepository struct {
+	br BaseRepository
+}
+
+func NewModeratorSessionRepository(db *sql.DB) *ModeratorSessionRepository {
+	return &ModeratorSessionRepository{BaseRepository{db}}
+}
+
+func (r *ModeratorSessionRepository) Store(ctx context.Context, session domain.ModeratorSession) error {
+	const op = "ModeratorSessionRepository.Store"
+
+	_, err := r.br.execContext(ctx, `
+		INSERT INTO moderator_sessions(token_hash, login, role, created_at, expires_at)
+		VALUES($1, $2, $3, $4, $5)
+	`, session.TokenHash, session.Login, string(session.Role), session.CreatedAt, session.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *ModeratorSessionRepository) GetByHash(ctx context.Context, hash string) (domain.ModeratorSession, error) {
+	const op = "ModeratorSessionRepository.GetByHash"
+
+	var session domain.ModeratorSession
+	var role string
+	err := r.br.queryRowContext(ctx, `
+		SELECT token_hash, login, role, created_at, expires_at
+		FROM moderator_sessions
+		WHERE token_hash = $1
+	`, hash).Scan(&session.TokenHash, &session.Login, &role, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ModeratorSession{}, service.ErrSessionNotFound
+		}
+		return domain.ModeratorSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+	session.Role = domain.Role(role)
+
+	return session, nil
+}
+
+func (r *ModeratorSessionRepository) Delete(ctx context.Context, hash string) error {
+	const op = "ModeratorSessionRepository.Delete"
+
+	if _, err := r.br.execContext(ctx, `DELETE FROM moderator_sessions WHERE token_hash = $1`, hash); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *ModeratorSessionRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	const op = "ModeratorSessionRepository.DeleteExpired"
+
+	res, err := r.br.execContext(ctx, `DELETE FROM moderator_sessions WHERE expires_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return res.RowsAffected()
+}
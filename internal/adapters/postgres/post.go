@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
+
 	"go-hex-forum/internal/core/domain"
 )
 
@@ -20,24 +22,24 @@ func NewPostRepository(db *sql.DB) *PostRepository {
 func (r *PostRepository) SavePost(ctx context.Context, post *domain.Post, userID int64) (int64, error) {
 	const op = "PostRepository.SavePost"
 
-	query := `INSERT INTO posts (user_id, title, content, image_path, created_at, expires_at)
-	          VALUES ($1, $2, $3, $4, $5, $6)
+	query := `INSERT INTO posts (user_id, title, content, created_at, expires_at)
+	          VALUES ($1, $2, $3, $4, $5)
 	          RETURNING id`
 
-	imagePath := sql.NullString{
-		String: post.ImagePath,
-		Valid:  post.ImagePath != "",
-	}
-
 	var id int64
-	err := r.br.queryRowContext(ctx, query,
-		userID,
-		post.Title,
-		post.Content,
-		imagePath,
-		post.CreatedAt,
-		post.ExpiresAt,
-	).Scan(&id)
+	err := r.br.withinTx(ctx, func(ctx context.Context) error {
+		err := r.br.queryRowContext(ctx, query,
+			userID,
+			post.Title,
+			post.Content,
+			post.CreatedAt,
+			post.ExpiresAt,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return r.br.insertAttachments(ctx, postAttachment, id, post.Attachments)
+	})
 	if err != nil {
 		return -1, fmt.Errorf("%s: %w", op, err)
 	}
@@ -48,68 +50,64 @@ func (r *PostRepository) SavePost(ctx context.Context, post *domain.Post, userID
 func (r *PostRepository) GetActivePosts(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error) {
 	const op = "PostRepository.GetActivePosts"
 
-	var posts []domain.Post
-	query := `SELECT p.id, u.id, u.name, COALESCE(u.avatar_url, '') AS avatar_url,
-	                 p.title, p.content, COALESCE(p.image_path, '') AS image_path,
-	                 p.created_at, p.is_archived 
-	          FROM posts p
-	          JOIN users u ON u.id = p.user_id
-	          WHERE p.is_archived = false
-	          ORDER BY p.created_at DESC
-	          LIMIT $1 OFFSET $2`
-
-	offset := (pagination.Page - 1) * pagination.PageSize
-
-	rows, err := r.br.queryContext(ctx, query, pagination.PageSize, offset)
+	posts, err := r.listPosts(ctx, false, pagination)
 	if err != nil {
-		return posts, fmt.Errorf("%s: queryContext: %w", op, err)
+		return posts, fmt.Errorf("%s: %w", op, err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var post domain.Post
-		err := rows.Scan(
-			&post.ID,
-			&post.PostAuthor.ID,
-			&post.PostAuthor.Name,
-			&post.PostAuthor.AvatarURL,
-			&post.Title,
-			&post.Content,
-			&post.ImagePath,
-			&post.CreatedAt,
-			&post.IsArchived,
-		)
-		if err != nil {
-			return posts, fmt.Errorf("%s: rows.Scan: %w", op, err)
-		}
-		posts = append(posts, post)
-	}
+	return posts, nil
+}
 
-	if err := rows.Err(); err != nil {
-		return posts, fmt.Errorf("%s: rows.Err: %w", op, err)
+func (r *PostRepository) GetArchivedPosts(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error) {
+	const op = "PostRepository.GetArchivedPosts"
+
+	posts, err := r.listPosts(ctx, true, pagination)
+	if err != nil {
+		return posts, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return posts, nil
 }
 
-func (r *PostRepository) GetArchivedPosts(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error) {
-	const op = "PostRepository.GetArchivedPosts"
+func (r *PostRepository) CountPosts(ctx context.Context, archived bool) (int64, error) {
+	const op = "PostRepository.CountPosts"
+
+	var total int64
+	err := r.br.queryRowContext(ctx, `SELECT COUNT(*) FROM posts WHERE is_archived = $1 AND deleted_at IS NULL`, archived).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return total, nil
+}
 
+// listPosts pages through posts that are not deleted newest first, by offset or, when a cursor is set, by keyset on (created_at, id).
+// It reads one post past the page, telling the caller whether there is a next one.
+func (r *PostRepository) listPosts(ctx context.Context, archived bool, pagination *domain.Pagination) ([]domain.Post, error) {
 	var posts []domain.Post
 	query := `SELECT p.id, u.id, u.name, COALESCE(u.avatar_url, '') AS avatar_url,
-	                 p.title, p.content, COALESCE(p.image_path, '') AS image_path,
-	                 p.created_at, p.is_archived 
+	                 p.title, p.content, p.created_at, p.is_archived
 	          FROM posts p
 	          JOIN users u ON u.id = p.user_id
-	          WHERE p.is_archived = true
-	          ORDER BY p.created_at DESC
-	          LIMIT $1 OFFSET $2`
-
-	offset := (pagination.Page - 1) * pagination.PageSize
+	          WHERE p.is_archived = $1 AND p.deleted_at IS NULL`
+
+	args := []interface{}{archived}
+	if pagination.Cursor != nil {
+		query += `
+	          AND (p.created_at, p.id) < ($2, $3)
+	          ORDER BY p.created_at DESC, p.id DESC
+	          LIMIT $4`
+		args = append(args, pagination.Cursor.CreatedAt, pagination.Cursor.ID, pagination.PageSize+1)
+	} else {
+		query += `
+	          ORDER BY p.created_at DESC, p.id DESC
+	          LIMIT $2 OFFSET $3`
+		args = append(args, pagination.PageSize+1, pagination.Offset())
+	}
 
-	rows, err := r.br.queryContext(ctx, query, pagination.PageSize, offset)
+	rows, err := r.br.queryContext(ctx, query, args...)
 	if err != nil {
-		return posts, fmt.Errorf("%s: queryContext: %w", op, err)
+		return posts, fmt.Errorf("queryContext: %w", err)
 	}
 	defer rows.Close()
 
@@ -122,63 +120,146 @@ func (r *PostRepository) GetArchivedPosts(ctx context.Context, pagination *domai
 			&post.PostAuthor.AvatarURL,
 			&post.Title,
 			&post.Content,
-			&post.ImagePath,
 			&post.CreatedAt,
 			&post.IsArchived,
 		)
 		if err != nil {
-			return posts, fmt.Errorf("%s: rows.Scan: %w", op, err)
+			return posts, fmt.Errorf("rows.Scan: %w", err)
 		}
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return posts, fmt.Errorf("%s: rows.Err: %w", op, err)
+		return posts, fmt.Errorf("rows.Err: %w", err)
+	}
+
+	ids := make([]int64, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	attachments, err := r.br.loadAttachments(ctx, postAttachment, ids)
+	if err != nil {
+		return posts, err
+	}
+	for i := range posts {
+		posts[i].Attachments = attachments[posts[i].ID]
 	}
 
 	return posts, nil
 }
 
-func (r *PostRepository) GetPostByID(ctx context.Context, postID int64) (domain.Post, error) {
-	const op = "PostRepository.GetPostByID"
-
-	var post domain.Post
-
-	query := `
-        SELECT 
+// postColumns selects a post with its author from posts p joined with users u, as scanPost reads it.
+const postColumns = `
             p.id,
             u.id,
             u.name,
             COALESCE(u.avatar_url, '') AS avatar_url,
             p.title,
             p.content,
-            COALESCE(p.image_path, '') AS image_path,
             p.created_at,
             p.expires_at,
-            p.is_archived
-        FROM posts p
-        JOIN users u ON u.id = p.user_id
-        WHERE p.id = $1
-    `
-	err := r.br.queryRowContext(ctx, query, postID).Scan(
+            p.is_archived,
+            (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+            p.edited_at,
+            p.deleted_at,
+            p.deleted_by,
+            p.delete_reason`
+
+func scanPost(row rowScanner) (domain.Post, error) {
+	var (
+		post                    domain.Post
+		editedAt, deletedAt     sql.NullTime
+		deletedBy, deleteReason sql.NullString
+	)
+	err := row.Scan(
 		&post.ID,
 		&post.PostAuthor.ID,
 		&post.PostAuthor.Name,
 		&post.PostAuthor.AvatarURL,
 		&post.Title,
 		&post.Content,
-		&post.ImagePath,
 		&post.CreatedAt,
 		&post.ExpiresAt,
 		&post.IsArchived,
+		&post.CommentsCount,
+		&editedAt,
+		&deletedAt,
+		&deletedBy,
+		&deleteReason,
 	)
+	if err != nil {
+		return post, err
+	}
+	post.EditedAt = editedAt.Time
+	post.Deleted = toDeletion(deletedAt, deletedBy, deleteReason)
+	return post, nil
+}
+
+func (r *PostRepository) GetPostByID(ctx context.Context, postID int64) (domain.Post, error) {
+	const op = "PostRepository.GetPostByID"
+
+	query := `
+        SELECT` + postColumns + `
+        FROM posts p
+        JOIN users u ON u.id = p.user_id
+        WHERE p.id = $1
+    `
+	post, err := scanPost(r.br.queryRowContext(ctx, query, postID))
 	if err != nil {
 		return post, fmt.Errorf("%s: %w", op, err)
 	}
 
+	attachments, err := r.br.loadAttachments(ctx, postAttachment, []int64{post.ID})
+	if err != nil {
+		return post, fmt.Errorf("%s: %w", op, err)
+	}
+	post.Attachments = attachments[post.ID]
+
 	return post, nil
 }
 
+func (r *PostRepository) GetPostsByIDs(ctx context.Context, ids []int64) ([]domain.Post, error) {
+	const op = "PostRepository.GetPostsByIDs"
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+        SELECT` + postColumns + `
+        FROM posts p
+        JOIN users u ON u.id = p.user_id
+        WHERE p.id = ANY($1)
+    `
+	rows, err := r.br.queryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var posts []domain.Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	attachments, err := r.br.loadAttachments(ctx, postAttachment, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for i := range posts {
+		posts[i].Attachments = attachments[posts[i].ID]
+	}
+
+	return posts, nil
+}
+
 func (r *PostRepository) UpdateExpiresAt(ctx context.Context, postID int64, date time.Time) error {
 	const op = "PostRepository.UpdateExpiresAt"
 
@@ -192,15 +273,70 @@ func (r *PostRepository) UpdateExpiresAt(ctx context.Context, postID int64, date
 	return nil
 }
 
-func (r *PostRepository) ArchiveExpiredPosts(ctx context.Context) error {
+func (r *PostRepository) ArchiveExpiredPosts(ctx context.Context, now time.Time) (int64, error) {
 	const op = "PostRepository.ArchiveExpiredPosts"
 
-	query := `UPDATE posts SET is_archived = true WHERE expires_at <= NOW() AND is_archived = false`
+	query := `UPDATE posts SET is_archived = true WHERE expires_at <= $1 AND is_archived = false`
+
+	res, err := r.br.execContext(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	archived, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: rows affected: %w", op, err)
+	}
+
+	return archived, nil
+}
+
+func (r *PostRepository) DeletePost(ctx context.Context, postID int64, deletion domain.Deletion) error {
+	const op = "PostRepository.DeletePost"
+
+	query := `UPDATE posts SET deleted_at = $1, deleted_by = $2, delete_reason = $3
+	          WHERE id = $4 AND deleted_at IS NULL`
+
+	// the reports on the post and on its comments, which are gone with it, are closed as well
+	err := r.br.withinTx(ctx, func(ctx context.Context) error {
+		if _, err := r.br.execContext(ctx, query, deletion.At, deletion.By, nullString(deletion.Reason), postID); err != nil {
+			return err
+		}
+		_, err := r.br.closeReports(ctx, "post_id = $1 OR comment_id IN (SELECT id FROM comments WHERE post_id = $1)", postID, deletionResolution(deletion))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *PostRepository) EditPost(ctx context.Context, post *domain.Post, revision domain.Revision) error {
+	const op = "PostRepository.EditPost"
 
-	_, err := r.br.execContext(ctx, query)
+	query := `UPDATE posts SET title = $1, content = $2, edited_at = $3 WHERE id = $4`
+
+	err := r.br.withinTx(ctx, func(ctx context.Context) error {
+		if _, err := r.br.execContext(ctx, query, post.Title, post.Content, post.EditedAt, post.ID); err != nil {
+			return err
+		}
+		return r.br.insertRevision(ctx, postRevision, post.ID, revision)
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
+
+func (r *PostRepository) GetPostRevisions(ctx context.Context, postID int64) ([]domain.Revision, error) {
+	const op = "PostRepository.GetPostRevisions"
+
+	revisions, err := r.br.loadRevisions(ctx, postRevision, postID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revisions, nil
+}
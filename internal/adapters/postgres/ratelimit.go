@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+// RateLimitRepository keeps token buckets in the rate_limits table, so every instance counts against the same buckets.
+type RateLimitRepository struct {
+	br BaseRepository
+}
+
+func NewRateLimitRepository(db *sql.DB) *RateLimitRepository {
+	return &RateLimitRepository{BaseRepository{db}}
+}
+
+// Take locks the bucket rows for the duration of the update, concurrent requests for the same keys queue up on
+// them. Rows are locked in key order, so requests sharing only some of their keys cannot deadlock.
+func (r *RateLimitRepository) Take(ctx context.Context, keys []string, limit domain.RateLimit, cost int, now time.Time) (time.Duration, error) {
+	const op = "RateLimitRepository.Take"
+
+	keys = slices.Sorted(slices.Values(keys))
+	var retryAfter time.Duration
+	err := r.br.withinTx(ctx, func(ctx context.Context) error {
+		// a missing bucket starts out full
+		_, err := r.br.execContext(ctx, `
+			INSERT INTO rate_limits(key, tokens, updated_at)
+			SELECT key, $2, $3 FROM unnest($1::text[]) AS key
+			ON CONFLICT (key) DO NOTHING
+		`, pq.Array(keys), float64(limit.Burst), now)
+		if err != nil {
+			return fmt.Errorf("%s: insert buckets: %w", op, err)
+		}
+
+		rows, err := r.br.queryContext(ctx, `
+			SELECT key, tokens, updated_at FROM rate_limits WHERE key = ANY($1) ORDER BY key FOR UPDATE
+		`, pq.Array(keys))
+		if err != nil {
+			return fmt.Errorf("%s: select buckets: %w", op, err)
+		}
+		defer rows.Close()
+
+		stored := make(map[string]domain.Bucket, len(keys))
+		for rows.Next() {
+			var (
+				key    string
+				bucket domain.Bucket
+			)
+			if err := rows.Scan(&key, &bucket.Tokens, &bucket.UpdatedAt); err != nil {
+				return fmt.Errorf("%s: scan bucket: %w", op, err)
+			}
+			stored[key] = bucket
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("%s: rows: %w", op, err)
+		}
+
+		buckets := make([]domain.Bucket, len(keys))
+		for i, key := range keys {
+			buckets[i] = stored[key]
+		}
+		buckets, retryAfter = limit.TakeAll(buckets, cost, now)
+		if retryAfter > 0 {
+			return nil
+		}
+		for i, key := range keys {
+			_, err = r.br.execContext(ctx, `
+				UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1
+			`, key, buckets[i].Tokens, buckets[i].UpdatedAt)
+			if err != nil {
+				return fmt.Errorf("%s: update bucket: %w", op, err)
+			}
+		}
+		return nil
+	})
+
+	return retryAfter, err
+}
+
+func (r *RateLimitRepository) Peek(ctx context.Context, key string) (domain.Bucket, error) {
+	const op = "RateLimitRepository.Peek"
+
+	var bucket domain.Bucket
+	err := r.br.queryRowContext(ctx, `SELECT tokens, updated_at FROM rate_limits WHERE key = $1`, key).
+		Scan(&bucket.Tokens, &bucket.UpdatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return domain.Bucket{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return bucket, nil
+}
+
+func (r *RateLimitRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	const op = "RateLimitRepository.Prune"
+
+	res, err := r.br.execContext(ctx, `DELETE FROM rate_limits WHERE updated_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return res.RowsAffected()
+}
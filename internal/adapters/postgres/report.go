@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/core/service"
+)
+
+// uniqueViolation is the SQLSTATE of a broken unique constraint.
+const uniqueViolation = "23505"
+
+type ReportRepository struct {
+	br BaseRepository
+}
+
+func NewReportRepository(db *sql.DB) *ReportRepository {
+	return &ReportRepository{BaseRepository{db}}
+}
+
+func (r *ReportRepository) SaveReport(ctx context.Context, report *domain.Report) (int64, error) {
+	const op = "ReportRepository.SaveReport"
+
+	var id int64
+	err := r.br.queryRowContext(ctx, `
+		INSERT INTO reports(post_id, comment_id, reporter_id, reason, details, created_at)
+		VALUES($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, nullID(report.Target.PostID), nullID(report.Target.CommentID), report.ReporterID, string(report.Reason), report.Details, report.CreatedAt).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return 0, service.ErrDuplicateReport
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+func (r *ReportRepository) GetReportQueue(ctx context.Context, pagination domain.Pagination) ([]domain.ReportGroup, error) {
+	const op = "ReportRepository.GetReportQueue"
+
+	rows, err := r.br.queryContext(ctx, `
+		SELECT post_id, comment_id, COUNT(*) AS open, MIN(created_at), MAX(created_at) AS last_at
+		FROM reports
+		WHERE resolved_at IS NULL
+		GROUP BY post_id, comment_id
+		ORDER BY open DESC, last_at DESC, post_id, comment_id
+		LIMIT $1 OFFSET $2
+	`, pagination.PageSize, pagination.Offset())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var groups []domain.ReportGroup
+	for rows.Next() {
+		var g domain.ReportGroup
+		var postID, commentID sql.NullInt64
+		if err := rows.Scan(&postID, &commentID, &g.Open, &g.FirstAt, &g.LastAt); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		g.Target = domain.ReportTarget{PostID: postID.Int64, CommentID: commentID.Int64}
+		g.Reasons = make(map[domain.ReportReason]int)
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	index := make(map[domain.ReportTarget]int, len(groups))
+	var postIDs, commentIDs []int64
+	for i, g := range groups {
+		index[g.Target] = i
+		if g.Target.IsComment() {
+			commentIDs = append(commentIDs, g.Target.CommentID)
+		} else {
+			postIDs = append(postIDs, g.Target.PostID)
+		}
+	}
+	if err := r.loadReasons(ctx, groups, index, postIDs, commentIDs); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := r.loadLatestReports(ctx, groups, index, postIDs, commentIDs); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return groups, nil
+}
+
+// openOnTargets selects the open reports on the posts in $1 and the comments in $2.
+const openOnTargets = `resolved_at IS NULL AND (post_id = ANY($1) OR comment_id = ANY($2))`
+
+func (r *ReportRepository) loadReasons(ctx context.Context, groups []domain.ReportGroup, index map[domain.ReportTarget]int, postIDs, commentIDs []int64) error {
+	rows, err := r.br.queryContext(ctx, `
+		SELECT post_id, comment_id, reason, COUNT(*)
+		FROM reports
+		WHERE `+openOnTargets+`
+		GROUP BY post_id, comment_id, reason
+	`, pq.Array(postIDs), pq.Array(commentIDs))
+	if err != nil {
+		return fmt.Errorf("load reasons: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID, commentID sql.NullInt64
+		var reason string
+		var count int
+		if err := rows.Scan(&postID, &commentID, &reason, &count); err != nil {
+			return fmt.Errorf("scan reason: %w", err)
+		}
+		i := index[domain.ReportTarget{PostID: postID.Int64, CommentID: commentID.Int64}]
+		groups[i].Reasons[domain.ReportReason(reason)] = count
+	}
+	return rows.Err()
+}
+
+// loadLatestReports fills in the latest MaxQueuedReports reports of each group.
+func (r *ReportRepository) loadLatestReports(ctx context.Context, groups []domain.ReportGroup, index map[domain.ReportTarget]int, postIDs, commentIDs []int64) error {
+	rows, err := r.br.queryContext(ctx, `
+		SELECT id, post_id, comment_id, reporter_id, reason, details, created_at
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY post_id, comment_id ORDER BY created_at DESC, id DESC) AS n
+			FROM reports
+			WHERE `+openOnTargets+`
+		) latest
+		WHERE n <= $3
+		ORDER BY created_at, id
+	`, pq.Array(postIDs), pq.Array(commentIDs), domain.MaxQueuedReports)
+	if err != nil {
+		return fmt.Errorf("load reports: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var report domain.Report
+		var postID, commentID, reporterID sql.NullInt64
+		var reason string
+		if err := rows.Scan(&report.ID, &postID, &commentID, &reporterID, &reason, &report.Details, &report.CreatedAt); err != nil {
+			return fmt.Errorf("scan report: %w", err)
+		}
+		report.Target = domain.ReportTarget{PostID: postID.Int64, CommentID: commentID.Int64}
+		report.ReporterID = reporterID.Int64
+		report.Reason = domain.ReportReason(reason)
+		g := &groups[index[report.Target]]
+		g.Reports = append(g.Reports, report)
+	}
+	return rows.Err()
+}
+
+func (r *ReportRepository) CountReportedTargets(ctx context.Context) (int64, error) {
+	const op = "ReportRepository.CountReportedTargets"
+
+	var total int64
+	err := r.br.queryRowContext(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT 1 FROM reports WHERE resolved_at IS NULL GROUP BY post_id, comment_id
+		) targets
+	`).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return total, nil
+}
+
+func (r *ReportRepository) ResolveReports(ctx context.Context, target domain.ReportTarget, resolution domain.Resolution) (int64, error) {
+	const op = "ReportRepository.ResolveReports"
+
+	filter, id := "post_id = $1", target.PostID
+	if target.IsComment() {
+		filter, id = "comment_id = $1", target.CommentID
+	}
+	closed, err := r.br.closeReports(ctx, filter, id, resolution)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return closed, nil
+}
+
+// closeReports resolves the open reports matching filter, a condition on the id passed as $1.
+// Deleting a post or comment closes its reports with it, wherever the deletion came from.
+func (r *BaseRepository) closeReports(ctx context.Context, filter string, id int64, resolution domain.Resolution) (int64, error) {
+	res, err := r.execContext(ctx, `
+		UPDATE reports
+		SET resolved_at = $2, resolved_by = $3, resolution = $4, resolution_note = $5
+		WHERE (`+filter+`) AND resolved_at IS NULL
+	`, id, resolution.At, resolution.By, string(resolution.Action), nullString(resolution.Note))
+	if err != nil {
+		return 0, fmt.Errorf("close reports: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// deletionResolution closes reports on content removed by deletion.
+func deletionResolution(deletion domain.Deletion) domain.Resolution {
+	return domain.Resolution{Action: domain.ReportDeleted, By: deletion.By, Note: deletion.Reason, At: deletion.At}
+}
+
+// nullID stores the unset side of a post or comment reference as NULL.
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id > 0}
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+func TestReportRepository_queue(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	reports := NewReportRepository(db)
+	posts := NewPostRepository(db)
+	comments := NewCommentRepository(db)
+
+	// the queue is shared between runs, close what earlier runs left open
+	if _, err := db.ExecContext(ctx, `UPDATE reports SET resolved_at = now(), resolved_by = 'test', resolution = 'dismissed' WHERE resolved_at IS NULL`); err != nil {
+		t.Fatalf("close reports: %v", err)
+	}
+
+	var users []int64
+	for i := 0; i < domain.MaxQueuedReports+2; i++ {
+		var id int64
+		if err := db.QueryRowContext(ctx, `INSERT INTO users (name, avatar_url) VALUES ($1, '') RETURNING id`, fmt.Sprintf("reporter %d", i)).Scan(&id); err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+		users = append(users, id)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	postID, err := posts.SavePost(ctx, &domain.Post{Title: "t", Content: "c", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, users[0])
+	if err != nil {
+		t.Fatalf("save post: %v", err)
+	}
+	commentID, err := comments.SaveComment(ctx, &domain.Comment{PostID: postID, Content: "c", Author: domain.UserData{ID: users[0]}})
+	if err != nil {
+		t.Fatalf("save comment: %v", err)
+	}
+
+	report := func(target domain.ReportTarget, reporter int64, reason domain.ReportReason, at time.Time) {
+		if _, err := reports.SaveReport(ctx, &domain.Report{Target: target, ReporterID: reporter, Reason: reason, CreatedAt: at}); err != nil {
+			t.Fatalf("save report: %v", err)
+		}
+	}
+	comment := domain.ReportTarget{CommentID: commentID}
+	for i, user := range users {
+		reason := domain.ReportSpam
+		if i == 0 {
+			reason = domain.ReportAbuse
+		}
+		report(comment, user, reason, now.Add(time.Duration(i)*time.Second))
+	}
+	report(domain.ReportTarget{PostID: postID}, users[0], domain.ReportSpam, now)
+
+	total, err := reports.CountReportedTargets(ctx)
+	if err != nil || total != 2 {
+		t.Fatalf("expected 2 reported targets, got %d and %v", total, err)
+	}
+	groups, err := reports.GetReportQueue(ctx, domain.Pagination{Page: 1, PageSize: 1})
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("expected a page of one, got %+v and %v", groups, err)
+	}
+	g := groups[0]
+	if g.Target != comment || g.Count() != len(users) || g.Reasons[domain.ReportAbuse] != 1 || g.Reasons[domain.ReportSpam] != len(users)-1 {
+		t.Fatalf("expected the comment first with all of its reports counted, got %+v", g)
+	}
+	if len(g.Reports) != domain.MaxQueuedReports || !g.Reports[len(g.Reports)-1].CreatedAt.Equal(g.LastAt) || !g.FirstAt.Equal(now) {
+		t.Fatalf("expected the latest %d reports, got %d ending %s", domain.MaxQueuedReports, len(g.Reports), g.Reports[len(g.Reports)-1].CreatedAt)
+	}
+
+	groups, err = reports.GetReportQueue(ctx, domain.Pagination{Page: 2, PageSize: 1})
+	if err != nil || len(groups) != 1 || groups[0].Target.PostID != postID || len(groups[0].Reports) != 1 {
+		t.Fatalf("expected the post on the second page, got %+v and %v", groups, err)
+	}
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+// revisionOwner is the revisions column pointing to a post or a comment.
+type revisionOwner string
+
+const (
+	postRevision    revisionOwner = "post_id"
+	commentRevision revisionOwner = "comment_id"
+)
+
+func (r *BaseRepository) insertRevision(ctx context.Context, owner revisionOwner, ownerID int64, revision domain.Revision) error {
+	query := `INSERT INTO revisions (` + string(owner) + `, title, content, edited_by, edited_at)
+	          VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.execContext(ctx, query, ownerID, revision.Title, revision.Content, revision.EditedBy, revision.EditedAt)
+	if err != nil {
+		return fmt.Errorf("insert revision: %w", err)
+	}
+	return nil
+}
+
+// loadRevisions returns the revisions of a post or comment, newest first.
+func (r *BaseRepository) loadRevisions(ctx context.Context, owner revisionOwner, ownerID int64) ([]domain.Revision, error) {
+	query := `SELECT id, title, content, edited_by, edited_at
+	          FROM revisions
+	          WHERE ` + string(owner) + ` = $1
+	          ORDER BY edited_at DESC, id DESC`
+
+	rows, err := r.queryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []domain.Revision
+	for rows.Next() {
+		var rev domain.Revision
+		if err := rows.Scan(&rev.ID, &rev.Title, &rev.Content, &rev.EditedBy, &rev.EditedAt); err != nil {
+			return nil, fmt.Errorf("scan revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate revisions: %w", err)
+	}
+
+	return revisions, nil
+}
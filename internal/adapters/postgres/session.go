@@ -44,8 +44,10 @@ func (r *SessionRepository) Store(ctx context.Context, session domain.Session) e
 				session_hash, 
 				user_id, 
 				created_at, 
-				expires_at
-			) VALUES($1, $2, $3, $4)
+				expires_at,
+				rotated_at,
+				csrf_token
+			) VALUES($1, $2, $3, $4, $5, $6)
 		`)
 		if err != nil {
 			return fmt.Errorf("%s: prepare session insert: %w", op, err)
@@ -57,6 +59,8 @@ func (r *SessionRepository) Store(ctx context.Context, session domain.Session) e
 			userID,
 			session.CreatedAt,
 			session.ExpiresAt,
+			session.RotatedAt,
+			session.CSRFToken,
 		)
 		if err != nil {
 			return fmt.Errorf("%s: session insert: %w", op, err)
@@ -73,22 +77,28 @@ func (r *SessionRepository) GetByHashedToken(ctx context.Context, hashedToken st
 		SELECT 
 			s.id, 
 			s.session_hash, 
+			COALESCE(s.previous_hash, ''),
 			s.created_at, 
 			s.expires_at,
+			s.rotated_at,
+			s.csrf_token,
 			u.id AS user_id,
 			u.name,
 			u.avatar_url
 		FROM sessions s
 		JOIN users u ON s.user_id = u.id
-		WHERE s.session_hash = $1
+		WHERE s.session_hash = $1 OR s.previous_hash = $1
 	`
 
 	var session domain.Session
 	err := r.db.QueryRowContext(ctx, query, hashedToken).Scan(
 		&session.ID,
 		&session.TokenHash,
+		&session.PreviousTokenHash,
 		&session.CreatedAt,
 		&session.ExpiresAt,
+		&session.RotatedAt,
+		&session.CSRFToken,
 		&session.User.ID,
 		&session.User.Name,
 		&session.User.AvatarURL,
@@ -103,6 +113,8 @@ func (r *SessionRepository) GetByHashedToken(ctx context.Context, hashedToken st
 	return &session, nil
 }
 
+// UpdateByToken finds the session like GetByHashedToken, so a token replaced by the last rotation still works
+// during the grace period, and saves what updateFn changed.
 func (r *SessionRepository) UpdateByToken(ctx context.Context, hashedToken string, updateFn func(*domain.Session) (bool, error)) error {
 	const op = "SessionRepository.UpdateByToken"
 
@@ -116,7 +128,8 @@ func (r *SessionRepository) UpdateByToken(ctx context.Context, hashedToken strin
 			u.avatar_url
 		FROM sessions s
 		JOIN users u ON s.user_id = u.id
-		WHERE s.session_hash = $1 FOR UPDATE`
+		WHERE s.session_hash = $1 OR s.previous_hash = $1
+		FOR UPDATE`
 
 		var (
 			id            int64
@@ -169,6 +182,68 @@ func (r *SessionRepository) UpdateByToken(ctx context.Context, hashedToken strin
 	})
 }
 
+// Rotate replaces the token of a session and extends it. The replaced hash is kept as the previous one,
+// ErrSessionNotFound is returned when the session is gone or was rotated concurrently.
+func (r *SessionRepository) Rotate(ctx context.Context, hashedToken, newHashedToken string, rotatedAt, expiresAt time.Time) error {
+	const op = "SessionRepository.Rotate"
+
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE sessions
+		SET previous_hash = session_hash, session_hash = $2, rotated_at = $3, expires_at = $4
+		WHERE session_hash = $1
+	`, hashedToken, newHashedToken, rotatedAt, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: update session: %w", op, err)
+	}
+
+	rotated, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: rows affected: %w", op, err)
+	}
+	if rotated == 0 {
+		return service.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeByHash deletes the session the token belongs to, whether it is the current or the previous one.
+func (r *SessionRepository) RevokeByHash(ctx context.Context, hashedToken string) error {
+	const op = "SessionRepository.RevokeByHash"
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE session_hash = $1 OR previous_hash = $1`, hashedToken)
+	if err != nil {
+		return fmt.Errorf("%s: delete session: %w", op, err)
+	}
+
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: rows affected: %w", op, err)
+	}
+	if revoked == 0 {
+		return service.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeByUser deletes every session of a user and returns how many there were.
+func (r *SessionRepository) RevokeByUser(ctx context.Context, userID int64) (int64, error) {
+	const op = "SessionRepository.RevokeByUser"
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: delete sessions: %w", op, err)
+	}
+
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: rows affected: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
 func runInTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
 	const op = "SessionRepository.runInTx"
 
@@ -191,3 +266,57 @@ func runInTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
 
 	return fmt.Errorf("%s: fn failed: %w", op, err)
 }
+
+func (r *SessionRepository) DeleteExpiredSessions(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "SessionRepository.DeleteExpiredSessions"
+
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM sessions
+		WHERE id IN (
+			SELECT id FROM sessions
+			WHERE expires_at < $1
+			ORDER BY id
+			LIMIT $2
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: delete sessions: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: rows affected: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// DeleteOrphanedUsers removes users nothing refers to any more. A user created together with a session
+// is not visible here before that transaction commits, so it cannot be removed underneath it.
+func (r *SessionRepository) DeleteOrphanedUsers(ctx context.Context, limit int) (int64, error) {
+	const op = "SessionRepository.DeleteOrphanedUsers"
+
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM users
+		WHERE id IN (
+			SELECT u.id FROM users u
+			WHERE NOT EXISTS (SELECT 1 FROM sessions s WHERE s.user_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.user_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.user_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM images i WHERE i.owner_id = u.id)
+			ORDER BY u.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+	`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: delete users: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: rows affected: %w", op, err)
+	}
+
+	return deleted, nil
+}
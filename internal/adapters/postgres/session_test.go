@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+func TestSessionRepository_UpdateByToken_previousToken(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	sessions := NewSessionRepository(db)
+
+	// hashes are unique per run, the database is shared between runs
+	run := time.Now().UnixNano()
+	oldHash, newHash := fmt.Sprintf("old-%d", run), fmt.Sprintf("new-%d", run)
+	now := time.Now().UTC().Truncate(time.Second)
+	session := domain.Session{
+		TokenHash: oldHash,
+		CSRFToken: fmt.Sprintf("csrf-%d", run),
+		User:      domain.UserData{Name: "before"},
+		CreatedAt: now,
+		RotatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	if err := sessions.Store(ctx, session); err != nil {
+		t.Fatalf("store session: %v", err)
+	}
+	if err := sessions.Rotate(ctx, oldHash, newHash, now, now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("rotate session: %v", err)
+	}
+
+	// a request still carrying the replaced token renames the user of the rotated session
+	err := sessions.UpdateByToken(ctx, oldHash, func(s *domain.Session) (bool, error) {
+		s.User.Name = "after"
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	got, err := sessions.GetByHashedToken(ctx, newHash)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if got.User.Name != "after" || got.TokenHash != newHash || !got.ExpiresAt.Equal(now.Add(2*time.Hour)) {
+		t.Fatalf("unexpected session %+v", got)
+	}
+}
@@ -63,3 +63,11 @@ func (r *BaseRepository) queryRowContext(ctx context.Context, query string, args
 	}
 	return r.db.QueryRowContext(ctx, query, args...)
 }
+
+// withinTx runs fn in the transaction carried by ctx, or in a new one when there is none.
+func (r *BaseRepository) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if extractTx(ctx) != nil {
+		return fn(ctx)
+	}
+	return (&Transactor{db: r.db}).WithinTransaction(ctx, fn)
+}
@@ -51,8 +51,6 @@ func NewUserDataProvider(baseURL string, charactersCount int) *UserDataProvider
 		p.availableIDs[i], p.availableIDs[j] = p.availableIDs[j], p.availableIDs[i]
 	})
 
-	// Start background cleanup
-	go p.cleanupExpiredIDs()
 	return p
 }
 
@@ -111,16 +109,29 @@ func (p *UserDataProvider) fetchUser(ctx context.Context, id int) (domain.UserDa
 	}, nil
 }
 
-func (p *UserDataProvider) cleanupExpiredIDs() {
-	for range time.Tick(5 * time.Minute) {
-		p.mu.Lock()
-		now := time.Now()
-		for id, expiry := range p.inUse {
-			if now.After(expiry) {
-				delete(p.inUse, id)
-				p.availableIDs = append(p.availableIDs, id)
-			}
+// CleanupExpiredIDs returns expired character IDs to the pool every five minutes until ctx is done.
+func (p *UserDataProvider) CleanupExpiredIDs(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.releaseExpiredIDs(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *UserDataProvider) releaseExpiredIDs(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, expiry := range p.inUse {
+		if now.After(expiry) {
+			delete(p.inUse, id)
+			p.availableIDs = append(p.availableIDs, id)
 		}
-		p.mu.Unlock()
 	}
 }
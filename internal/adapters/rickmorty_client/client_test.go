@@ -37,3 +37,22 @@ func TestGetUserData_Success_Unique(t *testing.T) {
 		fmt.Println(userdata)
 	}
 }
+
+func TestReleaseExpiredIDs(t *testing.T) {
+	client := NewUserDataProvider("https://rickandmortyapi.com/api", 2)
+	now := time.Now()
+	client.availableIDs = nil
+	client.inUse = map[int]time.Time{
+		1: now.Add(-time.Minute),
+		2: now.Add(time.Minute),
+	}
+
+	client.releaseExpiredIDs(now)
+
+	if len(client.availableIDs) != 1 || client.availableIDs[0] != 1 {
+		t.Fatalf("expected only id 1 to be released, got %v", client.availableIDs)
+	}
+	if _, ok := client.inUse[2]; !ok {
+		t.Fatalf("expected id 2 to stay in use")
+	}
+}
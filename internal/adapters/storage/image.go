@@ -1,74 +1,90 @@
 package storage
 
 import (
-	"bytes"
 	"context"
+	"encoding/xml"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"time"
+
+	"go-hex-forum/internal/core/service"
 )
 
+// imagesBucket holds every image object, objects are named by the hash of their content.
+const imagesBucket = "images"
+
 type ImageStorage struct {
-	baseURL    string
-	client     *http.Client
-	codeLength int64
+	baseURL string
+	client  *http.Client
 }
 
-const (
-	charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-)
-
-var seededRand = rand.New(rand.NewSource(time.Now().UnixNano()))
-
-func NewImageStorage(baseURL string, codeLength int64) *ImageStorage {
+func NewImageStorage(baseURL string) *ImageStorage {
 	return &ImageStorage{
-		baseURL:    baseURL,
-		client:     &http.Client{Timeout: 30 * time.Second},
-		codeLength: codeLength,
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 30 * time.Second},
 	}
 }
 
-func (s *ImageStorage) generateCode() string {
-	b := make([]byte, s.codeLength)
-	for i := range b {
-		b[i] = charset[seededRand.Intn(len(charset))]
+// validKey accepts the hex hashes and variant suffixes generated by the image service,
+// so a key can never escape its bucket.
+func validKey(key string) bool {
+	if key == "" || len(key) > 128 {
+		return false
 	}
-	return string(b)
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
 }
 
-func (s *ImageStorage) UploadImage(ctx context.Context, userID int64, data []byte) (string, error) {
-	code := s.generateCode()
-	bucketPath := fmt.Sprintf("user-%d", userID)
-	objectPath := fmt.Sprintf("%s/%s", bucketPath, code)
+// UploadImage streams size bytes of r into the object named key. Keys are derived from the content,
+// so uploading an existing key again only rewrites identical bytes.
+func (s *ImageStorage) UploadImage(ctx context.Context, key string, r io.Reader, size int64) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	objectPath := fmt.Sprintf("%s/%s", imagesBucket, key)
 	base := s.baseURL
 
-	put := func(path string, body io.Reader) (*http.Response, error) {
+	put := func(path string, body io.Reader, size int64) (*http.Response, error) {
 		req, err := http.NewRequestWithContext(ctx, "PUT", base+"/"+path, body)
 		if err != nil {
 			return nil, err
 		}
+		if body != nil && size >= 0 {
+			req.ContentLength = size
+		}
 		return s.client.Do(req)
 	}
 
-	resp, err := put(objectPath, bytes.NewReader(data))
+	resp, err := put(objectPath, r, size)
 	if err != nil {
 		return "", fmt.Errorf("upload failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
-		bResp, bErr := put(bucketPath, nil)
+		bResp, bErr := put(imagesBucket, nil, 0)
 		if bErr != nil {
-			return "", fmt.Errorf("failed to create bucket %q: %w", bucketPath, bErr)
+			return "", fmt.Errorf("failed to create bucket %q: %w", imagesBucket, bErr)
 		}
 		bResp.Body.Close()
 		if bResp.StatusCode != http.StatusOK {
 			return "", fmt.Errorf("bucket creation error: %d", bResp.StatusCode)
 		}
 
-		resp, err = put(objectPath, bytes.NewReader(data))
+		// the first attempt consumed the body
+		seeker, ok := r.(io.Seeker)
+		if !ok {
+			return "", fmt.Errorf("retry upload failed: body cannot be rewound")
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return "", fmt.Errorf("retry upload failed: %w", err)
+		}
+		resp, err = put(objectPath, r, size)
 		if err != nil {
 			return "", fmt.Errorf("retry upload failed: %w", err)
 		}
@@ -79,9 +95,76 @@ func (s *ImageStorage) UploadImage(ctx context.Context, userID int64, data []byt
 		return "", fmt.Errorf("storage error: %d", resp.StatusCode)
 	}
 
-	return s.GetImageURL(userID, code), nil
+	return s.GetImageURL(key), nil
+}
+
+func (s *ImageStorage) GetImageURL(key string) string {
+	return fmt.Sprintf("%s/%s/%s", s.baseURL, imagesBucket, key)
+}
+
+func (s *ImageStorage) DeleteImage(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.GetImageURL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("storage error: %d", resp.StatusCode)
+	}
+}
+
+// listBucketResult is the S3-style listing returned for GET /{bucket}.
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
 }
 
-func (s *ImageStorage) GetImageURL(userID int64, code string) string {
-	return fmt.Sprintf("%s/user-%d/%s", s.baseURL, userID, code)
+func (s *ImageStorage) ListImages(ctx context.Context) ([]service.ImageObject, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/"+imagesBucket, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("storage error: %d", resp.StatusCode)
+	}
+
+	var listing listBucketResult
+	if err := xml.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("decode listing: %w", err)
+	}
+
+	objects := make([]service.ImageObject, 0, len(listing.Contents))
+	for _, obj := range listing.Contents {
+		objects = append(objects, service.ImageObject{
+			Key:        obj.Key,
+			Size:       obj.Size,
+			ModifiedAt: obj.LastModified,
+		})
+	}
+
+	return objects, nil
 }
@@ -2,14 +2,17 @@ package storage
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 )
 
 func TestUploadImage_Success(t *testing.T) {
-	var codeLen int = 3
-	service := NewImageStorage("http://localhost:6969", int64(codeLen))
-	publicURL, err := service.UploadImage(context.Background(), 0, []byte("X"))
+	service := NewImageStorage("http://localhost:6969")
+	publicURL, err := service.UploadImage(context.Background(), "abc123", strings.NewReader("X"), 1)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -18,28 +21,63 @@ func TestUploadImage_Success(t *testing.T) {
 	}
 }
 
+func TestUploadImage_createsBucket(t *testing.T) {
+	var bucket bool
+	var stored string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/images":
+			bucket = true
+		case !bucket:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			if r.ContentLength != 5 {
+				t.Errorf("expected content length 5, got %d", r.ContentLength)
+			}
+			body, _ := io.ReadAll(r.Body)
+			stored = string(body)
+		}
+	}))
+	defer server.Close()
+
+	service := NewImageStorage(server.URL)
+	if _, err := service.UploadImage(context.Background(), "abc123", strings.NewReader("hello"), 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// the body is rewound for the retry after the bucket was created
+	if stored != "hello" {
+		t.Fatalf("expected stored content %q, got %q", "hello", stored)
+	}
+}
+
 func TestUploadImage_Fail(t *testing.T) {
-	var codeLen int = 3
-	service := NewImageStorage("http://localhost:0001", int64(codeLen))
-	_, err := service.UploadImage(context.Background(), 0, []byte("X"))
+	service := NewImageStorage("http://localhost:0001")
+	_, err := service.UploadImage(context.Background(), "abc123", strings.NewReader("X"), 1)
 	if err == nil {
 		t.Fatalf("expected error, got no errors")
 	}
 }
 
+func TestUploadImage_Fail_invalidKey(t *testing.T) {
+	service := NewImageStorage("http://localhost:6969")
+	for _, key := range []string{"", "../secret", "a/b", "a b"} {
+		if _, err := service.UploadImage(context.Background(), key, strings.NewReader("X"), 1); err == nil {
+			t.Fatalf("expected error for key %q, got no errors", key)
+		}
+	}
+}
+
 func TestGetImageURL_Success(t *testing.T) {
-	var codeLen int = 3
-	service := NewImageStorage("http://localhost:6969", int64(codeLen))
-	publicURL := service.GetImageURL(0, "wKj")
-	if _, err := url.Parse(publicURL); err != nil {
-		t.Fatalf("expected no url parsing err , got %v", err)
+	service := NewImageStorage("http://localhost:6969")
+	publicURL := service.GetImageURL("wKj")
+	if publicURL != "http://localhost:6969/images/wKj" {
+		t.Fatalf("unexpected public url %q", publicURL)
 	}
 }
 
 func TestGetImageURL_Fail(t *testing.T) {
-	var codeLen int = 3
-	service := NewImageStorage("http://localhost:6969", int64(codeLen))
-	publicURL := service.GetImageURL(0, "___")
+	service := NewImageStorage("http://localhost:6969")
+	publicURL := service.GetImageURL("___")
 	if _, err := url.Parse(publicURL); err != nil {
 		t.Fatalf("expected no url parsing err , got %v", err)
 	}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-hex-forum/internal/core/service"
+)
+
+// LocalImageStorage keeps images on the local filesystem and serves them under publicPrefix.
+type LocalImageStorage struct {
+	dir          string
+	publicPrefix string
+}
+
+func NewLocalImageStorage(dir, publicPrefix string) *LocalImageStorage {
+	return &LocalImageStorage{
+		dir:          dir,
+		publicPrefix: strings.TrimSuffix(publicPrefix, "/"),
+	}
+}
+
+// UploadImage streams size bytes of r into the object named key. An existing object is kept as is,
+// since keys are derived from the content.
+func (s *LocalImageStorage) UploadImage(ctx context.Context, key string, r io.Reader, size int64) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	bucketDir := filepath.Join(s.dir, imagesBucket)
+	if err := os.MkdirAll(bucketDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create bucket %q: %w", bucketDir, err)
+	}
+
+	name := filepath.Join(bucketDir, key)
+	if _, err := os.Stat(name); err == nil {
+		// refresh the modification time so the sweeper treats the reused object as new
+		now := time.Now()
+		if err := os.Chtimes(name, now, now); err != nil {
+			return "", fmt.Errorf("upload failed: %w", err)
+		}
+		return s.GetImageURL(key), nil
+	}
+	if err := writeFileAtomic(name, r, size); err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+
+	return s.GetImageURL(key), nil
+}
+
+func (s *LocalImageStorage) GetImageURL(key string) string {
+	return fmt.Sprintf("%s/%s/%s", s.publicPrefix, imagesBucket, key)
+}
+
+func (s *LocalImageStorage) DeleteImage(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	err := os.Remove(filepath.Join(s.dir, imagesBucket, key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalImageStorage) ListImages(ctx context.Context) ([]service.ImageObject, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, imagesBucket))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list failed: %w", err)
+	}
+
+	objects := make([]service.ImageObject, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !validKey(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list failed: %w", err)
+		}
+		objects = append(objects, service.ImageObject{
+			Key:        entry.Name(),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime(),
+		})
+	}
+
+	return objects, nil
+}
+
+// Handler serves stored objects, it is meant to be mounted on publicPrefix + "/".
+func (s *LocalImageStorage) Handler() http.Handler {
+	fileServer := http.FileServer(objectsOnlyFS{http.Dir(s.dir)})
+	return http.StripPrefix(s.publicPrefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		fileServer.ServeHTTP(w, r)
+	}))
+}
+
+// writeFileAtomic copies r to a hidden temporary file and renames it into place,
+// so a concurrent reader never sees a partially written object. A negative size means unknown.
+func writeFileAtomic(name string, r io.Reader, size int64) error {
+	f, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	n, err := io.Copy(f, r)
+	if err == nil && size >= 0 && n != size {
+		err = fmt.Errorf("short write: %d of %d bytes", n, size)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// objectsOnlyFS hides directories and in-progress uploads so the file server only serves complete objects.
+type objectsOnlyFS struct {
+	fs http.FileSystem
+}
+
+func (o objectsOnlyFS) Open(name string) (http.File, error) {
+	name = path.Clean(name)
+	if strings.HasPrefix(path.Base(name), ".") {
+		return nil, fs.ErrNotExist
+	}
+
+	f, err := o.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fs.ErrNotExist
+	}
+
+	return f, nil
+}
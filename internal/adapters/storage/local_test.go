@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalUploadImage_Success(t *testing.T) {
+	dir := t.TempDir()
+	service := NewLocalImageStorage(dir, "/media")
+
+	publicURL, err := service.UploadImage(context.Background(), "abc123", strings.NewReader("X"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if publicURL != "/media/images/abc123" {
+		t.Fatalf("unexpected public url %q", publicURL)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "images", "abc123"))
+	if err != nil {
+		t.Fatalf("expected stored object, got %v", err)
+	}
+	if string(data) != "X" {
+		t.Fatalf("expected stored content %q, got %q", "X", data)
+	}
+
+	// the same key again keeps the existing object
+	again, err := service.UploadImage(context.Background(), "abc123", strings.NewReader("X"), 1)
+	if err != nil || again != publicURL {
+		t.Fatalf("expected %q, got %q, %v", publicURL, again, err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "images"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected a single stored object, got %d, %v", len(entries), err)
+	}
+}
+
+func TestLocalUploadImage_Fail(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	service := NewLocalImageStorage(file, "/media")
+
+	if _, err := service.UploadImage(context.Background(), "abc123", strings.NewReader("X"), 1); err == nil {
+		t.Fatalf("expected error, got no errors")
+	}
+	if _, err := NewLocalImageStorage(t.TempDir(), "/media").UploadImage(context.Background(), "../abc", strings.NewReader("X"), 1); err == nil {
+		t.Fatalf("expected error for an escaping key, got no errors")
+	}
+}
+
+func TestLocalDeleteAndListImages(t *testing.T) {
+	dir := t.TempDir()
+	service := NewLocalImageStorage(dir, "/media")
+	ctx := context.Background()
+
+	for _, key := range []string{"abc", "abc-thumb"} {
+		if _, err := service.UploadImage(ctx, key, strings.NewReader(key), int64(len(key))); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	// leftovers of interrupted writes are not objects
+	if err := os.WriteFile(filepath.Join(dir, "images", ".tmp-1"), nil, 0o644); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	objects, err := service.ListImages(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(objects) != 2 || objects[0].Key != "abc" || objects[0].Size != 3 || objects[0].ModifiedAt.IsZero() {
+		t.Fatalf("unexpected objects %+v", objects)
+	}
+
+	if err := service.DeleteImage(ctx, "abc"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := service.DeleteImage(ctx, "abc"); err != nil {
+		t.Fatalf("expected deleting a missing object to succeed, got %v", err)
+	}
+	if err := service.DeleteImage(ctx, "../abc"); err == nil {
+		t.Fatalf("expected error for an escaping key, got no errors")
+	}
+
+	objects, err = service.ListImages(ctx)
+	if err != nil || len(objects) != 1 || objects[0].Key != "abc-thumb" {
+		t.Fatalf("expected only abc-thumb, got %+v, %v", objects, err)
+	}
+}
+
+func TestLocalHandler(t *testing.T) {
+	dir := t.TempDir()
+	service := NewLocalImageStorage(dir, "/media")
+	publicURL, err := service.UploadImage(context.Background(), "abc123", strings.NewReader("X"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	handler := service.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, publicURL, nil))
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || string(body) != "X" {
+		t.Fatalf("expected object to be served, got %d %q", rec.Code, body)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "images", ".upload-1"), []byte("partial"), 0o644); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, target := range []string{"/media/", "/media/images/", "/media/../local.go", "/media/images/.upload-1"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code == http.StatusOK {
+			t.Fatalf("expected %s not to be served, got %d", target, rec.Code)
+		}
+	}
+}
@@ -3,15 +3,22 @@ package apiserver
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"go-hex-forum/config"
+	"go-hex-forum/internal/adapters/challenge"
+	"go-hex-forum/internal/adapters/memory"
 	"go-hex-forum/internal/adapters/postgres"
 	rickmorty "go-hex-forum/internal/adapters/rickmorty_client"
 	"go-hex-forum/internal/adapters/storage"
@@ -32,8 +39,139 @@ func NewAPIServer(config *config.Config, db *sql.DB, logger *slog.Logger) *APISe
 	return &APIServer{config, db, logger}
 }
 
+// NewLifetimePolicy builds the post lifetime policy from its configuration.
+func NewLifetimePolicy(cfg config.PostConfig) domain.LifetimePolicy {
+	return domain.LifetimePolicy{
+		NoCommentsTTL: cfg.NoCommentsTTL,
+		CommentTTL:    cfg.CommentTTL,
+		MaxLifetime:   cfg.MaxLifetime,
+		BumpLimit:     int(cfg.BumpLimit),
+	}
+}
+
+// NewDeletionPolicy builds the policy deciding who may delete posts and comments from its configuration.
+func NewDeletionPolicy(cfg config.PostConfig) domain.DeletionPolicy {
+	return domain.DeletionPolicy{AuthorWindow: cfg.DeleteWindow}
+}
+
+// NewEditPolicy builds the policy deciding who may edit posts and comments from its configuration.
+func NewEditPolicy(cfg config.PostConfig) domain.EditPolicy {
+	return domain.EditPolicy{AuthorWindow: cfg.EditWindow}
+}
+
+// NewImagePolicy builds the limits of uploaded images from their configuration.
+func NewImagePolicy(cfg config.Images) domain.ImagePolicy {
+	return domain.ImagePolicy{
+		MaxBytes:        cfg.MaxBytes,
+		MaxWidth:        cfg.MaxWidth,
+		MaxHeight:       cfg.MaxHeight,
+		AllowedTypes:    cfg.AllowedTypes,
+		ThumbnailSize:   cfg.ThumbnailSize,
+		PreviewSize:     cfg.PreviewSize,
+		MaxAttachments:  cfg.MaxAttachments,
+		MaxDecodeMemory: cfg.MaxDecodeMemory,
+	}
+}
+
+// NewRateLimits builds the token buckets of every limited action from their configuration.
+func NewRateLimits(cfg config.RateLimit) map[domain.RateAction]domain.RateLimit {
+	limit := func(rule config.RateRule) domain.RateLimit {
+		return domain.RateLimit{Burst: rule.Burst, Window: rule.Window}
+	}
+	return map[domain.RateAction]domain.RateLimit{
+		domain.RateActionPost:    limit(cfg.Posts),
+		domain.RateActionComment: limit(cfg.Comments),
+		domain.RateActionUpload:  limit(cfg.Uploads),
+		domain.RateActionSession: limit(cfg.Sessions),
+		domain.RateActionLogin:   limit(cfg.Logins),
+		domain.RateActionReport:  limit(cfg.Reports),
+		domain.RateActionStrike:  limit(cfg.Strikes),
+	}
+}
+
+// NewRateLimitStore builds the configured bucket store.
+func NewRateLimitStore(cfg config.RateLimit, db *sql.DB) (service.RateLimitStore, error) {
+	switch cfg.Driver {
+	case "memory", "":
+		return memory.NewRateLimitStore(), nil
+	case "postgres":
+		return postgres.NewRateLimitRepository(db), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit driver %q", cfg.Driver)
+	}
+}
+
+// NewModeratorAccounts parses the configured staff accounts, "login:role:hash" each.
+func NewModeratorAccounts(entries []string) ([]domain.ModeratorAccount, error) {
+	accounts := make([]domain.ModeratorAccount, 0, len(entries))
+	for _, entry := range entries {
+		login, rest, _ := strings.Cut(entry, ":")
+		role, hash, _ := strings.Cut(rest, ":")
+		account := domain.ModeratorAccount{Login: login, Role: domain.Role(role), PasswordHash: hash}
+		if login == "" || hash == "" || !account.Role.Valid() {
+			return nil, fmt.Errorf("invalid moderator account %q, expected login:moderator|admin:hash", login)
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// NewChallengeProvider builds the configured challenge, "none" disables challenges and returns nil.
+func NewChallengeProvider(cfg config.Challenge) (service.ChallengeProvider, error) {
+	switch cfg.Kind {
+	case "pow", "":
+		return challenge.NewProofOfWork(cfg.PowBits, cfg.PowStepBits), nil
+	case "captcha":
+		return challenge.NewCaptcha(cfg.CaptchaLength), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown challenge kind %q", cfg.Kind)
+	}
+}
+
+// defaultAttachmentSlots is how many image inputs the forms offer when the number of attachments is unlimited.
+const defaultAttachmentSlots = 4
+
+// formOverhead is what a form may carry besides its images: text fields, alt texts and part headers.
+const formOverhead = 1 << 20
+
+// bodyLimit caps request bodies at the largest form the image limits allow, every image at its size limit
+// plus the text fields. Without a size or attachment limit there is no bound to derive, bodies are not capped.
+func bodyLimit(cfg config.Images) int64 {
+	if cfg.MaxBytes <= 0 || cfg.MaxAttachments <= 0 {
+		return 0
+	}
+	return cfg.MaxBytes*int64(cfg.MaxAttachments) + formOverhead
+}
+
+// attachmentSlots numbers the image inputs of the post and comment forms, from 1 to the attachment limit.
+func attachmentSlots(limit int) []int {
+	if limit <= 0 {
+		limit = defaultAttachmentSlots
+	}
+	slots := make([]int, limit)
+	for i := range slots {
+		slots[i] = i + 1
+	}
+	return slots
+}
+
+// NewImageStorage builds the configured image backend. The local backend also returns the handler
+// serving its objects under /media/, the http backend serves them itself and returns a nil handler.
+func NewImageStorage(cfg config.Storage) (service.ImageStorage, http.Handler, error) {
+	switch cfg.Driver {
+	case "local":
+		local := storage.NewLocalImageStorage(cfg.LocalDir, "/media")
+		return local, local.Handler(), nil
+	case "http", "":
+		return storage.NewImageStorage(cfg.MakeAddressString()), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
 func (s *APIServer) Run() error {
-	ctx := context.Background()
 	if s.cfg == nil {
 		return fmt.Errorf("cannot start, config is nil")
 	}
@@ -44,14 +182,33 @@ func (s *APIServer) Run() error {
 		return fmt.Errorf("cannot start, logger is nil")
 	}
 
+	// SIGINT/SIGTERM start a graceful shutdown
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Root context shared by all background workers, cancelled once the server has drained
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var workers sync.WaitGroup
+	startWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
+	}
+
 	// Loading templates for frontend part of the application
 	tpl := template.New("post.html").Funcs(template.FuncMap{
-		"commentArgs": func(comment *domain.Comment, allComments []*domain.Comment, postID int64, depth int) map[string]interface{} {
+		"commentArgs": func(comment *domain.Comment, allComments []*domain.Comment, postID int64, depth int, csrfToken string, challenges bool, actions map[int64]handlers.CommentActions) map[string]interface{} {
 			return map[string]interface{}{
 				"Comment":     comment,
 				"AllComments": allComments,
 				"PostID":      postID,
 				"Depth":       depth,
+				"CSRFToken":   csrfToken,
+				"Challenges":  challenges,
+				"Actions":     actions,
 			}
 		},
 		"formatTime": utils.FormatTime,
@@ -65,9 +222,15 @@ func (s *APIServer) Run() error {
 			return replies
 		},
 		"add": func(a, b int) int { return a + b },
+		"attachmentSlots": func() []int {
+			return attachmentSlots(s.cfg.Images.MaxAttachments)
+		},
 		"nl2br": func(text string) template.HTML {
 			return template.HTML(strings.ReplaceAll(template.HTMLEscapeString(text), "\n", "<br>"))
 		},
+		"reportReasons": func() []domain.ReportReason {
+			return domain.ReportReasons
+		},
 	})
 
 	tpl, err := tpl.ParseGlob(filepath.Join("web", "templates", "*.html"))
@@ -79,8 +242,10 @@ func (s *APIServer) Run() error {
 	// separating frontend handlers from api handlers
 	frontendMux := http.NewServeMux()
 	apiMux := http.NewServeMux()
+	// assets and health checks are served by publicMux before the session middleware is reached
+	publicMux := http.NewServeMux()
 
-	frontendMux.Handle("/static/",
+	publicMux.Handle("/static/",
 		http.StripPrefix("/static/",
 			http.FileServer(http.Dir(filepath.Join("web", "static"))),
 		),
@@ -93,44 +258,162 @@ func (s *APIServer) Run() error {
 	// Transactor
 	transactor := postgres.NewTransactor(s.db)
 
-	// Third Party APIs
+	// Third Party APIs and storage
+	ImageStorage, mediaHandler, err := NewImageStorage(s.cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("cannot start, %w", err)
+	}
+	if mediaHandler != nil {
+		publicMux.Handle("/media/", mediaHandler)
+	}
+	ImageRepository := postgres.NewImageRepository(s.db)
+	ImageService := service.NewImageService(ImageStorage, ImageRepository, NewImagePolicy(s.cfg.Images))
+	ImageSweeper := service.NewImageSweeper(ImageRepository, ImageStorage, s.cfg.Images.GCGrace, s.cfg.Images.GCInterval, time.Now, s.logger)
+	startWorker(ImageSweeper.Run)
+
 	UserdataProvider := rickmorty.NewUserDataProvider("https://rickandmortyapi.com/api", 826)
-	ImageStorage := storage.NewImageStorage(s.cfg.Storage.MakeAddressString(), s.cfg.Storage.MaxNameLength)
+	startWorker(UserdataProvider.CleanupExpiredIDs)
+
+	// Rate limits
+	RateLimitStore, err := NewRateLimitStore(s.cfg.RateLimit, s.db)
+	if err != nil {
+		return fmt.Errorf("cannot start, %w", err)
+	}
+	RateLimiter := service.NewRateLimiter(RateLimitStore, NewRateLimits(s.cfg.RateLimit), time.Now, s.logger)
+	startWorker(RateLimiter.Run)
+	uploadLimitMW := middleware.NewUploadRateLimitMW(RateLimiter)
+
+	// Challenges
+	ChallengeProvider, err := NewChallengeProvider(s.cfg.Challenge)
+	if err != nil {
+		return fmt.Errorf("cannot start, %w", err)
+	}
+	ChallengeService := service.NewChallengeService(postgres.NewChallengeRepository(s.db), ChallengeProvider, RateLimiter, s.cfg.Challenge, time.Now, s.logger)
+	startWorker(ChallengeService.Run)
+	ChallengeHandler := handlers.NewChallengeHandler(ChallengeService)
+	ChallengeHandler.RegisterEndpoints(apiMux)
 
 	// Session
 	SessionRepository := postgres.NewSessionRepository(s.db)
 	SessionService := service.NewSessionService(SessionRepository, time.Now, UserdataProvider, s.cfg.SessionConfig)
+	SessionCleaner := service.NewSessionCleaner(SessionRepository, s.cfg.SessionConfig.CleanupInterval, s.cfg.SessionConfig.CleanupBatchSize, time.Now, s.logger)
+	startWorker(SessionCleaner.Run)
 	SessionHandler := handlers.NewSessionHandler(tpl, SessionService, s.logger)
-	SessionHandler.RegisterEndpoints(apiMux)
+	// sessions are created on the first write, their creation is limited per address by writeLimits before that
+	SessionHandler.RegisterEndpoints(apiMux, SessionHandler.RequireSession)
+	guardUpload := middleware.NewMiddlewareChain(SessionHandler.RequireSession, uploadLimitMW)
+	guardReport := SessionHandler.RequireSession
+
+	// writes are counted against their limits before the CSRF check reads the body
+	sessionLimitMW := middleware.NewSessionRateLimitMW(RateLimiter)
+	limitPost := middleware.NewMiddlewareChain(sessionLimitMW, middleware.NewRateLimitMW(RateLimiter, domain.RateActionPost))
+	limitComment := middleware.NewMiddlewareChain(sessionLimitMW, middleware.NewRateLimitMW(RateLimiter, domain.RateActionComment))
+	limitReport := middleware.NewMiddlewareChain(sessionLimitMW, middleware.NewRateLimitMW(RateLimiter, domain.RateActionReport))
+	writeLimits := middleware.NewRouteMW(map[string]middleware.Middleware{
+		"POST /post":                                 limitPost,
+		"POST /api/post":                             limitPost,
+		"POST /post/{id}/comment":                    limitComment,
+		"POST /api/post/{id}/comment":                limitComment,
+		"POST /post/{id}/report":                     limitReport,
+		"POST /post/{id}/comment/{commentID}/report": limitReport,
+		"POST /api/report":                           limitReport,
+		"POST /api/username":                         sessionLimitMW,
+	})
 
 	// Post
+	LifetimePolicy := NewLifetimePolicy(s.cfg.PostConfig)
+	DeletionPolicy := NewDeletionPolicy(s.cfg.PostConfig)
+	EditPolicy := NewEditPolicy(s.cfg.PostConfig)
 	PostRepository := postgres.NewPostRepository(s.db)
-	PostService := service.NewPostService(PostRepository, ImageStorage)
+	PostService := service.NewPostService(PostRepository, ImageService, LifetimePolicy, DeletionPolicy, EditPolicy, time.Now)
 
-	go PostService.ArchiveExpiredPostsWorker(ctx)
-	PostHandler := handlers.NewPostHandler(PostService)
-	PostHandler.RegisterEndpoints(apiMux)
+	ArchiveWorker := service.NewArchiveWorker(PostService, s.cfg.PostConfig.ArchiveInterval, s.logger)
+	startWorker(ArchiveWorker.Run)
+	PostHandler := handlers.NewPostHandler(PostService, ChallengeService)
+	PostHandler.RegisterEndpoints(apiMux, guardUpload)
 
 	// Comment
 	CommentRepository := postgres.NewCommentRepository(s.db)
-	CommentService := service.NewCommentService(transactor, CommentRepository, PostRepository, ImageStorage)
-	CommentHandler := handlers.NewCommentHandler(CommentService)
-	CommentHandler.RegisterEndpoints(apiMux)
+	CommentService := service.NewCommentService(transactor, CommentRepository, PostRepository, ImageService, LifetimePolicy, DeletionPolicy, EditPolicy, time.Now)
+	CommentHandler := handlers.NewCommentHandler(CommentService, ChallengeService)
+	CommentHandler.RegisterEndpoints(apiMux, guardUpload)
+
+	// Report
+	ReportService := service.NewReportService(transactor, postgres.NewReportRepository(s.db), PostRepository, CommentRepository, PostService, CommentService, time.Now)
+	ReportHandler := handlers.NewReportHandler(ReportService)
+	ReportHandler.RegisterEndpoints(apiMux, guardReport)
+
+	// Admin
+	AdminHandler := handlers.NewAdminHandler(ArchiveWorker, SessionService)
+	AdminHandler.RegisterEndpoints(apiMux, middleware.NewAdminTokenMW(s.cfg.Admin.Token))
+
+	// Moderation
+	ModeratorAccounts, err := NewModeratorAccounts(s.cfg.Admin.Moderators)
+	if err != nil {
+		return fmt.Errorf("cannot start, %w", err)
+	}
+	ModeratorService := service.NewModeratorService(postgres.NewModeratorSessionRepository(s.db), ModeratorAccounts, s.cfg.Admin.SessionTTL, time.Now)
+	ModerationHandler := handlers.NewModerationHandler(tpl, ModeratorService, PostService, CommentService, ReportService, SessionService, ArchiveWorker, s.logger)
+	ModerationHandler.RegisterEndpoints(frontendMux, middleware.NewRateLimitMW(RateLimiter, domain.RateActionLogin))
 
 	// rendering pages and calls on /api
-	frontendHandler := handlers.NewFrontendHandler(PostService, SessionService, CommentService, tpl, s.logger)
-	frontendHandler.RegisterFrontendEndpoints(frontendMux)
+	frontendHandler := handlers.NewFrontendHandler(PostService, SessionService, CommentService, ChallengeService, ReportService, tpl, s.logger)
+	frontendHandler.RegisterFrontendEndpoints(frontendMux, guardUpload, guardUpload, guardReport)
+
+	// Health
+	HealthHandler := handlers.NewHealthHandler(s.db)
+	HealthHandler.RegisterEndpoints(publicMux)
 
 	// Middlewares
-	SessionMiddleware := SessionHandler.WithSessionToken(int64(s.cfg.SessionConfig.DefaultTTL.Seconds()))
-	TimeoutMW := middleware.NewTimeoutContextMW(15)
-	MWChain := middleware.NewMiddlewareChain(middleware.RecoveryMW, TimeoutMW, SessionMiddleware, SessionHandler.RequireValidSession)
+	publicMux.Handle("/", middleware.NewMiddlewareChain(
+		middleware.NewClientIPMW(s.cfg.RateLimit.TrustProxy),
+		// bodies are capped before the session, CSRF and upload middlewares parse any form
+		middleware.NewBodyLimitMW(bodyLimit(s.cfg.Images)),
+		SessionHandler.WithSessionToken,
+		writeLimits,
+		middleware.NewCSRFMW(),
+	)(router))
+	TimeoutMW := middleware.NewTimeoutContextMW(int(s.cfg.Server.RequestTimeout.Seconds()))
+	MWChain := middleware.NewMiddlewareChain(middleware.RecoveryMW, TimeoutMW)
 
 	serverAddress := fmt.Sprintf("%s:%s", s.cfg.Server.Address, s.cfg.Server.Port)
-	s.logger.Info("starting server", slog.String("host", serverAddress))
 	httpServer := http.Server{
-		Addr:    serverAddress,
-		Handler: MWChain(router),
+		Addr:              serverAddress,
+		Handler:           MWChain(publicMux),
+		ReadTimeout:       s.cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: s.cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      s.cfg.Server.WriteTimeout,
+		IdleTimeout:       s.cfg.Server.IdleTimeout,
 	}
-	return httpServer.ListenAndServe()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		s.logger.Info("starting server", slog.String("host", serverAddress))
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	var runErr error
+	select {
+	case err := <-serverErr:
+		runErr = err
+		s.logger.Error("server failed", "error", err)
+	case <-signalCtx.Done():
+		s.logger.Info("shutdown signal received, draining requests", "timeout", s.cfg.Server.ShutdownTimeout.String())
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), s.cfg.Server.ShutdownTimeout)
+		defer cancelShutdown()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			runErr = fmt.Errorf("shutdown server: %w", err)
+			s.logger.Error("graceful shutdown failed", "error", err)
+		}
+	}
+
+	// stop background workers before the caller closes the database
+	cancel()
+	workers.Wait()
+	s.logger.Info("server stopped")
+
+	return runErr
 }
@@ -0,0 +1,30 @@
+package domain
+
+import "io"
+
+// MaxAltTextLength caps the description of an attachment.
+const MaxAltTextLength = 200
+
+// Attachment is an image attached to a post or comment, attachments are shown in Position order.
+type Attachment struct {
+	ID       int64
+	Image    Image
+	AltText  string
+	Position int
+}
+
+// AttachmentUpload is an image file sent along with a new post or comment.
+type AttachmentUpload struct {
+	File io.Reader
+	// Size is the length announced by the client, -1 when it is unknown.
+	Size    int64
+	AltText string
+}
+
+// cover returns the image of the first attachment, or the zero Image when there are none.
+func cover(attachments []Attachment) Image {
+	if len(attachments) == 0 {
+		return Image{}
+	}
+	return attachments[0].Image
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// ChallengeKind names the puzzle a challenge asks for.
+type ChallengeKind string
+
+const (
+	// ChallengeProofOfWork asks for a nonce whose SHA-256 hash together with the seed starts with Difficulty zero bits.
+	ChallengeProofOfWork ChallengeKind = "pow"
+	// ChallengeCaptcha asks for the Difficulty digits shown in Image.
+	ChallengeCaptcha ChallengeKind = "captcha"
+)
+
+// Challenge is a puzzle forms have to solve before content is accepted. It can be answered once.
+type Challenge struct {
+	ID         string
+	Kind       ChallengeKind
+	Difficulty int
+	Seed       string
+	// Answer is a hash of the expected captcha text, it is never sent to clients
+	Answer string
+	// Image is the rendered captcha, only set when the challenge is issued
+	Image     []byte
+	ExpiresAt time.Time
+}
+
+func (c Challenge) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
@@ -7,7 +7,64 @@ type Comment struct {
 	PostID          int64
 	ParentCommentID *int64
 	Content         string
-	ImagePath       string // S3 path для изображений комментария
+	Attachments     []Attachment
 	CreatedAt       time.Time
 	Author          UserData
+	// EditedAt is when the comment was last edited, zero while it never was
+	EditedAt time.Time
+	// Deleted is set once the comment was removed, it is then shown as a tombstone above its replies
+	Deleted *Deletion
+}
+
+// Image returns the first attached image.
+func (c Comment) Image() Image {
+	return cover(c.Attachments)
+}
+
+func (c Comment) IsEdited() bool {
+	return !c.EditedAt.IsZero()
+}
+
+func (c Comment) IsDeleted() bool {
+	return c.Deleted != nil
+}
+
+// Tombstone returns the comment with everything but its place in the thread removed.
+func (c Comment) Tombstone() *Comment {
+	return &Comment{
+		ID:              c.ID,
+		PostID:          c.PostID,
+		ParentCommentID: c.ParentCommentID,
+		CreatedAt:       c.CreatedAt,
+		Deleted:         &Deletion{At: c.Deleted.At},
+	}
+}
+
+// VisibleComments prepares a chronologically ordered thread for readers. Deleted comments with replies still
+// shown are replaced by tombstones, so the replies keep their place, deleted comments without any are dropped.
+func VisibleComments(comments []*Comment) []*Comment {
+	// replies come after their parents, walking backwards settles every reply before its parent
+	keep := make(map[int64]bool, len(comments))
+	hasReplies := make(map[int64]bool)
+	for i := len(comments) - 1; i >= 0; i-- {
+		c := comments[i]
+		if !c.IsDeleted() || hasReplies[c.ID] {
+			keep[c.ID] = true
+			if c.ParentCommentID != nil {
+				hasReplies[*c.ParentCommentID] = true
+			}
+		}
+	}
+
+	visible := make([]*Comment, 0, len(keep))
+	for _, c := range comments {
+		switch {
+		case !keep[c.ID]:
+		case c.IsDeleted():
+			visible = append(visible, c.Tombstone())
+		default:
+			visible = append(visible, c)
+		}
+	}
+	return visible
 }
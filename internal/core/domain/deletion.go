@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// MaxDeleteReasonLength caps the reason stored with a deletion.
+const MaxDeleteReasonLength = 200
+
+var (
+	ErrNotAuthor          = errors.New("only the author may do this")
+	ErrDeleteWindowClosed = errors.New("the time to delete this has run out")
+)
+
+// Actor is whoever changes content: a visitor, known by the user of their session, or a member of staff.
+type Actor struct {
+	UserID    int64
+	Moderator string
+}
+
+func (a Actor) IsStaff() bool {
+	return a.Moderator != ""
+}
+
+// String identifies the actor in audit columns, "moderator:<login>" or "user:<id>".
+func (a Actor) String() string {
+	if a.IsStaff() {
+		return "moderator:" + a.Moderator
+	}
+	return "user:" + strconv.FormatInt(a.UserID, 10)
+}
+
+// Deletion records that a post or comment was removed. Deleted content is kept but no longer shown.
+type Deletion struct {
+	At     time.Time
+	By     string
+	Reason string
+}
+
+// DeletionPolicy decides who may delete a post or comment.
+type DeletionPolicy struct {
+	// AuthorWindow is how long after creation authors may delete their own content, zero means they never may.
+	AuthorWindow time.Duration
+}
+
+func DefaultDeletionPolicy() DeletionPolicy {
+	return DeletionPolicy{AuthorWindow: 15 * time.Minute}
+}
+
+// Check returns nil when actor may delete content authorID created at createdAt. Staff may always delete.
+func (p DeletionPolicy) Check(actor Actor, authorID int64, createdAt, now time.Time) error {
+	if actor.IsStaff() {
+		return nil
+	}
+	if actor.UserID == 0 || actor.UserID != authorID {
+		return ErrNotAuthor
+	}
+	if !now.Before(createdAt.Add(p.AuthorWindow)) {
+		return ErrDeleteWindowClosed
+	}
+	return nil
+}
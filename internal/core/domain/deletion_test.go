@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeletionPolicy_Check(t *testing.T) {
+	created := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	policy := DeletionPolicy{AuthorWindow: 10 * time.Minute}
+
+	cases := []struct {
+		name  string
+		actor Actor
+		now   time.Time
+		want  error
+	}{
+		{"author within the window", Actor{UserID: 7}, created.Add(9 * time.Minute), nil},
+		{"author after the window", Actor{UserID: 7}, created.Add(10 * time.Minute), ErrDeleteWindowClosed},
+		{"someone else", Actor{UserID: 8}, created, ErrNotAuthor},
+		{"anonymous", Actor{}, created, ErrNotAuthor},
+		{"moderator long after", Actor{Moderator: "mod"}, created.Add(24 * time.Hour), nil},
+	}
+	for _, c := range cases {
+		if err := policy.Check(c.actor, 7, created, c.now); !errors.Is(err, c.want) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+
+	if err := (DeletionPolicy{}).Check(Actor{UserID: 7}, 7, created, created); !errors.Is(err, ErrDeleteWindowClosed) {
+		t.Fatalf("expected a zero window to keep authors from deleting, got %v", err)
+	}
+}
+
+func TestVisibleComments(t *testing.T) {
+	id := func(v int64) *int64 { return &v }
+	deleted := &Deletion{At: time.Now(), By: "user:1", Reason: "oops"}
+	comments := []*Comment{
+		{ID: 1, Content: "root", Author: UserData{ID: 1, Name: "a"}, Deleted: deleted},
+		{ID: 2, ParentCommentID: id(1), Content: "deleted reply", Deleted: deleted},
+		{ID: 3, ParentCommentID: id(2), Content: "reply to a deleted reply"},
+		{ID: 4, Content: "deleted leaf", Deleted: deleted},
+		{ID: 5, Content: "kept"},
+	}
+
+	visible := VisibleComments(comments)
+	if len(visible) != 4 {
+		t.Fatalf("expected the deleted leaf to be dropped, got %d comments", len(visible))
+	}
+	for _, c := range visible[:2] {
+		if !c.IsDeleted() || c.Content != "" || c.Author.Name != "" || c.Deleted.Reason != "" {
+			t.Fatalf("expected comment %d to be a tombstone, got %+v", c.ID, c)
+		}
+	}
+	if *visible[1].ParentCommentID != 1 || visible[2].ID != 3 || visible[3].ID != 5 {
+		t.Fatalf("expected the thread to keep its shape, got %+v", visible)
+	}
+	if comments[0].Content != "root" {
+		t.Fatalf("expected the input to be left alone")
+	}
+}
@@ -0,0 +1,61 @@
+package domain
+
+import "time"
+
+// Image holds the public paths of an uploaded image and its downscaled variants.
+// A variant path is empty when the original is already small enough or its format cannot be resized.
+type Image struct {
+	// Hash identifies the stored image, it is empty for images uploaded before deduplication.
+	Hash          string
+	Path          string
+	ThumbnailPath string
+	PreviewPath   string
+}
+
+// Thumbnail returns the smallest stored variant, falling back to the larger ones.
+func (i Image) Thumbnail() string {
+	if i.ThumbnailPath != "" {
+		return i.ThumbnailPath
+	}
+	return i.Preview()
+}
+
+// Preview returns the medium variant, falling back to the original.
+func (i Image) Preview() string {
+	if i.PreviewPath != "" {
+		return i.PreviewPath
+	}
+	return i.Path
+}
+
+// ImageRecord tracks a stored image by the hash of its normalized content, so identical uploads share one copy.
+type ImageRecord struct {
+	Image    Image
+	OwnerID  int64
+	Size     int64
+	MIMEType string
+	// RefCount is how many visible posts and comments attach the image when the record was read. It is counted
+	// from the attachments rather than stored, so it cannot drift when content is deleted.
+	RefCount  int64
+	CreatedAt time.Time
+	// LastUsedAt is refreshed whenever the image is attached again, the sweeper never removes recently used images.
+	LastUsedAt time.Time
+}
+
+// ImagePolicy limits what images are accepted and how their variants are sized.
+type ImagePolicy struct {
+	// MaxBytes, MaxWidth and MaxHeight bound a single image, zero means unlimited.
+	MaxBytes  int64
+	MaxWidth  int64
+	MaxHeight int64
+	// AllowedTypes lists the accepted MIME types, the real type is sniffed from the content.
+	AllowedTypes []string
+	// ThumbnailSize and PreviewSize are the longest sides of the downscaled variants, zero disables a variant.
+	ThumbnailSize int64
+	PreviewSize   int64
+	// MaxAttachments is how many images a post or comment may carry, zero means unlimited.
+	MaxAttachments int
+	// MaxDecodeMemory bounds the memory decoding and resizing a single image may take, zero means unlimited.
+	// Attachments are processed one after another, so it also bounds the memory of a request.
+	MaxDecodeMemory int64
+}
@@ -0,0 +1,41 @@
+package domain
+
+import "testing"
+
+func TestImage_variantsFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		img           Image
+		wantThumbnail string
+		wantPreview   string
+	}{
+		{"all variants", Image{Path: "orig", ThumbnailPath: "thumb", PreviewPath: "preview"}, "thumb", "preview"},
+		{"small original", Image{Path: "orig", ThumbnailPath: "thumb"}, "thumb", "orig"},
+		{"no variants", Image{Path: "orig"}, "orig", "orig"},
+		{"no image", Image{}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.img.Thumbnail(); got != tt.wantThumbnail {
+				t.Fatalf("expected thumbnail %q, got %q", tt.wantThumbnail, got)
+			}
+			if got := tt.img.Preview(); got != tt.wantPreview {
+				t.Fatalf("expected preview %q, got %q", tt.wantPreview, got)
+			}
+		})
+	}
+}
+
+func TestPost_Image_cover(t *testing.T) {
+	post := Post{Attachments: []Attachment{
+		{Image: Image{Path: "first"}, Position: 0},
+		{Image: Image{Path: "second"}, Position: 1},
+	}}
+	if got := post.Image().Path; got != "first" {
+		t.Fatalf("expected the first attachment as cover, got %q", got)
+	}
+	if got := (Post{}).Image(); got != (Image{}) {
+		t.Fatalf("expected no cover, got %+v", got)
+	}
+}
@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// Role is the privilege of a staff login.
+type Role string
+
+const (
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleModerator || r == RoleAdmin
+}
+
+// Includes reports whether r grants everything required does, an admin can do everything a moderator can.
+func (r Role) Includes(required Role) bool {
+	switch r {
+	case RoleAdmin:
+		return required.Valid()
+	case RoleModerator:
+		return required == RoleModerator
+	}
+	return false
+}
+
+// ModeratorAccount is a staff login. Accounts are configured out of band, they are not stored with the board.
+type ModeratorAccount struct {
+	Login        string
+	Role         Role
+	PasswordHash string
+}
+
+// ModeratorSession is the session of a staff login. It is kept apart from the anonymous sessions of the board,
+// signing in as staff does not change who posts are written as.
+type ModeratorSession struct {
+	TokenHash string
+	Login     string
+	Role      Role
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (s ModeratorSession) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
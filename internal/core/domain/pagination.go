@@ -1,6 +1,108 @@
 package domain
 
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 50
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Pagination struct {
 	Page     int `json:"page"`
 	PageSize int `json:"pageSize"`
+	// Cursor switches listing to keyset mode: only items strictly older than
+	// the cursor are returned and Page is ignored.
+	Cursor *Cursor `json:"cursor,omitempty"`
+}
+
+// Normalize clamps page and page size into their allowed ranges.
+func (p *Pagination) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.PageSize < 1 {
+		p.PageSize = DefaultPageSize
+	}
+	if p.PageSize > MaxPageSize {
+		p.PageSize = MaxPageSize
+	}
+}
+
+func (p *Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// Cursor points at the last item of a page ordered by (created_at, id) descending.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	rawTime, rawID, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(rawTime, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+type PostPage struct {
+	Posts      []Post
+	Page       int
+	PageSize   int
+	Total      int64
+	NextCursor *Cursor
+}
+
+func (p PostPage) TotalPages() int {
+	return pageCount(p.Total, p.PageSize)
+}
+
+func (p PostPage) HasPrev() bool {
+	return p.Page > 1
+}
+
+func (p PostPage) HasNext() bool {
+	return p.Page < p.TotalPages()
+}
+
+func (p PostPage) PrevPage() int {
+	return p.Page - 1
+}
+
+func (p PostPage) NextPage() int {
+	return p.Page + 1
+}
+
+// pageCount is how many pages of pageSize total items fill.
+func pageCount(total int64, pageSize int) int {
+	if pageSize <= 0 {
+		return 0
+	}
+	return int((total + int64(pageSize) - 1) / int64(pageSize))
 }
@@ -3,23 +3,90 @@ package domain
 import "time"
 
 type Post struct {
-	ID         int64
-	PostAuthor UserData
-	Title      string
-	Content    string
-	ImagePath  string // S3 object path (пример: "posts/abc123.jpg")
-	CreatedAt  time.Time
-	ExpiresAt  time.Time
-	IsArchived bool
+	ID            int64
+	PostAuthor    UserData
+	Title         string
+	Content       string
+	Attachments   []Attachment
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	IsArchived    bool
+	CommentsCount int
+	// EditedAt is when the post was last edited, zero while it never was
+	EditedAt time.Time
+	// Deleted is set once the post was removed, deleted posts are no longer listed or shown
+	Deleted *Deletion
 }
 
-func (p *Post) IsExpired() bool {
-	// logic here
-	return false
+// Image returns the first attached image, which listings show as the cover of the post.
+func (p Post) Image() Image {
+	return cover(p.Attachments)
 }
 
-func (p *Post) ArchivePostIfExpired() {
-	if p.IsExpired() {
+func (p Post) IsEdited() bool {
+	return !p.EditedAt.IsZero()
+}
+
+func (p Post) IsDeleted() bool {
+	return p.Deleted != nil
+}
+
+func (p *Post) IsExpired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt)
+}
+
+// ArchivePostIfExpired marks the post archived once its lifetime is over and reports whether it is archived.
+func (p *Post) ArchivePostIfExpired(now time.Time) bool {
+	if p.IsExpired(now) {
 		p.IsArchived = true
 	}
+	return p.IsArchived
+}
+
+// LifetimePolicy decides how long a post stays active before it is archived.
+type LifetimePolicy struct {
+	// NoCommentsTTL is how long a post without comments lives after creation.
+	NoCommentsTTL time.Duration
+	// CommentTTL is how long a post lives after its latest comment.
+	CommentTTL time.Duration
+	// MaxLifetime caps the total lifetime counted from creation, zero means unlimited.
+	MaxLifetime time.Duration
+	// BumpLimit is the number of comments after which new comments stop extending the post, zero means unlimited.
+	BumpLimit int
+}
+
+func DefaultLifetimePolicy() LifetimePolicy {
+	return LifetimePolicy{
+		NoCommentsTTL: 10 * time.Minute,
+		CommentTTL:    15 * time.Minute,
+	}
+}
+
+// InitialExpiry returns the expiration time of a freshly created post.
+func (lp LifetimePolicy) InitialExpiry(createdAt time.Time) time.Time {
+	return lp.capped(createdAt, createdAt.Add(lp.NoCommentsTTL))
+}
+
+// ExpiryAfterComment returns the expiration time of post after a comment was left at commentedAt.
+// A comment never shortens the lifetime of a post.
+func (lp LifetimePolicy) ExpiryAfterComment(post Post, commentedAt time.Time) time.Time {
+	if lp.BumpLimit > 0 && post.CommentsCount >= lp.BumpLimit {
+		return post.ExpiresAt
+	}
+
+	expiresAt := commentedAt.Add(lp.CommentTTL)
+	if expiresAt.Before(post.ExpiresAt) {
+		expiresAt = post.ExpiresAt
+	}
+
+	return lp.capped(post.CreatedAt, expiresAt)
+}
+
+func (lp LifetimePolicy) capped(createdAt, expiresAt time.Time) time.Time {
+	if lp.MaxLifetime > 0 {
+		if limit := createdAt.Add(lp.MaxLifetime); expiresAt.After(limit) {
+			return limit
+		}
+	}
+	return expiresAt
 }
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPost_IsExpired(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	post := Post{ExpiresAt: now}
+
+	if post.IsExpired(now.Add(-time.Second)) {
+		t.Fatalf("expected post to be active before expires_at")
+	}
+	if !post.IsExpired(now) {
+		t.Fatalf("expected post to be expired at expires_at")
+	}
+	if !post.ArchivePostIfExpired(now.Add(time.Second)) || !post.IsArchived {
+		t.Fatalf("expected expired post to be archived")
+	}
+}
+
+func TestLifetimePolicy_InitialExpiry(t *testing.T) {
+	created := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	policy := DefaultLifetimePolicy()
+	if got := policy.InitialExpiry(created); !got.Equal(created.Add(10 * time.Minute)) {
+		t.Fatalf("expected 10 minutes lifetime, got %v", got.Sub(created))
+	}
+
+	policy.MaxLifetime = 5 * time.Minute
+	if got := policy.InitialExpiry(created); !got.Equal(created.Add(5 * time.Minute)) {
+		t.Fatalf("expected lifetime capped at 5 minutes, got %v", got.Sub(created))
+	}
+}
+
+func TestLifetimePolicy_ExpiryAfterComment(t *testing.T) {
+	created := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	policy := DefaultLifetimePolicy()
+	post := Post{CreatedAt: created, ExpiresAt: policy.InitialExpiry(created)}
+
+	commented := created.Add(2 * time.Minute)
+	if got := policy.ExpiryAfterComment(post, commented); !got.Equal(commented.Add(15 * time.Minute)) {
+		t.Fatalf("expected 15 minutes after the comment, got %v", got.Sub(commented))
+	}
+
+	post.ExpiresAt = created.Add(time.Hour)
+	if got := policy.ExpiryAfterComment(post, commented); !got.Equal(post.ExpiresAt) {
+		t.Fatalf("expected comment not to shorten lifetime, got %v", got)
+	}
+}
+
+func TestLifetimePolicy_ExpiryAfterComment_limits(t *testing.T) {
+	created := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	policy := LifetimePolicy{
+		NoCommentsTTL: 10 * time.Minute,
+		CommentTTL:    15 * time.Minute,
+		MaxLifetime:   20 * time.Minute,
+		BumpLimit:     3,
+	}
+	post := Post{CreatedAt: created, ExpiresAt: policy.InitialExpiry(created), CommentsCount: 1}
+
+	commented := created.Add(9 * time.Minute)
+	if got := policy.ExpiryAfterComment(post, commented); !got.Equal(created.Add(20 * time.Minute)) {
+		t.Fatalf("expected lifetime capped at 20 minutes, got %v", got.Sub(created))
+	}
+
+	post.CommentsCount = 3
+	if got := policy.ExpiryAfterComment(post, commented); !got.Equal(post.ExpiresAt) {
+		t.Fatalf("expected no extension past the bump limit, got %v", got)
+	}
+}
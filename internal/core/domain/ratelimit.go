@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// RateAction names what a rate limit is counted for.
+type RateAction string
+
+const (
+	RateActionPost    RateAction = "post"
+	RateActionComment RateAction = "comment"
+	RateActionUpload  RateAction = "upload"
+	RateActionSession RateAction = "session"
+	RateActionLogin   RateAction = "login"
+	RateActionReport  RateAction = "report"
+	// RateActionStrike counts requests refused by the other limits, challenges get harder the more strikes there are
+	RateActionStrike RateAction = "strike"
+)
+
+// RateLimit is a token bucket: it holds up to Burst tokens and refills completely over Window.
+// A zero Burst or Window disables the limit.
+type RateLimit struct {
+	Burst  int
+	Window time.Duration
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Burst > 0 && l.Window > 0
+}
+
+// Bucket is the state of one token bucket. A zero bucket is full.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Take refills b for the time since it was last updated and takes cost tokens from it.
+// When there are not enough tokens nothing is taken and the returned duration says how long
+// until there will be, a zero duration means the tokens were taken.
+func (l RateLimit) Take(b Bucket, cost int, now time.Time) (Bucket, time.Duration) {
+	burst := float64(l.Burst)
+	rate := burst / l.Window.Seconds() // tokens per second
+
+	b = l.Refill(b, now)
+	tokens := b.Tokens
+
+	need := float64(cost)
+	if need > burst {
+		// can never be satisfied, ask to come back once the bucket is full
+		return b, l.Window
+	}
+	if tokens >= need {
+		b.Tokens -= need
+		return b, 0
+	}
+	// rounded up to whole seconds, clients are told in seconds anyway. Rounding to milliseconds first
+	// keeps float noise from adding a second.
+	wait := time.Duration((need - tokens) / rate * float64(time.Second)).Round(time.Millisecond)
+	return b, (wait + time.Second - 1).Truncate(time.Second)
+}
+
+// TakeAll takes cost tokens from every bucket or from none of them. When one of them has too few tokens the
+// buckets are returned unchanged together with the longest wait, so a refused request costs nothing.
+func (l RateLimit) TakeAll(buckets []Bucket, cost int, now time.Time) ([]Bucket, time.Duration) {
+	taken := make([]Bucket, len(buckets))
+	var retryAfter time.Duration
+	for i, b := range buckets {
+		var wait time.Duration
+		taken[i], wait = l.Take(b, cost, now)
+		retryAfter = max(retryAfter, wait)
+	}
+	if retryAfter > 0 {
+		return buckets, retryAfter
+	}
+	return taken, 0
+}
+
+// Refill adds the tokens accumulated since b was last updated.
+func (l RateLimit) Refill(b Bucket, now time.Time) Bucket {
+	burst := float64(l.Burst)
+	if b.UpdatedAt.IsZero() {
+		return Bucket{Tokens: burst, UpdatedAt: now}
+	}
+	elapsed := max(now.Sub(b.UpdatedAt).Seconds(), 0)
+	return Bucket{Tokens: math.Min(burst, b.Tokens+elapsed*burst/l.Window.Seconds()), UpdatedAt: now}
+}
+
+// Used returns how many whole tokens are missing from b at now.
+func (l RateLimit) Used(b Bucket, now time.Time) int {
+	return int(float64(l.Burst) - math.Ceil(l.Refill(b, now).Tokens))
+}
+
+// RateLimitExceeded is returned when a bucket has run dry, RetryAfter is when the request may be repeated.
+type RateLimitExceeded struct {
+	Action     RateAction
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitExceeded) Error() string {
+	return fmt.Sprintf("%s rate limit exceeded, retry after %s", e.Action, e.RetryAfter)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimit_Take(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	limit := RateLimit{Burst: 2, Window: time.Minute} // one token every 30 seconds
+
+	bucket, retry := limit.Take(Bucket{}, 1, now)
+	if retry != 0 || bucket.Tokens != 1 {
+		t.Fatalf("expected a new bucket to start full, got %+v and %v", bucket, retry)
+	}
+	bucket, retry = limit.Take(bucket, 1, now)
+	if retry != 0 || bucket.Tokens != 0 {
+		t.Fatalf("expected the second token to be taken, got %+v and %v", bucket, retry)
+	}
+	bucket, retry = limit.Take(bucket, 1, now.Add(10*time.Second))
+	if retry != 20*time.Second {
+		t.Fatalf("expected to retry after 20s, got %v", retry)
+	}
+	bucket, retry = limit.Take(bucket, 1, now.Add(30*time.Second))
+	if retry != 0 {
+		t.Fatalf("expected a refilled token after 30s, got retry %v", retry)
+	}
+
+	// a bucket never refills past its burst
+	bucket, _ = limit.Take(bucket, 1, now.Add(time.Hour))
+	if bucket.Tokens != 1 {
+		t.Fatalf("expected one token left, got %v", bucket.Tokens)
+	}
+
+	if _, retry := limit.Take(Bucket{}, 3, now); retry != time.Minute {
+		t.Fatalf("expected a cost above the burst to be refused for a window, got %v", retry)
+	}
+}
+
+func TestRateLimit_TakeAll(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	limit := RateLimit{Burst: 2, Window: time.Minute}
+
+	full, empty := Bucket{Tokens: 2, UpdatedAt: now}, Bucket{Tokens: 0, UpdatedAt: now}
+	buckets, retry := limit.TakeAll([]Bucket{full, empty}, 1, now)
+	if retry != 30*time.Second {
+		t.Fatalf("expected to retry after 30s, got %v", retry)
+	}
+	if buckets[0] != full || buckets[1] != empty {
+		t.Fatalf("expected a refused take to leave every bucket alone, got %+v", buckets)
+	}
+
+	buckets, retry = limit.TakeAll([]Bucket{full, {}}, 1, now)
+	if retry != 0 || buckets[0].Tokens != 1 || buckets[1].Tokens != 1 {
+		t.Fatalf("expected a token to be taken from both buckets, got %+v and %v", buckets, retry)
+	}
+}
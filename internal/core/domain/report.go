@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// MaxReportDetailsLength caps the free text a reader sends with a report.
+	MaxReportDetailsLength = 500
+	// MaxReportNoteLength caps the note a moderator leaves when closing reports.
+	MaxReportNoteLength = 200
+	// MaxQueuedReports is how many of the latest reports on a target the moderation queue lists.
+	MaxQueuedReports = 20
+)
+
+// ReportReason is the category a reader files a report under.
+type ReportReason string
+
+const (
+	ReportSpam     ReportReason = "spam"
+	ReportAbuse    ReportReason = "abuse"
+	ReportIllegal  ReportReason = "illegal"
+	ReportOffTopic ReportReason = "off_topic"
+	// ReportOther needs details, there is nothing else to go by
+	ReportOther ReportReason = "other"
+)
+
+// ReportReasons lists the categories in the order forms offer them.
+var ReportReasons = []ReportReason{ReportSpam, ReportAbuse, ReportIllegal, ReportOffTopic, ReportOther}
+
+func (r ReportReason) Valid() bool {
+	for _, reason := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Label is the reason as forms show it.
+func (r ReportReason) Label() string {
+	return strings.ReplaceAll(string(r), "_", " ")
+}
+
+// ReportAction is what a moderator did about the reports on a post or comment.
+type ReportAction string
+
+const (
+	// ReportDeleted closes reports by deleting what they are about
+	ReportDeleted ReportAction = "deleted"
+	// ReportResolved closes reports that were acted on some other way, revoking the author's sessions say
+	ReportResolved ReportAction = "resolved"
+	// ReportDismissed closes reports that needed no action
+	ReportDismissed ReportAction = "dismissed"
+)
+
+func (a ReportAction) Valid() bool {
+	return a == ReportDeleted || a == ReportResolved || a == ReportDismissed
+}
+
+// ReportTarget is the post or comment a report is about, exactly one of the IDs is set.
+type ReportTarget struct {
+	PostID    int64
+	CommentID int64
+}
+
+func (t ReportTarget) Valid() bool {
+	return (t.PostID > 0) != (t.CommentID > 0)
+}
+
+func (t ReportTarget) IsComment() bool {
+	return t.CommentID > 0
+}
+
+// Resolution records which moderator closed a report, when, and what was done.
+type Resolution struct {
+	Action ReportAction
+	By     string
+	Note   string
+	At     time.Time
+}
+
+// Report is a reader flagging a post or comment. It stays open until a moderator resolves or dismisses it.
+type Report struct {
+	ID         int64
+	Target     ReportTarget
+	ReporterID int64
+	Reason     ReportReason
+	Details    string
+	CreatedAt  time.Time
+	Resolution *Resolution
+}
+
+func (r Report) IsOpen() bool {
+	return r.Resolution == nil
+}
+
+// ReportGroup gathers the open reports on one post or comment for the moderation queue.
+type ReportGroup struct {
+	Target ReportTarget
+	// Open counts the open reports, Reports holds the latest MaxQueuedReports of them
+	Open    int
+	Reports []Report
+	// Reasons counts the open reports per category
+	Reasons map[ReportReason]int
+	FirstAt time.Time
+	LastAt  time.Time
+
+	// Post and Comment hold what was reported, whichever of them the target is
+	Post    *Post
+	Comment *Comment
+}
+
+func (g ReportGroup) Count() int {
+	return g.Open
+}
+
+// ReportPage is a page of the moderation queue, the most reported targets come first,
+// ties go to the most recently reported.
+type ReportPage struct {
+	Groups   []ReportGroup
+	Page     int
+	PageSize int
+	// Total counts the reported targets
+	Total int64
+}
+
+func (p ReportPage) TotalPages() int {
+	return pageCount(p.Total, p.PageSize)
+}
+
+func (p ReportPage) HasPrev() bool {
+	return p.Page > 1
+}
+
+func (p ReportPage) HasNext() bool {
+	return p.Page < p.TotalPages()
+}
+
+func (p ReportPage) PrevPage() int {
+	return p.Page - 1
+}
+
+func (p ReportPage) NextPage() int {
+	return p.Page + 1
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"testing"
+)
+
+func TestReportTarget_Valid(t *testing.T) {
+	cases := []struct {
+		target ReportTarget
+		want   bool
+	}{
+		{ReportTarget{PostID: 1}, true},
+		{ReportTarget{CommentID: 2}, true},
+		{ReportTarget{PostID: 1, CommentID: 2}, false},
+		{ReportTarget{}, false},
+		{ReportTarget{PostID: -1}, false},
+	}
+	for _, c := range cases {
+		if got := c.target.Valid(); got != c.want {
+			t.Fatalf("%+v: expected %v, got %v", c.target, c.want, got)
+		}
+	}
+}
+
+func TestReportPage(t *testing.T) {
+	cases := []struct {
+		page             ReportPage
+		pages            int
+		hasPrev, hasNext bool
+	}{
+		{ReportPage{Page: 1, PageSize: 10}, 0, false, false},
+		{ReportPage{Page: 1, PageSize: 10, Total: 10}, 1, false, false},
+		{ReportPage{Page: 1, PageSize: 10, Total: 11}, 2, false, true},
+		{ReportPage{Page: 2, PageSize: 10, Total: 11}, 2, true, false},
+	}
+	for _, c := range cases {
+		if c.page.TotalPages() != c.pages || c.page.HasPrev() != c.hasPrev || c.page.HasNext() != c.hasNext {
+			t.Fatalf("%+v: expected %d pages, prev %v and next %v", c.page, c.pages, c.hasPrev, c.hasNext)
+		}
+	}
+}
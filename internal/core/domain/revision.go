@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrEditWindowClosed = errors.New("the time to edit this has run out")
+
+// Revision keeps the text a post or comment had before an edit. Title is empty for comments.
+type Revision struct {
+	ID       int64
+	Title    string
+	Content  string
+	EditedBy string
+	EditedAt time.Time
+}
+
+// EditPolicy decides who may edit a post or comment.
+type EditPolicy struct {
+	// AuthorWindow is how long after creation authors may edit their own content, zero means they never may.
+	AuthorWindow time.Duration
+}
+
+func DefaultEditPolicy() EditPolicy {
+	return EditPolicy{AuthorWindow: 15 * time.Minute}
+}
+
+// Check returns nil when actor may edit content authorID created at createdAt. Only authors edit, staff
+// remove content instead of rewriting it.
+func (p EditPolicy) Check(actor Actor, authorID int64, createdAt, now time.Time) error {
+	if actor.IsStaff() || actor.UserID == 0 || actor.UserID != authorID {
+		return ErrNotAuthor
+	}
+	if !now.Before(createdAt.Add(p.AuthorWindow)) {
+		return ErrEditWindowClosed
+	}
+	return nil
+}
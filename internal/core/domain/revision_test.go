@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEditPolicy_Check(t *testing.T) {
+	created := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	policy := EditPolicy{AuthorWindow: 10 * time.Minute}
+
+	cases := []struct {
+		name  string
+		actor Actor
+		now   time.Time
+		want  error
+	}{
+		{"author within the window", Actor{UserID: 7}, created.Add(9 * time.Minute), nil},
+		{"author after the window", Actor{UserID: 7}, created.Add(10 * time.Minute), ErrEditWindowClosed},
+		{"someone else", Actor{UserID: 8}, created, ErrNotAuthor},
+		{"moderator", Actor{Moderator: "mod"}, created, ErrNotAuthor},
+	}
+	for _, c := range cases {
+		if err := policy.Check(c.actor, 7, created, c.now); !errors.Is(err, c.want) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+}
@@ -5,9 +5,14 @@ import "time"
 type Session struct {
 	ID        int64
 	TokenHash string // Захэшированный токен для безопасности
+	// PreviousTokenHash is the hash replaced by the last rotation, it stays usable for a short grace period
+	PreviousTokenHash string
+	// CSRFToken is the synchronizer token forms of this session carry, it survives token rotation
+	CSRFToken string
 	User      UserData
 	ExpiresAt time.Time
 	CreatedAt time.Time
+	RotatedAt time.Time
 }
 
 type UserData struct {
@@ -19,3 +24,8 @@ type UserData struct {
 func (s Session) IsExpired() bool {
 	return time.Now().UTC().After(s.ExpiresAt)
 }
+
+// NeedsRotation reports whether the token was last issued at least interval ago, a zero interval disables rotation.
+func (s Session) NeedsRotation(now time.Time, interval time.Duration) bool {
+	return interval > 0 && now.Sub(s.RotatedAt) >= interval
+}
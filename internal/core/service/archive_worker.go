@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+type PostArchiver interface {
+	ArchiveExpiredPosts(ctx context.Context) (int64, error)
+}
+
+type ArchiveStats struct {
+	Runs     int64     `json:"runs"`
+	Failures int64     `json:"failures"`
+	Archived int64     `json:"archived"`
+	LastRun  time.Time `json:"last_run"`
+}
+
+// ArchiveWorker periodically archives expired posts until its context is cancelled.
+type ArchiveWorker struct {
+	archiver PostArchiver
+	interval time.Duration
+	logger   *slog.Logger
+
+	runs     atomic.Int64
+	failures atomic.Int64
+	archived atomic.Int64
+	lastRun  atomic.Int64 // unix nanoseconds
+}
+
+func NewArchiveWorker(archiver PostArchiver, interval time.Duration, logger *slog.Logger) *ArchiveWorker {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &ArchiveWorker{
+		archiver: archiver,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run performs a pass right away and then every interval. It blocks until ctx is done.
+func (w *ArchiveWorker) Run(ctx context.Context) {
+	const op = "ArchiveWorker.Run"
+
+	w.logger.Info("archive worker started", "op", op, "interval", w.interval.String())
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.RunOnce(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			w.logger.Info("archive worker stopped", "op", op)
+			return
+		}
+	}
+}
+
+// RunOnce performs a single archive pass and records its outcome.
+func (w *ArchiveWorker) RunOnce(ctx context.Context) (int64, error) {
+	const op = "ArchiveWorker.RunOnce"
+
+	w.runs.Add(1)
+	w.lastRun.Store(time.Now().UnixNano())
+
+	archived, err := w.archiver.ArchiveExpiredPosts(ctx)
+	if err != nil {
+		failures := w.failures.Add(1)
+		attrs := []any{"op", op, "failures", failures, "err", err.Error()}
+		if cause := errors.Unwrap(err); cause != nil {
+			attrs = append(attrs, "cause", cause.Error())
+		}
+		w.logger.Error("archive pass failed", attrs...)
+		return 0, err
+	}
+
+	w.archived.Add(archived)
+	if archived > 0 {
+		w.logger.Info("archive pass finished", "op", op, "archived", archived)
+	} else {
+		w.logger.Debug("archive pass finished", "op", op, "archived", archived)
+	}
+
+	return archived, nil
+}
+
+func (w *ArchiveWorker) Stats() ArchiveStats {
+	stats := ArchiveStats{
+		Runs:     w.runs.Load(),
+		Failures: w.failures.Load(),
+		Archived: w.archived.Load(),
+	}
+	if lastRun := w.lastRun.Load(); lastRun != 0 {
+		stats.LastRun = time.Unix(0, lastRun).UTC()
+	}
+	return stats
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type mockArchiver struct {
+	archiveFunc func(ctx context.Context) (int64, error)
+}
+
+func (m *mockArchiver) ArchiveExpiredPosts(ctx context.Context) (int64, error) {
+	if m.archiveFunc != nil {
+		return m.archiveFunc(ctx)
+	}
+	return 0, nil
+}
+
+func TestArchiveWorker_RunOnce(t *testing.T) {
+	fail := false
+	archiver := &mockArchiver{
+		archiveFunc: func(ctx context.Context) (int64, error) {
+			if fail {
+				return 0, fmt.Errorf("error")
+			}
+			return 3, nil
+		},
+	}
+	worker := NewArchiveWorker(archiver, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	archived, err := worker.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if archived != 3 {
+		t.Fatalf("expected 3 archived posts, got %d", archived)
+	}
+
+	fail = true
+	if _, err := worker.RunOnce(context.Background()); err == nil {
+		t.Fatalf("expected error, got none")
+	}
+
+	stats := worker.Stats()
+	if stats.Runs != 2 || stats.Failures != 1 || stats.Archived != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.LastRun.IsZero() {
+		t.Fatalf("expected last run to be recorded")
+	}
+}
+
+func TestArchiveWorker_Run_stopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	archiver := &mockArchiver{
+		archiveFunc: func(ctx context.Context) (int64, error) {
+			cancel()
+			return 0, nil
+		},
+	}
+	worker := NewArchiveWorker(archiver, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("worker did not stop after cancellation")
+	}
+	if runs := worker.Stats().Runs; runs != 1 {
+		t.Fatalf("expected 1 run, got %d", runs)
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-hex-forum/config"
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
+)
+
+type ChallengeRepository interface {
+	Store(ctx context.Context, challenge domain.Challenge) error
+	// Consume deletes the challenge and returns it, so every challenge is answered at most once.
+	Consume(ctx context.Context, id string) (domain.Challenge, error)
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ChallengeProvider generates and checks one kind of puzzle.
+type ChallengeProvider interface {
+	Kind() domain.ChallengeKind
+	// Issue creates a puzzle, level 0 is the configured difficulty and every level above makes it harder.
+	Issue(level int) (domain.Challenge, error)
+	Verify(challenge domain.Challenge, answer string) bool
+}
+
+// StrikeCounter reports how often the subjects of a request were refused by the rate limiter recently.
+type StrikeCounter interface {
+	Strikes(ctx context.Context, subjects ...string) int
+}
+
+// ChallengeService hands out the challenges forms have to solve and checks their answers. Subjects that keep
+// tripping the rate limiter get harder challenges, up to MaxEscalation levels. Without a provider challenges
+// are disabled, nothing is issued and every answer is accepted.
+type ChallengeService struct {
+	repo          ChallengeRepository
+	provider      ChallengeProvider
+	strikes       StrikeCounter
+	ttl           time.Duration
+	maxEscalation int
+	timeSource    func() time.Time
+	logger        *slog.Logger
+}
+
+func NewChallengeService(repo ChallengeRepository, provider ChallengeProvider, strikes StrikeCounter, cfg config.Challenge, timeSource func() time.Time, logger *slog.Logger) *ChallengeService {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	return &ChallengeService{
+		repo:          repo,
+		provider:      provider,
+		strikes:       strikes,
+		ttl:           ttl,
+		maxEscalation: cfg.MaxEscalation,
+		timeSource:    timeSource,
+		logger:        logger,
+	}
+}
+
+// Enabled tells whether forms have to solve challenges.
+func (s *ChallengeService) Enabled() bool {
+	return s.provider != nil
+}
+
+// Issue stores a new challenge for a form shown to subjects. It returns nil when challenges are disabled.
+func (s *ChallengeService) Issue(ctx context.Context, subjects ...string) (*domain.Challenge, error) {
+	const op = "ChallengeService.Issue"
+
+	if s.provider == nil {
+		return nil, nil
+	}
+
+	level := min(s.strikes.Strikes(ctx, subjects...), max(s.maxEscalation, 0))
+	challenge, err := s.provider.Issue(level)
+	if err != nil {
+		return nil, svcerr.NewError("failed to create challenge", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+	if challenge.ID, err = generateChallengeID(); err != nil {
+		return nil, svcerr.NewError("failed to create challenge", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+	challenge.ExpiresAt = s.timeSource().UTC().Add(s.ttl)
+
+	if err := s.repo.Store(ctx, challenge); err != nil {
+		return nil, svcerr.NewError("failed to create challenge", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+
+	return &challenge, nil
+}
+
+// Verify consumes the challenge and checks answer against it. A challenge can only be tried once, a wrong
+// answer needs a new challenge.
+func (s *ChallengeService) Verify(ctx context.Context, id, answer string) error {
+	const op = "ChallengeService.Verify"
+
+	if s.provider == nil {
+		return nil
+	}
+	if id == "" || answer == "" {
+		return svcerr.NewError("challenge not solved", fmt.Errorf("%s: missing challenge or answer", op), svcerr.ErrBadRequest)
+	}
+
+	challenge, err := s.repo.Consume(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrChallengeNotFound) {
+			return svcerr.NewError("challenge expired, reload the page", fmt.Errorf("%s: %w", op, err), svcerr.ErrBadRequest)
+		}
+		return svcerr.NewError("failed to check challenge", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+	if challenge.IsExpired(s.timeSource().UTC()) {
+		return svcerr.NewError("challenge expired, reload the page", fmt.Errorf("%s: expired at %s", op, challenge.ExpiresAt), svcerr.ErrBadRequest)
+	}
+	if challenge.Kind != s.provider.Kind() || !s.provider.Verify(challenge, answer) {
+		return svcerr.NewError("wrong challenge answer", fmt.Errorf("%s: wrong answer", op), svcerr.ErrBadRequest)
+	}
+
+	return nil
+}
+
+// Run deletes expired challenges every ttl until ctx is done.
+func (s *ChallengeService) Run(ctx context.Context) {
+	const op = "ChallengeService.Run"
+
+	if s.provider == nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		deleted, err := s.repo.DeleteExpired(ctx, s.timeSource().UTC())
+		if err != nil {
+			attrs := []any{"op", op, "err", err.Error()}
+			if cause := errors.Unwrap(err); cause != nil {
+				attrs = append(attrs, "cause", cause.Error())
+			}
+			s.logger.Error("deleting expired challenges failed", attrs...)
+			continue
+		}
+		if deleted > 0 {
+			s.logger.Debug("deleted expired challenges", "op", op, "challenges", deleted)
+		}
+	}
+}
+
+func generateChallengeID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
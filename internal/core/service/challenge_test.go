@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"go-hex-forum/config"
+	"go-hex-forum/internal/core/domain"
+)
+
+type mockChallengeRepository struct {
+	challenges map[string]domain.Challenge
+}
+
+func (m *mockChallengeRepository) Store(ctx context.Context, challenge domain.Challenge) error {
+	m.challenges[challenge.ID] = challenge
+	return nil
+}
+
+func (m *mockChallengeRepository) Consume(ctx context.Context, id string) (domain.Challenge, error) {
+	challenge, ok := m.challenges[id]
+	if !ok {
+		return domain.Challenge{}, ErrChallengeNotFound
+	}
+	delete(m.challenges, id)
+	return challenge, nil
+}
+
+func (m *mockChallengeRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// mockChallengeProvider expects the level it was issued at as the answer.
+type mockChallengeProvider struct{}
+
+func (mockChallengeProvider) Kind() domain.ChallengeKind { return domain.ChallengeCaptcha }
+
+func (mockChallengeProvider) Issue(level int) (domain.Challenge, error) {
+	return domain.Challenge{Kind: domain.ChallengeCaptcha, Difficulty: level}, nil
+}
+
+func (mockChallengeProvider) Verify(challenge domain.Challenge, answer string) bool {
+	return answer == string(rune('0'+challenge.Difficulty))
+}
+
+type mockStrikeCounter map[string]int
+
+func (m mockStrikeCounter) Strikes(ctx context.Context, subjects ...string) int {
+	strikes := 0
+	for _, subject := range subjects {
+		strikes = max(strikes, m[subject])
+	}
+	return strikes
+}
+
+func newTestChallengeService(repo ChallengeRepository, provider ChallengeProvider, now *time.Time) *ChallengeService {
+	strikes := mockStrikeCounter{"ip:6.6.6.6": 7}
+	cfg := config.Challenge{TTL: time.Minute, MaxEscalation: 3}
+	return NewChallengeService(repo, provider, strikes, cfg, func() time.Time { return *now }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestChallengeService_IssueAndVerify(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	repo := &mockChallengeRepository{challenges: map[string]domain.Challenge{}}
+	svc := newTestChallengeService(repo, mockChallengeProvider{}, &now)
+	ctx := context.Background()
+
+	challenge, err := svc.Issue(ctx, "ip:1.2.3.4")
+	if err != nil || challenge == nil || challenge.ID == "" {
+		t.Fatalf("expected a stored challenge, got %+v (%v)", challenge, err)
+	}
+	if !challenge.ExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected the challenge to expire after the ttl, got %v", challenge.ExpiresAt)
+	}
+	if err := svc.Verify(ctx, challenge.ID, "0"); err != nil {
+		t.Fatalf("expected the answer to be accepted, got %v", err)
+	}
+	expectBadRequest(t, svc.Verify(ctx, challenge.ID, "0"))
+
+	wrong, _ := svc.Issue(ctx, "ip:1.2.3.4")
+	expectBadRequest(t, svc.Verify(ctx, wrong.ID, "9"))
+	expectBadRequest(t, svc.Verify(ctx, wrong.ID, "0"))
+
+	expired, _ := svc.Issue(ctx, "ip:1.2.3.4")
+	now = now.Add(time.Minute)
+	expectBadRequest(t, svc.Verify(ctx, expired.ID, "0"))
+	expectBadRequest(t, svc.Verify(ctx, "", ""))
+}
+
+func TestChallengeService_escalation(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	repo := &mockChallengeRepository{challenges: map[string]domain.Challenge{}}
+	svc := newTestChallengeService(repo, mockChallengeProvider{}, &now)
+
+	challenge, err := svc.Issue(context.Background(), "ip:6.6.6.6", "user:1")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if challenge.Difficulty != 3 {
+		t.Fatalf("expected seven strikes to be capped at three levels, got %d", challenge.Difficulty)
+	}
+}
+
+func TestChallengeService_disabled(t *testing.T) {
+	now := time.Now()
+	svc := newTestChallengeService(&mockChallengeRepository{}, nil, &now)
+
+	if svc.Enabled() {
+		t.Fatalf("expected challenges to be disabled")
+	}
+	if challenge, err := svc.Issue(context.Background()); challenge != nil || err != nil {
+		t.Fatalf("expected no challenge, got %+v (%v)", challenge, err)
+	}
+	if err := svc.Verify(context.Background(), "", ""); err != nil {
+		t.Fatalf("expected any answer to pass, got %v", err)
+	}
+}
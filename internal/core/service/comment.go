@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -14,7 +15,17 @@ import (
 
 type CommentRepository interface {
 	SaveComment(ctx context.Context, comment *domain.Comment) (int64, error)
+	GetByID(ctx context.Context, commentID int64) (*domain.Comment, error)
+	// GetByIDs returns the comments of ids that exist, deleted ones included, in no particular order.
+	GetByIDs(ctx context.Context, ids []int64) ([]*domain.Comment, error)
 	GetByPostID(ctx context.Context, postID int64) ([]*domain.Comment, error)
+	// GetRecent returns the newest comments of all posts, newest first.
+	GetRecent(ctx context.Context, limit int) ([]*domain.Comment, error)
+	DeleteComment(ctx context.Context, commentID int64, deletion domain.Deletion) error
+	// EditComment stores the new content of comment together with the revision keeping the old one.
+	EditComment(ctx context.Context, comment *domain.Comment, revision domain.Revision) error
+	// GetCommentRevisions returns the revisions of a comment, newest first.
+	GetCommentRevisions(ctx context.Context, commentID int64) ([]domain.Revision, error)
 }
 
 type CommentPostRepo interface {
@@ -23,22 +34,30 @@ type CommentPostRepo interface {
 }
 
 type CommentService struct {
-	transactor   Transactor
-	commentRepo  CommentRepository
-	postRepo     CommentPostRepo
-	imageStorage ImageStorage
+	transactor  Transactor
+	commentRepo CommentRepository
+	postRepo    CommentPostRepo
+	images      ImageUploader
+	policy      domain.LifetimePolicy
+	deletion    domain.DeletionPolicy
+	edit        domain.EditPolicy
+	timeSource  func() time.Time
 }
 
 func NewCommentService(
 	tr Transactor,
 	cr CommentRepository,
 	pr CommentPostRepo,
-	is ImageStorage,
+	is ImageUploader,
+	policy domain.LifetimePolicy,
+	deletion domain.DeletionPolicy,
+	edit domain.EditPolicy,
+	timeSource func() time.Time,
 ) *CommentService {
-	return &CommentService{tr, cr, pr, is}
+	return &CommentService{tr, cr, pr, is, policy, deletion, edit, timeSource}
 }
 
-func (s *CommentService) SaveComment(ctx context.Context, comment *domain.Comment, imageData []byte) (int64, error) {
+func (s *CommentService) SaveComment(ctx context.Context, comment *domain.Comment, uploads []domain.AttachmentUpload) (int64, error) {
 	const op = "CommentService.SaveComment"
 	if comment.Content == "" {
 		err := fmt.Errorf("%s: content is not provided", op)
@@ -50,22 +69,51 @@ func (s *CommentService) SaveComment(ctx context.Context, comment *domain.Commen
 		raw := fmt.Errorf("%s: get post: %w", op, err)
 		return -1, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
 	}
-	if post.IsArchived {
+	if post.IsDeleted() {
+		raw := fmt.Errorf("%s: post %d was deleted", op, comment.PostID)
+		return -1, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
+	}
+	now := s.timeSource()
+	if post.ArchivePostIfExpired(now) {
 		raw := fmt.Errorf("%s: post is archived", op)
 		return -1, svcerr.NewError("post is archived, new comments are prohibited", raw, svcerr.ErrBadRequest)
 	}
 
+	// Проверка родительского комментария
+	if comment.ParentCommentID != nil {
+		parent, err := s.commentRepo.GetByID(ctx, *comment.ParentCommentID)
+		if err != nil {
+			if errors.Is(err, ErrCommentNotFound) {
+				raw := fmt.Errorf("%s: parent comment %d: %w", op, *comment.ParentCommentID, err)
+				return -1, svcerr.NewError("parent comment not found", raw, svcerr.ErrBadRequest)
+			}
+			raw := fmt.Errorf("%s: get parent comment: %w", op, err)
+			return -1, svcerr.NewError("failed to load parent comment", raw, svcerr.ErrInternal)
+		}
+		if parent.PostID != comment.PostID {
+			raw := fmt.Errorf("%s: parent comment %d belongs to post %d, not %d", op, parent.ID, parent.PostID, comment.PostID)
+			return -1, svcerr.NewError("parent comment belongs to another post", raw, svcerr.ErrBadRequest)
+		}
+		if parent.IsDeleted() {
+			raw := fmt.Errorf("%s: parent comment %d was deleted", op, parent.ID)
+			return -1, svcerr.NewError("parent comment was deleted", raw, svcerr.ErrBadRequest)
+		}
+	}
+
 	// Загрузка изображения
-	if len(imageData) > 0 {
-		url, err := s.imageStorage.UploadImage(ctx, comment.Author.ID, imageData)
+	if len(uploads) > 0 {
+		attachments, err := s.images.UploadAttachments(ctx, comment.Author.ID, uploads)
 		if err != nil {
+			if isBadRequest(err) {
+				return -1, err
+			}
 			raw := fmt.Errorf("%s: upload image: %w", op, err)
 			return -1, svcerr.NewError("failed to upload comment image", raw, svcerr.ErrInternal)
 		}
-		comment.ImagePath = url
+		comment.Attachments = attachments
 	}
 
-	// Транзакция: сохранение комментария + продление expires_at
+	// Транзакция: сохранение комментария + продление expires_at по политике жизни поста
 	var id int64
 	err = s.transactor.WithinTransaction(ctx, func(txCtx context.Context) error {
 		var innerErr error
@@ -75,9 +123,12 @@ func (s *CommentService) SaveComment(ctx context.Context, comment *domain.Commen
 			return svcerr.NewError("failed to save comment", raw, svcerr.ErrInternal)
 		}
 
-		if err := s.postRepo.UpdateExpiresAt(txCtx, comment.PostID, time.Now().Add(15*time.Minute)); err != nil {
-			raw := fmt.Errorf("%s: update post expires: %w", op, err)
-			return svcerr.NewError("failed to update post expiration", raw, svcerr.ErrInternal)
+		expiresAt := s.policy.ExpiryAfterComment(post, now)
+		if !expiresAt.Equal(post.ExpiresAt) {
+			if err := s.postRepo.UpdateExpiresAt(txCtx, comment.PostID, expiresAt); err != nil {
+				raw := fmt.Errorf("%s: update post expires: %w", op, err)
+				return svcerr.NewError("failed to update post expiration", raw, svcerr.ErrInternal)
+			}
 		}
 
 		return nil
@@ -89,12 +140,158 @@ func (s *CommentService) SaveComment(ctx context.Context, comment *domain.Commen
 	return id, nil
 }
 
+// GetByPostID returns the thread of a post as readers see it, deleted comments are tombstones or left out.
+// A deleted post has no thread to show.
 func (s *CommentService) GetByPostID(ctx context.Context, postID int64) ([]*domain.Comment, error) {
 	const op = "CommentService.GetByPostID"
+	post, err := s.postRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		raw := fmt.Errorf("%s: get post: %w", op, err)
+		return nil, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
+	}
+	if post.IsDeleted() {
+		raw := fmt.Errorf("%s: post %d was deleted", op, postID)
+		return nil, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
+	}
 	comments, err := s.commentRepo.GetByPostID(ctx, postID)
 	if err != nil {
 		raw := fmt.Errorf("%s: get comments: %w", op, err)
 		return nil, svcerr.NewError("failed to load comments", raw, svcerr.ErrInternal)
 	}
+	return domain.VisibleComments(comments), nil
+}
+
+// GetRecentComments returns the newest comments across all posts for moderation, deleted ones included.
+func (s *CommentService) GetRecentComments(ctx context.Context, limit int) ([]*domain.Comment, error) {
+	const op = "CommentService.GetRecentComments"
+	if limit <= 0 {
+		limit = domain.DefaultPageSize
+	}
+	limit = min(limit, domain.MaxPageSize)
+	comments, err := s.commentRepo.GetRecent(ctx, limit)
+	if err != nil {
+		raw := fmt.Errorf("%s: get comments: %w", op, err)
+		return nil, svcerr.NewError("failed to load comments", raw, svcerr.ErrInternal)
+	}
 	return comments, nil
 }
+
+// DeleteComment removes a comment on behalf of actor. Its replies stay, beneath a tombstone. Authors may
+// delete their own comments for a while after writing them, staff at any time.
+func (s *CommentService) DeleteComment(ctx context.Context, commentID int64, actor domain.Actor, reason string) error {
+	const op = "CommentService.DeleteComment"
+	if len(reason) > domain.MaxDeleteReasonLength {
+		raw := fmt.Errorf("%s: reason longer than %d chars", op, domain.MaxDeleteReasonLength)
+		return svcerr.NewError("too long reason", raw, svcerr.ErrBadRequest)
+	}
+
+	comment, err := s.getComment(ctx, op, commentID)
+	if err != nil {
+		return err
+	}
+	if comment.IsDeleted() {
+		raw := fmt.Errorf("%s: comment %d was deleted already", op, commentID)
+		return svcerr.NewError("comment was deleted already", raw, svcerr.ErrConflict)
+	}
+
+	now := s.timeSource().UTC()
+	if err := s.deletion.Check(actor, comment.Author.ID, comment.CreatedAt, now); err != nil {
+		raw := fmt.Errorf("%s: %s may not delete comment %d: %w", op, actor, commentID, err)
+		return svcerr.NewError(err.Error(), raw, svcerr.ErrForbidden)
+	}
+
+	deletion := domain.Deletion{At: now, By: actor.String(), Reason: reason}
+	if err := s.commentRepo.DeleteComment(ctx, commentID, deletion); err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return svcerr.NewError("failed to delete comment", raw, svcerr.ErrInternal)
+	}
+	return nil
+}
+
+// CanDeleteComment reports whether actor may delete comment right now, pages only offer deletion when they may.
+func (s *CommentService) CanDeleteComment(comment *domain.Comment, actor domain.Actor) bool {
+	return !comment.IsDeleted() && s.deletion.Check(actor, comment.Author.ID, comment.CreatedAt, s.timeSource().UTC()) == nil
+}
+
+// EditComment replaces the content of a comment on behalf of its author, keeping the old text as a revision.
+// Authors may edit for a while after writing, comments of archived posts are no longer edited.
+func (s *CommentService) EditComment(ctx context.Context, commentID int64, actor domain.Actor, content string) (*domain.Comment, error) {
+	const op = "CommentService.EditComment"
+	if content == "" {
+		err := fmt.Errorf("%s: content is not provided", op)
+		return nil, svcerr.NewError("content is required", err, svcerr.ErrBadRequest)
+	}
+
+	comment, err := s.getComment(ctx, op, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.IsDeleted() {
+		raw := fmt.Errorf("%s: comment %d was deleted", op, commentID)
+		return nil, svcerr.NewError("comment not found", raw, svcerr.ErrNotFound)
+	}
+
+	now := s.timeSource().UTC()
+	if err := s.edit.Check(actor, comment.Author.ID, comment.CreatedAt, now); err != nil {
+		raw := fmt.Errorf("%s: %s may not edit comment %d: %w", op, actor, commentID, err)
+		return nil, svcerr.NewError(err.Error(), raw, svcerr.ErrForbidden)
+	}
+
+	post, err := s.postRepo.GetPostByID(ctx, comment.PostID)
+	if err != nil {
+		raw := fmt.Errorf("%s: get post: %w", op, err)
+		return nil, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
+	}
+	if post.IsDeleted() {
+		raw := fmt.Errorf("%s: post %d was deleted", op, post.ID)
+		return nil, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
+	}
+	if post.ArchivePostIfExpired(now) {
+		raw := fmt.Errorf("%s: post %d is archived", op, post.ID)
+		return nil, svcerr.NewError("post is archived, editing is prohibited", raw, svcerr.ErrBadRequest)
+	}
+	if content == comment.Content {
+		return comment, nil
+	}
+
+	revision := domain.Revision{Content: comment.Content, EditedBy: actor.String(), EditedAt: now}
+	comment.Content, comment.EditedAt = content, now
+	if err := s.commentRepo.EditComment(ctx, comment, revision); err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return nil, svcerr.NewError("failed to edit comment", raw, svcerr.ErrInternal)
+	}
+	return comment, nil
+}
+
+// CanEditComment reports whether actor may edit comment right now, pages only offer editing when they may.
+func (s *CommentService) CanEditComment(comment *domain.Comment, actor domain.Actor) bool {
+	return !comment.IsDeleted() && s.edit.Check(actor, comment.Author.ID, comment.CreatedAt, s.timeSource().UTC()) == nil
+}
+
+// GetCommentHistory returns a comment, deleted or not, with its revisions newest first for moderation.
+func (s *CommentService) GetCommentHistory(ctx context.Context, commentID int64) (*domain.Comment, []domain.Revision, error) {
+	const op = "CommentService.GetCommentHistory"
+	comment, err := s.getComment(ctx, op, commentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	revisions, err := s.commentRepo.GetCommentRevisions(ctx, commentID)
+	if err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return nil, nil, svcerr.NewError("failed to load revisions", raw, svcerr.ErrInternal)
+	}
+	return comment, revisions, nil
+}
+
+func (s *CommentService) getComment(ctx context.Context, op string, commentID int64) (*domain.Comment, error) {
+	comment, err := s.commentRepo.GetByID(ctx, commentID)
+	if err != nil {
+		if errors.Is(err, ErrCommentNotFound) {
+			raw := fmt.Errorf("%s: comment %d: %w", op, commentID, err)
+			return nil, svcerr.NewError("comment not found", raw, svcerr.ErrNotFound)
+		}
+		raw := fmt.Errorf("%s: get comment: %w", op, err)
+		return nil, svcerr.NewError("failed to load comment", raw, svcerr.ErrInternal)
+	}
+	return comment, nil
+}
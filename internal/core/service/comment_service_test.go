@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
 	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
 )
 
 type mockTransactor struct{}
@@ -18,8 +22,12 @@ func (m *mockTransactor) WithinTransaction(ctx context.Context, fn func(ctx cont
 type mockPostRepository struct {
 	saveFunc            func(ctx context.Context, post *domain.Post, userID int64) (int64, error)
 	getFunc             func(ctx context.Context, postID int64) (domain.Post, error)
+	listFunc            func(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error)
+	countFunc           func(ctx context.Context, archived bool) (int64, error)
 	updateExpireFunc    func(ctx context.Context, postID int64, expire_at time.Time) error
-	archieveExpiredfunc func(ctx context.Context) error
+	archieveExpiredfunc func(ctx context.Context, now time.Time) (int64, error)
+	deleteFunc          func(ctx context.Context, postID int64, deletion domain.Deletion) error
+	editFunc            func(ctx context.Context, post *domain.Post, revision domain.Revision) error
 }
 
 func (m *mockPostRepository) SavePost(ctx context.Context, post *domain.Post, userID int64) (int64, error) {
@@ -30,6 +38,9 @@ func (m *mockPostRepository) SavePost(ctx context.Context, post *domain.Post, us
 }
 
 func (m *mockPostRepository) GetActivePosts(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error) {
+	if m.listFunc != nil {
+		return m.listFunc(ctx, pagination)
+	}
 	return []domain.Post{}, nil
 }
 
@@ -37,6 +48,13 @@ func (m *mockPostRepository) GetArchivedPosts(ctx context.Context, pagination *d
 	return []domain.Post{}, nil
 }
 
+func (m *mockPostRepository) CountPosts(ctx context.Context, archived bool) (int64, error) {
+	if m.countFunc != nil {
+		return m.countFunc(ctx, archived)
+	}
+	return 0, nil
+}
+
 func (m *mockPostRepository) GetPostByID(ctx context.Context, postID int64) (domain.Post, error) {
 	if m.getFunc != nil {
 		return m.getFunc(ctx, postID)
@@ -44,6 +62,18 @@ func (m *mockPostRepository) GetPostByID(ctx context.Context, postID int64) (dom
 	return domain.Post{}, nil
 }
 
+func (m *mockPostRepository) GetPostsByIDs(ctx context.Context, ids []int64) ([]domain.Post, error) {
+	var posts []domain.Post
+	for _, id := range ids {
+		post, err := m.GetPostByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
 func (m *mockPostRepository) UpdateExpiresAt(ctx context.Context, postID int64, expires_at time.Time) error {
 	if m.updateExpireFunc != nil {
 		return m.updateExpireFunc(ctx, postID, expires_at)
@@ -51,13 +81,37 @@ func (m *mockPostRepository) UpdateExpiresAt(ctx context.Context, postID int64,
 	return nil
 }
 
-func (m *mockPostRepository) ArchiveExpiredPosts(ctx context.Context) error {
+func (m *mockPostRepository) ArchiveExpiredPosts(ctx context.Context, now time.Time) (int64, error) {
+	if m.archieveExpiredfunc != nil {
+		return m.archieveExpiredfunc(ctx, now)
+	}
+	return 0, nil
+}
+
+func (m *mockPostRepository) DeletePost(ctx context.Context, postID int64, deletion domain.Deletion) error {
+	if m.deleteFunc != nil {
+		return m.deleteFunc(ctx, postID, deletion)
+	}
+	return nil
+}
+
+func (m *mockPostRepository) EditPost(ctx context.Context, post *domain.Post, revision domain.Revision) error {
+	if m.editFunc != nil {
+		return m.editFunc(ctx, post, revision)
+	}
 	return nil
 }
 
+func (m *mockPostRepository) GetPostRevisions(ctx context.Context, postID int64) ([]domain.Revision, error) {
+	return nil, nil
+}
+
 type mockCommentRepository struct {
-	saveFunc func(ctx context.Context, comment *domain.Comment) (int64, error)
-	getFunc  func(ctx context.Context, postID int64) ([]*domain.Comment, error)
+	saveFunc    func(ctx context.Context, comment *domain.Comment) (int64, error)
+	getFunc     func(ctx context.Context, postID int64) ([]*domain.Comment, error)
+	getByIDFunc func(ctx context.Context, commentID int64) (*domain.Comment, error)
+	deleteFunc  func(ctx context.Context, commentID int64, deletion domain.Deletion) error
+	editFunc    func(ctx context.Context, comment *domain.Comment, revision domain.Revision) error
 }
 
 func (m *mockCommentRepository) SaveComment(ctx context.Context, comment *domain.Comment) (int64, error) {
@@ -67,6 +121,25 @@ func (m *mockCommentRepository) SaveComment(ctx context.Context, comment *domain
 	return -2, nil
 }
 
+func (m *mockCommentRepository) GetByID(ctx context.Context, commentID int64) (*domain.Comment, error) {
+	if m.getByIDFunc != nil {
+		return m.getByIDFunc(ctx, commentID)
+	}
+	return nil, ErrCommentNotFound
+}
+
+func (m *mockCommentRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Comment, error) {
+	var comments []*domain.Comment
+	for _, id := range ids {
+		comment, err := m.GetByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
 func (m *mockCommentRepository) GetByPostID(ctx context.Context, postID int64) ([]*domain.Comment, error) {
 	if m.getFunc != nil {
 		return m.getFunc(ctx, postID)
@@ -74,25 +147,92 @@ func (m *mockCommentRepository) GetByPostID(ctx context.Context, postID int64) (
 	return nil, nil
 }
 
+func (m *mockCommentRepository) GetRecent(ctx context.Context, limit int) ([]*domain.Comment, error) {
+	return nil, nil
+}
+
+func (m *mockCommentRepository) DeleteComment(ctx context.Context, commentID int64, deletion domain.Deletion) error {
+	if m.deleteFunc != nil {
+		return m.deleteFunc(ctx, commentID, deletion)
+	}
+	return nil
+}
+
+func (m *mockCommentRepository) EditComment(ctx context.Context, comment *domain.Comment, revision domain.Revision) error {
+	if m.editFunc != nil {
+		return m.editFunc(ctx, comment, revision)
+	}
+	return nil
+}
+
+func (m *mockCommentRepository) GetCommentRevisions(ctx context.Context, commentID int64) ([]domain.Revision, error) {
+	return nil, nil
+}
+
 type mockImageStorage struct {
-	uploadFunc func(ctx context.Context, userID int64, data []byte) (publicURL string, err error)
-	getUrlFunc func(userID int64, code string) string
+	uploadFunc func(ctx context.Context, key string, data []byte) (publicURL string, err error)
+	getUrlFunc func(key string) string
+	deleteFunc func(ctx context.Context, key string) error
+	listFunc   func(ctx context.Context) ([]ImageObject, error)
 }
 
-func (m *mockImageStorage) UploadImage(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
+func (m *mockImageStorage) UploadImage(ctx context.Context, key string, r io.Reader, size int64) (publicURL string, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return "", fmt.Errorf("read %d bytes, announced %d", len(data), size)
+	}
 	if m.uploadFunc != nil {
-		return m.uploadFunc(ctx, userID, data)
+		return m.uploadFunc(ctx, key, data)
 	}
 	return "", nil
 }
 
-func (m *mockImageStorage) GetImageURL(userID int64, code string) string {
+func (m *mockImageStorage) GetImageURL(key string) string {
 	if m.getUrlFunc != nil {
-		return m.getUrlFunc(userID, code)
+		return m.getUrlFunc(key)
 	}
 	return ""
 }
 
+func (m *mockImageStorage) DeleteImage(ctx context.Context, key string) error {
+	if m.deleteFunc != nil {
+		return m.deleteFunc(ctx, key)
+	}
+	return nil
+}
+
+func (m *mockImageStorage) ListImages(ctx context.Context) ([]ImageObject, error) {
+	if m.listFunc != nil {
+		return m.listFunc(ctx)
+	}
+	return nil, nil
+}
+
+type mockImageUploader struct {
+	uploadFunc func(ctx context.Context, userID int64, data []byte) (domain.Image, error)
+}
+
+func (m *mockImageUploader) UploadAttachments(ctx context.Context, userID int64, uploads []domain.AttachmentUpload) ([]domain.Attachment, error) {
+	attachments := make([]domain.Attachment, 0, len(uploads))
+	for i, upload := range uploads {
+		data, err := io.ReadAll(upload.File)
+		if err != nil {
+			return nil, err
+		}
+		var img domain.Image
+		if m.uploadFunc != nil {
+			if img, err = m.uploadFunc(ctx, userID, data); err != nil {
+				return nil, err
+			}
+		}
+		attachments = append(attachments, domain.Attachment{Image: img, AltText: upload.AltText, Position: i})
+	}
+	return attachments, nil
+}
+
 func TestCreateComment_Success(t *testing.T) {
 	mockTransactor := &mockTransactor{}
 
@@ -106,16 +246,17 @@ func TestCreateComment_Success(t *testing.T) {
 			return 1, nil
 		},
 	}
-	imageMock := &mockImageStorage{
-		uploadFunc: func(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
-			return "http://localhost:6969/user-104/dBAvuR", nil
+	imageMock := &mockImageUploader{
+		uploadFunc: func(ctx context.Context, userID int64, data []byte) (domain.Image, error) {
+			return domain.Image{Path: "http://localhost:6969/user-104/dBAvuR"}, nil
 		},
 	}
 
-	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock)
+	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
 	id, err := service.SaveComment(context.Background(), &domain.Comment{
-		PostID: 1,
-	}, []byte(""))
+		PostID:  1,
+		Content: "comment",
+	}, nil)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -137,16 +278,16 @@ func TestCreateComment_Fail_postRepo(t *testing.T) {
 			return 1, nil
 		},
 	}
-	imageMock := &mockImageStorage{
-		uploadFunc: func(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
-			return "http://localhost:6969/user-104/dBAvuR", nil
+	imageMock := &mockImageUploader{
+		uploadFunc: func(ctx context.Context, userID int64, data []byte) (domain.Image, error) {
+			return domain.Image{Path: "http://localhost:6969/user-104/dBAvuR"}, nil
 		},
 	}
 
-	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock)
+	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
 	id, err := service.SaveComment(context.Background(), &domain.Comment{
 		PostID: 1,
-	}, []byte(""))
+	}, nil)
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
@@ -168,16 +309,16 @@ func TestCreateComment_Fail_postComm(t *testing.T) {
 			return -1, fmt.Errorf("error")
 		},
 	}
-	imageMock := &mockImageStorage{
-		uploadFunc: func(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
-			return "http://localhost:6969/user-104/dBAvuR", nil
+	imageMock := &mockImageUploader{
+		uploadFunc: func(ctx context.Context, userID int64, data []byte) (domain.Image, error) {
+			return domain.Image{Path: "http://localhost:6969/user-104/dBAvuR"}, nil
 		},
 	}
 
-	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock)
+	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
 	id, err := service.SaveComment(context.Background(), &domain.Comment{
 		PostID: 1,
-	}, []byte(""))
+	}, nil)
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
@@ -199,16 +340,17 @@ func TestCreateComment_Success_imgSource(t *testing.T) {
 			return 1, nil
 		},
 	}
-	imageMock := &mockImageStorage{
-		uploadFunc: func(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
-			return "", fmt.Errorf("error")
+	imageMock := &mockImageUploader{
+		uploadFunc: func(ctx context.Context, userID int64, data []byte) (domain.Image, error) {
+			return domain.Image{}, fmt.Errorf("error")
 		},
 	}
 
-	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock)
+	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
 	id, err := service.SaveComment(context.Background(), &domain.Comment{
-		PostID: 1,
-	}, []byte(""))
+		PostID:  1,
+		Content: "comment",
+	}, nil)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -230,16 +372,16 @@ func TestCreateComment_Fail_imgSource(t *testing.T) {
 			return 1, nil
 		},
 	}
-	imageMock := &mockImageStorage{
-		uploadFunc: func(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
-			return "", fmt.Errorf("error")
+	imageMock := &mockImageUploader{
+		uploadFunc: func(ctx context.Context, userID int64, data []byte) (domain.Image, error) {
+			return domain.Image{}, fmt.Errorf("error")
 		},
 	}
 
-	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock)
+	service := NewCommentService(mockTransactor, repoComment, repoPost, imageMock, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
 	id, err := service.SaveComment(context.Background(), &domain.Comment{
 		PostID: 1,
-	}, []byte("X"))
+	}, []domain.AttachmentUpload{{File: strings.NewReader("X"), Size: 1}})
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
@@ -247,3 +389,288 @@ func TestCreateComment_Fail_imgSource(t *testing.T) {
 		t.Fatalf("expected id -1, got %d", id)
 	}
 }
+
+func TestCreateComment_Success_reply(t *testing.T) {
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return domain.Post{ID: 1}, nil
+		},
+	}
+	var saved *domain.Comment
+	repoComment := &mockCommentRepository{
+		saveFunc: func(ctx context.Context, comment *domain.Comment) (int64, error) {
+			saved = comment
+			return 2, nil
+		},
+		getByIDFunc: func(ctx context.Context, commentID int64) (*domain.Comment, error) {
+			return &domain.Comment{ID: commentID, PostID: 1}, nil
+		},
+	}
+
+	service := NewCommentService(&mockTransactor{}, repoComment, repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
+	parentID := int64(1)
+	id, err := service.SaveComment(context.Background(), &domain.Comment{
+		PostID:          1,
+		ParentCommentID: &parentID,
+		Content:         "reply",
+	}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if id != 2 {
+		t.Fatalf("expected id 2, got %d", id)
+	}
+	if saved == nil || saved.ParentCommentID == nil || *saved.ParentCommentID != parentID {
+		t.Fatalf("expected parent comment id %d to be saved, got %v", parentID, saved)
+	}
+}
+
+func TestCreateComment_Fail_parentNotFound(t *testing.T) {
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return domain.Post{ID: 1}, nil
+		},
+	}
+	repoComment := &mockCommentRepository{
+		saveFunc: func(ctx context.Context, comment *domain.Comment) (int64, error) {
+			t.Fatalf("comment must not be saved")
+			return -1, nil
+		},
+	}
+
+	service := NewCommentService(&mockTransactor{}, repoComment, repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
+	parentID := int64(42)
+	id, err := service.SaveComment(context.Background(), &domain.Comment{
+		PostID:          1,
+		ParentCommentID: &parentID,
+		Content:         "reply",
+	}, nil)
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+	if id != -1 {
+		t.Fatalf("expected id -1, got %d", id)
+	}
+}
+
+func TestCreateComment_Fail_parentOtherPost(t *testing.T) {
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return domain.Post{ID: 1}, nil
+		},
+	}
+	repoComment := &mockCommentRepository{
+		saveFunc: func(ctx context.Context, comment *domain.Comment) (int64, error) {
+			t.Fatalf("comment must not be saved")
+			return -1, nil
+		},
+		getByIDFunc: func(ctx context.Context, commentID int64) (*domain.Comment, error) {
+			return &domain.Comment{ID: commentID, PostID: 2}, nil
+		},
+	}
+
+	service := NewCommentService(&mockTransactor{}, repoComment, repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
+	parentID := int64(7)
+	id, err := service.SaveComment(context.Background(), &domain.Comment{
+		PostID:          1,
+		ParentCommentID: &parentID,
+		Content:         "reply",
+	}, nil)
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+	if id != -1 {
+		t.Fatalf("expected id -1, got %d", id)
+	}
+}
+
+func TestCreateComment_Success_extendsPost(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	var extendedTo time.Time
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return domain.Post{ID: 1, CreatedAt: now.Add(-5 * time.Minute), ExpiresAt: now.Add(5 * time.Minute)}, nil
+		},
+		updateExpireFunc: func(ctx context.Context, postID int64, expiresAt time.Time) error {
+			extendedTo = expiresAt
+			return nil
+		},
+	}
+	repoComment := &mockCommentRepository{
+		saveFunc: func(ctx context.Context, comment *domain.Comment) (int64, error) {
+			return 1, nil
+		},
+	}
+
+	service := NewCommentService(&mockTransactor{}, repoComment, repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), func() time.Time { return now })
+	if _, err := service.SaveComment(context.Background(), &domain.Comment{PostID: 1, Content: "comment"}, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !extendedTo.Equal(now.Add(15 * time.Minute)) {
+		t.Fatalf("expected post extended to %v, got %v", now.Add(15*time.Minute), extendedTo)
+	}
+}
+
+func TestCreateComment_Fail_expiredPost(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return domain.Post{ID: 1, CreatedAt: now.Add(-20 * time.Minute), ExpiresAt: now.Add(-time.Minute)}, nil
+		},
+	}
+	repoComment := &mockCommentRepository{
+		saveFunc: func(ctx context.Context, comment *domain.Comment) (int64, error) {
+			t.Fatalf("comment must not be saved")
+			return -1, nil
+		},
+	}
+
+	service := NewCommentService(&mockTransactor{}, repoComment, repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), func() time.Time { return now })
+	id, err := service.SaveComment(context.Background(), &domain.Comment{PostID: 1, Content: "comment"}, nil)
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+	if id != -1 {
+		t.Fatalf("expected id -1, got %d", id)
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	comment := &domain.Comment{ID: 3, PostID: 1, Author: domain.UserData{ID: 7}, CreatedAt: now.Add(-time.Minute)}
+	repoComment := &mockCommentRepository{
+		getByIDFunc: func(ctx context.Context, commentID int64) (*domain.Comment, error) {
+			return comment, nil
+		},
+		deleteFunc: func(ctx context.Context, commentID int64, deletion domain.Deletion) error {
+			comment.Deleted = &deletion
+			return nil
+		},
+	}
+	service := NewCommentService(&mockTransactor{}, repoComment, &mockPostRepository{}, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), func() time.Time { return now })
+	ctx := context.Background()
+
+	expectForbidden(t, service.DeleteComment(ctx, 3, domain.Actor{UserID: 8}, ""))
+	if err := service.DeleteComment(ctx, 3, domain.Actor{UserID: 7}, ""); err != nil {
+		t.Fatalf("expected the author to delete the comment, got %v", err)
+	}
+	if comment.Deleted == nil || comment.Deleted.By != "user:7" {
+		t.Fatalf("unexpected deletion %+v", comment.Deleted)
+	}
+
+	var svcErr *svcerr.Error
+	if err := service.DeleteComment(ctx, 3, domain.Actor{Moderator: "mod"}, ""); !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrConflict {
+		t.Fatalf("expected a conflict deleting twice, got %v", err)
+	}
+	if service.CanDeleteComment(comment, domain.Actor{Moderator: "mod"}) {
+		t.Fatalf("expected a deleted comment not to be offered for deletion")
+	}
+
+	// a deleted comment takes no new replies
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return domain.Post{ID: 1, ExpiresAt: now.Add(time.Hour)}, nil
+		},
+	}
+	service = NewCommentService(&mockTransactor{}, repoComment, repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), func() time.Time { return now })
+	parentID := comment.ID
+	_, err := service.SaveComment(ctx, &domain.Comment{PostID: 1, ParentCommentID: &parentID, Content: "reply"}, nil)
+	expectBadRequest(t, err)
+}
+
+func TestGetByPostID_tombstones(t *testing.T) {
+	parentID := int64(1)
+	repoComment := &mockCommentRepository{
+		getFunc: func(ctx context.Context, postID int64) ([]*domain.Comment, error) {
+			return []*domain.Comment{
+				{ID: 1, PostID: 1, Content: "removed", Deleted: &domain.Deletion{By: "moderator:mod", Reason: "spam"}},
+				{ID: 2, PostID: 1, ParentCommentID: &parentID, Content: "reply"},
+			}, nil
+		},
+	}
+	service := NewCommentService(&mockTransactor{}, repoComment, &mockPostRepository{}, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
+
+	comments, err := service.GetByPostID(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(comments) != 2 || comments[0].Content != "" || !comments[0].IsDeleted() || comments[1].Content != "reply" {
+		t.Fatalf("expected the reply beneath a tombstone, got %+v", comments)
+	}
+}
+
+func TestGetByPostID_Fail_deletedPost(t *testing.T) {
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return domain.Post{ID: postID, IsArchived: true, Deleted: &domain.Deletion{By: "moderator:mod"}}, nil
+		},
+	}
+	repoComment := &mockCommentRepository{
+		getFunc: func(ctx context.Context, postID int64) ([]*domain.Comment, error) {
+			return []*domain.Comment{{ID: 1, PostID: postID, Content: "still here"}}, nil
+		},
+	}
+	service := NewCommentService(&mockTransactor{}, repoComment, repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
+
+	comments, err := service.GetByPostID(context.Background(), 1)
+	if comments != nil {
+		t.Fatalf("expected no comments, got %+v", comments)
+	}
+	expectNotFound(t, err)
+}
+
+func TestEditComment(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	comment := &domain.Comment{ID: 3, PostID: 1, Content: "old", Author: domain.UserData{ID: 7}, CreatedAt: now.Add(-time.Minute)}
+	post := domain.Post{ID: 1, ExpiresAt: now.Add(time.Hour)}
+	var revisions []domain.Revision
+	repoComment := &mockCommentRepository{
+		getByIDFunc: func(ctx context.Context, commentID int64) (*domain.Comment, error) {
+			return comment, nil
+		},
+		editFunc: func(ctx context.Context, edited *domain.Comment, revision domain.Revision) error {
+			revisions = append(revisions, revision)
+			return nil
+		},
+	}
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return post, nil
+		},
+	}
+	service := NewCommentService(&mockTransactor{}, repoComment, repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), func() time.Time { return now })
+	ctx := context.Background()
+
+	_, err := service.EditComment(ctx, 3, domain.Actor{UserID: 8}, "new")
+	expectForbidden(t, err)
+	_, err = service.EditComment(ctx, 3, domain.Actor{Moderator: "mod"}, "new")
+	expectForbidden(t, err)
+	_, err = service.EditComment(ctx, 3, domain.Actor{UserID: 7}, "")
+	expectBadRequest(t, err)
+
+	edited, err := service.EditComment(ctx, 3, domain.Actor{UserID: 7}, "new")
+	if err != nil {
+		t.Fatalf("expected the author to edit the comment, got %v", err)
+	}
+	if edited.Content != "new" || !edited.IsEdited() {
+		t.Fatalf("unexpected edited comment %+v", edited)
+	}
+	if len(revisions) != 1 || revisions[0].Content != "old" || revisions[0].EditedBy != "user:7" || !revisions[0].EditedAt.Equal(now) {
+		t.Fatalf("expected the old text in the revision, got %+v", revisions)
+	}
+
+	post.IsArchived = true
+	_, err = service.EditComment(ctx, 3, domain.Actor{UserID: 7}, "newer")
+	expectBadRequest(t, err)
+
+	post.IsArchived = false
+	post.Deleted = &domain.Deletion{By: "moderator:mod"}
+	_, err = service.EditComment(ctx, 3, domain.Actor{UserID: 7}, "newer")
+	expectNotFound(t, err)
+	post.Deleted = nil
+
+	post.IsArchived = false
+	now = now.Add(time.Hour)
+	_, err = service.EditComment(ctx, 3, domain.Actor{UserID: 7}, "newer")
+	expectForbidden(t, err)
+}
@@ -1,9 +1,23 @@
 package service
 
-import "errors"
+import (
+	"errors"
+
+	"go-hex-forum/pkg/svcerr"
+)
 
 var (
-	ErrSessionNotFound = errors.New("session not found")
-	ErrSessionExpired  = errors.New("session expired")
-	ErrNotImplemented  = errors.New("not implemented")
+	ErrSessionNotFound   = errors.New("session not found")
+	ErrSessionExpired    = errors.New("session expired")
+	ErrNotImplemented    = errors.New("not implemented")
+	ErrCommentNotFound   = errors.New("comment not found")
+	ErrImageNotFound     = errors.New("image not found")
+	ErrChallengeNotFound = errors.New("challenge not found")
+	ErrDuplicateReport   = errors.New("report already open")
 )
+
+// isBadRequest reports whether err already carries a client-facing validation failure.
+func isBadRequest(err error) bool {
+	var svcErr *svcerr.Error
+	return errors.As(err, &svcErr) && svcErr.AppErr == svcerr.ErrBadRequest
+}
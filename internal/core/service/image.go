@@ -0,0 +1,380 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/imaging"
+	"go-hex-forum/pkg/svcerr"
+)
+
+// ImageStorage keeps image objects under content-derived keys.
+type ImageStorage interface {
+	// UploadImage streams size bytes of r into the object. r also implements io.Seeker,
+	// so a backend may rewind it to retry.
+	UploadImage(ctx context.Context, key string, r io.Reader, size int64) (publicURL string, err error)
+	GetImageURL(key string) string
+	// DeleteImage removes an object, deleting a missing object is not an error.
+	DeleteImage(ctx context.Context, key string) error
+	ListImages(ctx context.Context) ([]ImageObject, error)
+}
+
+// ImageObject describes an object as found in the storage.
+type ImageObject struct {
+	Key        string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// Variants are stored next to the original under its hash with a suffix.
+const (
+	thumbnailKeySuffix = "-thumb"
+	previewKeySuffix   = "-preview"
+)
+
+// ImageUploader is the part of the image pipeline used by post and comment services.
+type ImageUploader interface {
+	UploadAttachments(ctx context.Context, userID int64, uploads []domain.AttachmentUpload) ([]domain.Attachment, error)
+}
+
+type ImageRepository interface {
+	// AcquireImage marks a stored image as used again, it returns ErrImageNotFound for an unknown hash.
+	AcquireImage(ctx context.Context, hash string) (domain.ImageRecord, error)
+	// SaveImage records a new image. When the hash is already known the existing record is acquired
+	// and copied into record instead.
+	SaveImage(ctx context.Context, record *domain.ImageRecord) error
+}
+
+// ImageService validates uploaded images and stores them together with their downscaled variants.
+// Images are keyed by the SHA-256 of their normalized content, so identical uploads are stored once.
+type ImageService struct {
+	storage ImageStorage
+	repo    ImageRepository
+	policy  domain.ImagePolicy
+}
+
+func NewImageService(storage ImageStorage, repo ImageRepository, policy domain.ImagePolicy) *ImageService {
+	return &ImageService{storage, repo, policy}
+}
+
+// UploadAttachments uploads the images of a new post or comment in order. The number of attachments,
+// their alt texts and announced sizes are checked before anything is read. Images stored before a later
+// upload fails stay unreferenced and are removed by the sweeper.
+func (s *ImageService) UploadAttachments(ctx context.Context, userID int64, uploads []domain.AttachmentUpload) ([]domain.Attachment, error) {
+	const op = "ImageService.UploadAttachments"
+
+	if s.policy.MaxAttachments > 0 && len(uploads) > s.policy.MaxAttachments {
+		raw := fmt.Errorf("%s: %d attachments, limit %d", op, len(uploads), s.policy.MaxAttachments)
+		return nil, svcerr.NewError(fmt.Sprintf("too many images, at most %d are allowed", s.policy.MaxAttachments), raw, svcerr.ErrBadRequest)
+	}
+	for i := range uploads {
+		uploads[i].AltText = strings.TrimSpace(uploads[i].AltText)
+		if utf8.RuneCountInString(uploads[i].AltText) > domain.MaxAltTextLength {
+			raw := fmt.Errorf("%s: alt text of image %d is too long", op, i+1)
+			return nil, svcerr.NewError(fmt.Sprintf("alt text is too long, maximum is %d characters", domain.MaxAltTextLength), raw, svcerr.ErrBadRequest)
+		}
+		if err := s.checkSize(uploads[i].Size); err != nil {
+			return nil, attachmentError(err, i, len(uploads))
+		}
+	}
+
+	attachments := make([]domain.Attachment, 0, len(uploads))
+	for i, upload := range uploads {
+		img, err := s.UploadImage(ctx, userID, upload.File, upload.Size)
+		if err != nil {
+			return nil, attachmentError(err, i, len(uploads))
+		}
+		attachments = append(attachments, domain.Attachment{Image: img, AltText: upload.AltText, Position: i})
+	}
+
+	return attachments, nil
+}
+
+// attachmentError tells which of several images an error is about.
+func attachmentError(err error, i, total int) error {
+	var svcErr *svcerr.Error
+	if total < 2 || !errors.As(err, &svcErr) {
+		return err
+	}
+	return svcerr.NewError(fmt.Sprintf("image %d: %s", i+1, svcErr.Message), svcErr.RawErr, svcErr.AppErr)
+}
+
+// UploadImage validates, sanitizes and stores a single image. size is the length announced by the client,
+// or -1 when it is unknown.
+func (s *ImageService) UploadImage(ctx context.Context, userID int64, r io.Reader, size int64) (domain.Image, error) {
+	const op = "ImageService.UploadImage"
+
+	// an upload announced as too large is rejected before anything is read
+	if err := s.checkSize(size); err != nil {
+		return domain.Image{}, err
+	}
+	src, err := s.spool(r, size)
+	if err != nil {
+		raw := fmt.Errorf("%s: spool upload: %w", op, err)
+		return domain.Image{}, svcerr.NewError("failed to upload image", raw, svcerr.ErrInternal)
+	}
+	defer src.Close()
+
+	info, decoded, err := s.validate(src)
+	if err != nil {
+		return domain.Image{}, err
+	}
+
+	// anonymous authors must not leak their location or camera through EXIF, XMP or ICC blocks
+	clean, hash, decoded, err := sanitize(src, info.MIMEType, decoded)
+	if err != nil {
+		raw := fmt.Errorf("%s: strip metadata: %w", op, err)
+		if errors.Is(err, imaging.ErrCorrupted) {
+			return domain.Image{}, svcerr.NewError("image is corrupted", raw, svcerr.ErrBadRequest)
+		}
+		return domain.Image{}, svcerr.NewError("failed to upload image", raw, svcerr.ErrInternal)
+	}
+	defer clean.Close()
+
+	// the same content was uploaded before, reuse the stored objects
+	record, err := s.repo.AcquireImage(ctx, hash)
+	if err == nil {
+		return record.Image, nil
+	}
+	if !errors.Is(err, ErrImageNotFound) {
+		raw := fmt.Errorf("%s: acquire image: %w", op, err)
+		return domain.Image{}, svcerr.NewError("failed to upload image", raw, svcerr.ErrInternal)
+	}
+
+	img, err := s.store(ctx, hash, clean, info.MIMEType, decoded)
+	if err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return domain.Image{}, svcerr.NewError("failed to upload image", raw, svcerr.ErrInternal)
+	}
+
+	img.Hash = hash
+	record = domain.ImageRecord{
+		Image:    img,
+		OwnerID:  userID,
+		Size:     clean.Size(),
+		MIMEType: info.MIMEType,
+	}
+	if err := s.repo.SaveImage(ctx, &record); err != nil {
+		raw := fmt.Errorf("%s: save image: %w", op, err)
+		return domain.Image{}, svcerr.NewError("failed to upload image", raw, svcerr.ErrInternal)
+	}
+
+	return record.Image, nil
+}
+
+// store uploads the original under its hash and the variants under the hash with a size suffix.
+func (s *ImageService) store(ctx context.Context, hash string, src imaging.Source, mimeType string, decoded image.Image) (domain.Image, error) {
+	var (
+		img domain.Image
+		err error
+	)
+	img.Path, err = s.storage.UploadImage(ctx, hash, io.NewSectionReader(src, 0, src.Size()), src.Size())
+	if err != nil {
+		return domain.Image{}, fmt.Errorf("original: %w", err)
+	}
+
+	// the thumbnail is made from the preview, so the full-size image is copied for resizing only once
+	var preview image.Image
+	img.PreviewPath, preview, err = s.uploadVariant(ctx, hash+previewKeySuffix, decoded, mimeType, s.policy.PreviewSize)
+	if err != nil {
+		return domain.Image{}, fmt.Errorf("preview: %w", err)
+	}
+	if s.policy.ThumbnailSize > s.policy.PreviewSize {
+		preview = decoded
+	}
+	img.ThumbnailPath, _, err = s.uploadVariant(ctx, hash+thumbnailKeySuffix, preview, mimeType, s.policy.ThumbnailSize)
+	if err != nil {
+		return domain.Image{}, fmt.Errorf("thumbnail: %w", err)
+	}
+
+	return img, nil
+}
+
+// sanitize writes src without its metadata to a scratch file and hashes the result on the way.
+// A JPEG whose EXIF orientation is not upright is rotated and re-encoded instead, otherwise it would be
+// displayed sideways once the tag is gone.
+func sanitize(src imaging.Source, mimeType string, img image.Image) (*scratch, string, image.Image, error) {
+	hash := sha256.New()
+	orientation := imaging.Orientation(src)
+
+	clean, err := newScratch(func(w io.Writer) error {
+		w = io.MultiWriter(w, hash)
+		if orientation > 1 {
+			img = imaging.Orient(img, orientation)
+			return imaging.Encode(w, img, mimeType)
+		}
+		return imaging.StripMetadata(w, src, mimeType)
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return clean, hex.EncodeToString(hash.Sum(nil)), img, nil
+}
+
+// uploadVariant stores a copy of img downscaled to maxSide and returns it. It returns an empty path and img
+// itself when img already fits. Variants are small enough to be encoded in memory.
+func (s *ImageService) uploadVariant(ctx context.Context, key string, img image.Image, mimeType string, maxSide int64) (string, image.Image, error) {
+	small, ok := imaging.Downscale(img, int(maxSide))
+	if !ok {
+		return "", img, nil
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, small, mimeType); err != nil {
+		return "", nil, err
+	}
+	path, err := s.storage.UploadImage(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	return path, small, err
+}
+
+// spool makes an upload readable more than once. Readers with random access, such as multipart files,
+// are used in place, anything else is copied to a scratch file. At most one byte more than the size limit
+// is read, which is enough for validate to reject the upload.
+func (s *ImageService) spool(r io.Reader, size int64) (spooled, error) {
+	if ra, ok := r.(io.ReaderAt); ok && size >= 0 {
+		return nopCloser{io.NewSectionReader(ra, 0, size)}, nil
+	}
+
+	return newScratch(func(w io.Writer) error {
+		if s.policy.MaxBytes > 0 {
+			r = io.LimitReader(r, s.policy.MaxBytes+1)
+		}
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+// spooled is an upload being processed, it is closed once the upload is done.
+type spooled interface {
+	imaging.Source
+	io.Closer
+}
+
+type nopCloser struct {
+	*io.SectionReader
+}
+
+func (nopCloser) Close() error { return nil }
+
+// scratch is a temporary file holding an upload while it is processed, so large images are never kept in memory.
+type scratch struct {
+	*io.SectionReader
+	file *os.File
+}
+
+// newScratch creates a temporary file and fills it with whatever fill writes.
+func newScratch(fill func(w io.Writer) error) (*scratch, error) {
+	f, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp := &scratch{file: f}
+
+	if err := fill(f); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	tmp.SectionReader = io.NewSectionReader(f, 0, size)
+
+	return tmp, nil
+}
+
+// Close removes the temporary file.
+func (s *scratch) Close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// Validate sniffs the real format of src and checks it against the configured limits.
+func (s *ImageService) Validate(src imaging.Source) (imaging.Info, error) {
+	info, _, err := s.validate(src)
+	return info, err
+}
+
+// checkSize rejects uploads over the size limit.
+func (s *ImageService) checkSize(size int64) error {
+	if s.policy.MaxBytes > 0 && size > s.policy.MaxBytes {
+		raw := fmt.Errorf("ImageService.Validate: image is %d bytes, limit %d", size, s.policy.MaxBytes)
+		return svcerr.NewError(fmt.Sprintf("image is too large, maximum size is %s", formatBytes(s.policy.MaxBytes)), raw, svcerr.ErrBadRequest)
+	}
+	return nil
+}
+
+// validate also returns the decoded image.
+func (s *ImageService) validate(src imaging.Source) (imaging.Info, image.Image, error) {
+	const op = "ImageService.Validate"
+
+	if err := s.checkSize(src.Size()); err != nil {
+		return imaging.Info{}, nil, err
+	}
+
+	header := make([]byte, min(src.Size(), imaging.SniffLen))
+	if _, err := src.ReadAt(header, 0); err != nil && err != io.EOF {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return imaging.Info{}, nil, svcerr.NewError("failed to read image", raw, svcerr.ErrInternal)
+	}
+	mimeType := imaging.Sniff(header)
+	if !slices.Contains(s.policy.AllowedTypes, mimeType) {
+		raw := fmt.Errorf("%s: %s is not allowed", op, mimeType)
+		return imaging.Info{}, nil, svcerr.NewError("unsupported image format, allowed formats are JPEG, PNG, GIF and WebP", raw, svcerr.ErrBadRequest)
+	}
+
+	info, err := imaging.DecodeConfig(src)
+	if err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, imaging.ErrUnsupportedFormat) {
+			return imaging.Info{}, nil, svcerr.NewError("unsupported image format", raw, svcerr.ErrBadRequest)
+		}
+		return imaging.Info{}, nil, svcerr.NewError("image is corrupted", raw, svcerr.ErrBadRequest)
+	}
+
+	if (s.policy.MaxWidth > 0 && int64(info.Width) > s.policy.MaxWidth) || (s.policy.MaxHeight > 0 && int64(info.Height) > s.policy.MaxHeight) {
+		raw := fmt.Errorf("%s: image is %dx%d, limit %dx%d", op, info.Width, info.Height, s.policy.MaxWidth, s.policy.MaxHeight)
+		return imaging.Info{}, nil, svcerr.NewError(fmt.Sprintf("image dimensions are too large, maximum is %dx%d pixels", s.policy.MaxWidth, s.policy.MaxHeight), raw, svcerr.ErrBadRequest)
+	}
+
+	if s.policy.MaxDecodeMemory > 0 && info.DecodeMemory() > s.policy.MaxDecodeMemory {
+		raw := fmt.Errorf("%s: decoding takes %d bytes, limit %d", op, info.DecodeMemory(), s.policy.MaxDecodeMemory)
+		return imaging.Info{}, nil, svcerr.NewError("image has too many pixels to process", raw, svcerr.ErrBadRequest)
+	}
+
+	// dimensions are checked first so decoding cannot be abused as a decompression bomb
+	img, err := imaging.Decode(src, info.MIMEType)
+	if err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, imaging.ErrTooManyFrames) {
+			return imaging.Info{}, nil, svcerr.NewError(fmt.Sprintf("animation has too many frames, maximum is %d", imaging.MaxGIFFrames), raw, svcerr.ErrBadRequest)
+		}
+		return imaging.Info{}, nil, svcerr.NewError("image is corrupted", raw, svcerr.ErrBadRequest)
+	}
+
+	return info, img, nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
@@ -0,0 +1,617 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/imaging"
+	"go-hex-forum/pkg/svcerr"
+)
+
+func testImagePolicy() domain.ImagePolicy {
+	return domain.ImagePolicy{
+		MaxBytes:     1 << 20,
+		MaxWidth:     64,
+		MaxHeight:    64,
+		AllowedTypes: []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+	}
+}
+
+// mockImageRepository keeps image records in memory, keyed by hash, and counts how often known images were acquired.
+type mockImageRepository struct {
+	records  map[string]domain.ImageRecord
+	acquired int
+}
+
+func newMockImageRepository() *mockImageRepository {
+	return &mockImageRepository{records: make(map[string]domain.ImageRecord)}
+}
+
+func (m *mockImageRepository) AcquireImage(ctx context.Context, hash string) (domain.ImageRecord, error) {
+	record, ok := m.records[hash]
+	if !ok {
+		return domain.ImageRecord{}, ErrImageNotFound
+	}
+	m.acquired++
+	return record, nil
+}
+
+func (m *mockImageRepository) SaveImage(ctx context.Context, record *domain.ImageRecord) error {
+	if existing, ok := m.records[record.Image.Hash]; ok {
+		m.acquired++
+		*record = existing
+	}
+	m.records[record.Image.Hash] = *record
+	return nil
+}
+
+type failingImageRepository struct{}
+
+func (failingImageRepository) AcquireImage(ctx context.Context, hash string) (domain.ImageRecord, error) {
+	return domain.ImageRecord{}, fmt.Errorf("connection refused")
+}
+
+func (failingImageRepository) SaveImage(ctx context.Context, record *domain.ImageRecord) error {
+	return fmt.Errorf("connection refused")
+}
+
+// uploadBytes uploads data the way a multipart file is passed in, with random access and a known size.
+func uploadBytes(service *ImageService, userID int64, data []byte) (domain.Image, error) {
+	return service.UploadImage(context.Background(), userID, bytes.NewReader(data), int64(len(data)))
+}
+
+func pngBytes(t testing.TB, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func expectBadRequest(t *testing.T, err error) {
+	t.Helper()
+	var svcErr *svcerr.Error
+	if !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrBadRequest {
+		t.Fatalf("expected bad request error, got %v", err)
+	}
+}
+
+func TestImageService_UploadImage_Success(t *testing.T) {
+	uploaded := 0
+	imageMock := &mockImageStorage{
+		uploadFunc: func(ctx context.Context, key string, data []byte) (publicURL string, err error) {
+			uploaded++
+			return "http://localhost:6969/user-1/aBc", nil
+		},
+	}
+
+	service := NewImageService(imageMock, newMockImageRepository(), testImagePolicy())
+	img, err := uploadBytes(service, 1, pngBytes(t, 16, 16))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if uploaded != 1 || img.Path == "" {
+		t.Fatalf("expected only the original to be uploaded, got %d uploads", uploaded)
+	}
+	if img.ThumbnailPath != "" || img.PreviewPath != "" {
+		t.Fatalf("expected no variants for a small image, got %+v", img)
+	}
+}
+
+func TestImageService_UploadImage_variants(t *testing.T) {
+	policy := testImagePolicy()
+	policy.MaxWidth, policy.MaxHeight = 1024, 1024
+	policy.ThumbnailSize, policy.PreviewSize = 100, 300
+
+	var sizes []string
+	imageMock := &mockImageStorage{
+		uploadFunc: func(ctx context.Context, key string, data []byte) (publicURL string, err error) {
+			info, err := imaging.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("uploaded variant is not an image: %v", err)
+			}
+			size := fmt.Sprintf("%dx%d", info.Width, info.Height)
+			sizes = append(sizes, size)
+			return "/media/images/" + key + "/" + size, nil
+		},
+	}
+
+	data := pngBytes(t, 600, 400)
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	service := NewImageService(imageMock, newMockImageRepository(), policy)
+	img, err := uploadBytes(service, 1, data)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := domain.Image{
+		Hash:          hash,
+		Path:          "/media/images/" + hash + "/600x400",
+		ThumbnailPath: "/media/images/" + hash + "-thumb/100x66",
+		PreviewPath:   "/media/images/" + hash + "-preview/300x200",
+	}
+	if img != want {
+		t.Fatalf("expected %+v, got %+v (uploaded %v)", want, img, sizes)
+	}
+}
+
+func TestImageService_UploadImage_deduplicates(t *testing.T) {
+	uploads := 0
+	imageMock := &mockImageStorage{
+		uploadFunc: func(ctx context.Context, key string, data []byte) (publicURL string, err error) {
+			uploads++
+			return "/media/images/" + key, nil
+		},
+	}
+	repo := newMockImageRepository()
+	service := NewImageService(imageMock, repo, testImagePolicy())
+
+	// the same picture uploaded with and without camera metadata is stored once
+	first, err := uploadBytes(service, 1, jpegWithExif(t, 40, 20, 1))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for userID, data := range map[int64][]byte{2: jpegWithExif(t, 40, 20, 1), 3: plainJPEG(t, 40, 20)} {
+		img, err := uploadBytes(service, userID, data)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if img != first {
+			t.Fatalf("expected %+v, got %+v", first, img)
+		}
+	}
+
+	if uploads != 1 {
+		t.Fatalf("expected a single stored object, got %d", uploads)
+	}
+	if len(repo.records) != 1 || repo.acquired != 2 {
+		t.Fatalf("expected a single image record acquired twice, got %d records acquired %d times", len(repo.records), repo.acquired)
+	}
+	for _, record := range repo.records {
+		if record.OwnerID != 1 || record.MIMEType != "image/jpeg" {
+			t.Fatalf("unexpected record %+v", record)
+		}
+	}
+}
+
+func TestImageService_UploadImage_Fail_repository(t *testing.T) {
+	repo := &failingImageRepository{}
+	service := NewImageService(&mockImageStorage{}, repo, testImagePolicy())
+
+	_, err := uploadBytes(service, 1, pngBytes(t, 16, 16))
+	var svcErr *svcerr.Error
+	if !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrInternal {
+		t.Fatalf("expected internal error, got %v", err)
+	}
+}
+
+func TestImageService_UploadImage_Fail_variant(t *testing.T) {
+	policy := testImagePolicy()
+	policy.ThumbnailSize = 8
+
+	calls := 0
+	imageMock := &mockImageStorage{
+		uploadFunc: func(ctx context.Context, key string, data []byte) (publicURL string, err error) {
+			calls++
+			if calls > 1 {
+				return "", fmt.Errorf("storage is down")
+			}
+			return "http://localhost:6969/user-1/aBc", nil
+		},
+	}
+
+	service := NewImageService(imageMock, newMockImageRepository(), policy)
+	_, err := uploadBytes(service, 1, pngBytes(t, 16, 16))
+	var svcErr *svcerr.Error
+	if !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrInternal {
+		t.Fatalf("expected internal error, got %v", err)
+	}
+}
+
+func TestImageService_Validate_Fail(t *testing.T) {
+	valid := pngBytes(t, 16, 16)
+	cases := map[string][]byte{
+		"text":       []byte("definitely not an image"),
+		"too large":  append(valid, make([]byte, 1<<20)...),
+		"dimensions": pngBytes(t, 65, 10),
+		"corrupted":  valid[:len(valid)-20],
+	}
+
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			imageMock := &mockImageStorage{
+				uploadFunc: func(ctx context.Context, key string, data []byte) (publicURL string, err error) {
+					t.Fatalf("invalid image must not be uploaded")
+					return "", nil
+				},
+			}
+			service := NewImageService(imageMock, newMockImageRepository(), testImagePolicy())
+			_, err := uploadBytes(service, 1, data)
+			expectBadRequest(t, err)
+		})
+	}
+}
+
+// countingReader hides any random access of r and counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestImageService_UploadImage_stream(t *testing.T) {
+	data := pngBytes(t, 16, 16)
+
+	var stored []byte
+	imageMock := &mockImageStorage{
+		uploadFunc: func(ctx context.Context, key string, data []byte) (publicURL string, err error) {
+			stored = data
+			return "/media/images/" + key, nil
+		},
+	}
+	service := NewImageService(imageMock, newMockImageRepository(), testImagePolicy())
+
+	img, err := service.UploadImage(context.Background(), 1, &countingReader{r: bytes.NewReader(data)}, -1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	sum := sha256.Sum256(stored)
+	if img.Hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected the hash of the stored object, got %s", img.Hash)
+	}
+}
+
+func TestImageService_UploadImage_stream_tooLarge(t *testing.T) {
+	policy := testImagePolicy()
+	service := NewImageService(&mockImageStorage{}, newMockImageRepository(), policy)
+
+	// an unannounced stream is read just past the limit
+	stream := &countingReader{r: bytes.NewReader(append(pngBytes(t, 16, 16), make([]byte, 4<<20)...))}
+	_, err := service.UploadImage(context.Background(), 1, stream, -1)
+	expectBadRequest(t, err)
+	if stream.n != policy.MaxBytes+1 {
+		t.Fatalf("expected %d bytes to be read, got %d", policy.MaxBytes+1, stream.n)
+	}
+
+	// an announced size over the limit is rejected before reading
+	stream = &countingReader{r: bytes.NewReader(make([]byte, 4<<20))}
+	_, err = service.UploadImage(context.Background(), 1, stream, 4<<20)
+	expectBadRequest(t, err)
+	if stream.n != 0 {
+		t.Fatalf("expected nothing to be read, got %d bytes", stream.n)
+	}
+}
+
+func TestImageService_UploadAttachments(t *testing.T) {
+	imageMock := &mockImageStorage{
+		uploadFunc: func(ctx context.Context, key string, data []byte) (string, error) {
+			return "http://localhost:6969/" + key, nil
+		},
+	}
+
+	service := NewImageService(imageMock, newMockImageRepository(), testImagePolicy())
+	first, second := pngBytes(t, 16, 16), pngBytes(t, 8, 8)
+	attachments, err := service.UploadAttachments(context.Background(), 1, []domain.AttachmentUpload{
+		{File: bytes.NewReader(first), Size: int64(len(first)), AltText: "  first  "},
+		{File: bytes.NewReader(second), Size: -1},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(attachments) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(attachments))
+	}
+	for i, a := range attachments {
+		if a.Position != i || a.Image.Path == "" {
+			t.Fatalf("unexpected attachment %d: %+v", i, a)
+		}
+	}
+	if attachments[0].AltText != "first" || attachments[1].AltText != "" {
+		t.Fatalf("unexpected alt texts %q, %q", attachments[0].AltText, attachments[1].AltText)
+	}
+	if attachments[0].Image.Path == attachments[1].Image.Path {
+		t.Fatalf("expected different images, got %q twice", attachments[0].Image.Path)
+	}
+}
+
+func TestImageService_UploadAttachments_Fail(t *testing.T) {
+	policy := testImagePolicy()
+	policy.MaxAttachments = 2
+
+	service := NewImageService(&mockImageStorage{
+		uploadFunc: func(ctx context.Context, key string, data []byte) (string, error) {
+			t.Fatalf("nothing must be uploaded")
+			return "", nil
+		},
+	}, newMockImageRepository(), policy)
+
+	upload := func(alt string) domain.AttachmentUpload {
+		data := pngBytes(t, 16, 16)
+		return domain.AttachmentUpload{File: bytes.NewReader(data), Size: int64(len(data)), AltText: alt}
+	}
+
+	tests := map[string][]domain.AttachmentUpload{
+		"too many":     {upload(""), upload(""), upload("")},
+		"long alt":     {upload(strings.Repeat("a", domain.MaxAltTextLength+1))},
+		"second large": {upload(""), {File: strings.NewReader(""), Size: 2 << 20}},
+	}
+	for name, uploads := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.UploadAttachments(context.Background(), 1, uploads)
+			expectBadRequest(t, err)
+		})
+	}
+}
+
+func TestImageService_UploadAttachments_Fail_names(t *testing.T) {
+	service := NewImageService(&mockImageStorage{}, newMockImageRepository(), testImagePolicy())
+	data := pngBytes(t, 16, 16)
+	_, err := service.UploadAttachments(context.Background(), 1, []domain.AttachmentUpload{
+		{File: bytes.NewReader(data), Size: int64(len(data))},
+		{File: strings.NewReader("not an image"), Size: 12},
+	})
+	expectBadRequest(t, err)
+	if !strings.HasPrefix(err.Error(), "image 2: ") {
+		t.Fatalf("expected the error to name the second image, got %q", err.Error())
+	}
+}
+
+func TestImageService_Validate_Fail_notAllowed(t *testing.T) {
+	policy := testImagePolicy()
+	policy.AllowedTypes = []string{"image/jpeg"}
+
+	service := NewImageService(&mockImageStorage{}, newMockImageRepository(), policy)
+	_, err := service.Validate(bytes.NewReader(pngBytes(t, 16, 16)))
+	expectBadRequest(t, err)
+}
+
+func TestImageService_Validate_Fail_decodeMemory(t *testing.T) {
+	policy := testImagePolicy()
+	// a 16x16 RGBA PNG takes 16*16*(4+8) bytes to decode and resize
+	policy.MaxDecodeMemory = 16*16*12 - 1
+
+	service := NewImageService(&mockImageStorage{}, newMockImageRepository(), policy)
+	_, err := service.Validate(bytes.NewReader(pngBytes(t, 16, 16)))
+	expectBadRequest(t, err)
+
+	policy.MaxDecodeMemory++
+	service = NewImageService(&mockImageStorage{}, newMockImageRepository(), policy)
+	if _, err := service.Validate(bytes.NewReader(pngBytes(t, 16, 16))); err != nil {
+		t.Fatalf("expected an image within the limit to pass, got %v", err)
+	}
+}
+
+func TestImageService_UploadImage_webp(t *testing.T) {
+	policy := testImagePolicy()
+	policy.MaxWidth, policy.MaxHeight = 1024, 1024
+	policy.ThumbnailSize, policy.PreviewSize = 100, 300
+
+	var sizes []string
+	imageMock := &mockImageStorage{
+		uploadFunc: func(ctx context.Context, key string, data []byte) (publicURL string, err error) {
+			info, err := imaging.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("uploaded image is not an image: %v", err)
+			}
+			sizes = append(sizes, fmt.Sprintf("%s %dx%d", info.MIMEType, info.Width, info.Height))
+			return "/media/images/" + key, nil
+		},
+	}
+
+	// a lossless 600x400 WebP of a single color
+	data := []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\r\x00\x00\x00/W\xc2c\x10(@\x91\v\xd0\xff\x02\x00\x00")
+	service := NewImageService(imageMock, newMockImageRepository(), policy)
+	if _, err := uploadBytes(service, 1, data); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the original is kept as it is, variants are encoded as PNG
+	slices.Sort(sizes)
+	want := []string{"image/png 100x66", "image/png 300x200", "image/webp 600x400"}
+	if !slices.Equal(sizes, want) {
+		t.Fatalf("expected uploads %v, got %v", want, sizes)
+	}
+}
+
+func TestImageService_Validate_Fail_corruptedWebP(t *testing.T) {
+	storage := &mockImageStorage{
+		uploadFunc: func(ctx context.Context, key string, data []byte) (string, error) {
+			t.Fatalf("a corrupted image must not be stored")
+			return "", nil
+		},
+	}
+	// the header of a 16x16 lossless WebP without the pixel data
+	data := []byte("RIFF\x16\x00\x00\x00WEBPVP8L\x0a\x00\x00\x00/\x0f\xc0\x03\x00\x00\x00\x00\x00\x00")
+	service := NewImageService(storage, newMockImageRepository(), testImagePolicy())
+	_, err := uploadBytes(service, 1, data)
+	expectBadRequest(t, err)
+}
+
+func TestCreatePost_Fail_invalidImage(t *testing.T) {
+	repoPost := &mockPostRepository{
+		saveFunc: func(ctx context.Context, post *domain.Post, userID int64) (int64, error) {
+			t.Fatalf("post must not be saved")
+			return -1, nil
+		},
+	}
+
+	service := NewPostService(repoPost, NewImageService(&mockImageStorage{}, newMockImageRepository(), testImagePolicy()), domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
+	id, err := service.CreateNewPost(context.Background(), &domain.Post{
+		Title:   "title",
+		Content: "content",
+	}, []domain.AttachmentUpload{{File: strings.NewReader("not an image"), Size: 12}})
+	expectBadRequest(t, err)
+	if id != -1 {
+		t.Fatalf("expected id -1, got %d", id)
+	}
+}
+
+func plainJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// jpegWithExif builds a JPEG carrying an EXIF block with the given orientation and a GPS position.
+func jpegWithExif(t *testing.T, w, h int, orientation uint16) []byte {
+	t.Helper()
+	plain := plainJPEG(t, w, h)
+
+	// TIFF header and IFD0 holding the orientation and a GPS latitude string stored right after the IFD
+	le := binary.LittleEndian
+	tiff := make([]byte, 8+2+2*12+4)
+	copy(tiff, "II*\x00")
+	le.PutUint32(tiff[4:], 8)
+	le.PutUint16(tiff[8:], 2)
+	le.PutUint16(tiff[10:], 0x0112)
+	le.PutUint16(tiff[12:], 3)
+	le.PutUint32(tiff[14:], 1)
+	le.PutUint16(tiff[18:], orientation)
+	le.PutUint16(tiff[22:], 0x0002)
+	le.PutUint16(tiff[24:], 2)
+	le.PutUint32(tiff[26:], 14)
+	le.PutUint32(tiff[30:], uint32(len(tiff)))
+	tiff = append(tiff, "GPS-N-55.7558\x00"...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+
+	out := append([]byte{}, plain[:2]...)
+	out = append(out, segment...)
+	out = append(out, payload...)
+	return append(out, plain[2:]...)
+}
+
+func TestImageService_UploadImage_stripsMetadata(t *testing.T) {
+	for _, orientation := range []uint16{1, 6} {
+		var uploaded []byte
+		imageMock := &mockImageStorage{
+			uploadFunc: func(ctx context.Context, key string, data []byte) (publicURL string, err error) {
+				uploaded = data
+				return "http://localhost:6969/user-1/aBc", nil
+			},
+		}
+
+		service := NewImageService(imageMock, newMockImageRepository(), testImagePolicy())
+		if _, err := uploadBytes(service, 1, jpegWithExif(t, 40, 20, orientation)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if bytes.Contains(uploaded, []byte("Exif")) || bytes.Contains(uploaded, []byte("GPS-N-55.7558")) {
+			t.Fatalf("orientation %d: EXIF survived the upload", orientation)
+		}
+		info, err := imaging.DecodeConfig(bytes.NewReader(uploaded))
+		if err != nil {
+			t.Fatalf("orientation %d: uploaded image is invalid: %v", orientation, err)
+		}
+		wantW, wantH := 40, 20
+		if orientation == 6 {
+			wantW, wantH = 20, 40
+		}
+		if info.Width != wantW || info.Height != wantH {
+			t.Fatalf("orientation %d: expected %dx%d, got %dx%d", orientation, wantW, wantH, info.Width, info.Height)
+		}
+	}
+}
+
+// discardStorage drops uploads, so benchmarks measure the pipeline rather than the storage.
+type discardStorage struct {
+	*mockImageStorage
+}
+
+func (discardStorage) UploadImage(ctx context.Context, key string, r io.Reader, size int64) (string, error) {
+	_, err := io.Copy(io.Discard, r)
+	return "/media/images/" + key, err
+}
+
+// noopImageRepository never finds an image, so every upload goes through the whole pipeline.
+type noopImageRepository struct{}
+
+func (noopImageRepository) AcquireImage(ctx context.Context, hash string) (domain.ImageRecord, error) {
+	return domain.ImageRecord{}, ErrImageNotFound
+}
+
+func (noopImageRepository) SaveImage(ctx context.Context, record *domain.ImageRecord) error {
+	return nil
+}
+
+// paddedPNG returns a w x h PNG grown to about size bytes with a text chunk, which stripping removes.
+func paddedPNG(tb testing.TB, w, h, size int) []byte {
+	data := pngBytes(tb, w, h)
+	text := make([]byte, max(size-len(data)-12, 0))
+	for i := range text {
+		text[i] = 'a'
+	}
+
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	// the text chunk goes right after the signature and IHDR
+	const ihdrEnd = 8 + 12 + 13
+	out := append([]byte{}, data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+// BenchmarkImageService_UploadImage uploads the same 256x256 image padded to growing sizes. Memory per
+// upload follows the pixel size, not the upload size: B/op stays flat from 256KB to 4MB, whether the
+// upload arrives as a multipart file or as a plain stream that is spooled to disk.
+func BenchmarkImageService_UploadImage(b *testing.B) {
+	policy := testImagePolicy()
+	policy.MaxBytes = 8 << 20
+	policy.MaxWidth, policy.MaxHeight = 1024, 1024
+	policy.ThumbnailSize, policy.PreviewSize = 64, 128
+	service := NewImageService(discardStorage{&mockImageStorage{}}, noopImageRepository{}, policy)
+
+	for _, size := range []int{256 << 10, 1 << 20, 4 << 20} {
+		data := paddedPNG(b, 256, 256, size)
+
+		b.Run(fmt.Sprintf("multipart/%dKB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := uploadBytes(service, 1, data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("stream/%dKB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				stream := &countingReader{r: bytes.NewReader(data)}
+				if _, err := service.UploadImage(context.Background(), 1, stream, -1); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
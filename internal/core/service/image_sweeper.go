@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type ImageSweepRepository interface {
+	// ListUnreferencedImages returns the hashes of images attached to no post or comment and not used since before.
+	// Deleted posts and comments do not count.
+	ListUnreferencedImages(ctx context.Context, before time.Time) ([]string, error)
+	// DeleteUnreferencedImage removes the record of hash unless it got attached or used in the meantime.
+	DeleteUnreferencedImage(ctx context.Context, hash string, before time.Time) (bool, error)
+	ListImageHashes(ctx context.Context) ([]string, error)
+}
+
+// SweepResult reports what a sweep removed, or would remove on a dry run.
+type SweepResult struct {
+	Records int   `json:"records"`
+	Objects int   `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+	DryRun  bool  `json:"dry_run"`
+}
+
+type SweepStats struct {
+	Runs     int64     `json:"runs"`
+	Failures int64     `json:"failures"`
+	Records  int64     `json:"records"`
+	Objects  int64     `json:"objects"`
+	Bytes    int64     `json:"bytes"`
+	LastRun  time.Time `json:"last_run"`
+}
+
+// ImageSweeper removes stored images that no post or comment references. Uploads whose post failed to save,
+// objects left behind by a failed upload and images of deleted content all end up here.
+// Anything younger than the grace period is kept, since it may belong to a post that is being created.
+type ImageSweeper struct {
+	repo       ImageSweepRepository
+	storage    ImageStorage
+	grace      time.Duration
+	interval   time.Duration
+	timeSource func() time.Time
+	logger     *slog.Logger
+
+	runs     atomic.Int64
+	failures atomic.Int64
+	records  atomic.Int64
+	objects  atomic.Int64
+	bytes    atomic.Int64
+	lastRun  atomic.Int64 // unix nanoseconds
+}
+
+func NewImageSweeper(repo ImageSweepRepository, storage ImageStorage, grace, interval time.Duration, timeSource func() time.Time, logger *slog.Logger) *ImageSweeper {
+	if grace <= 0 {
+		grace = 24 * time.Hour
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &ImageSweeper{
+		repo:       repo,
+		storage:    storage,
+		grace:      grace,
+		interval:   interval,
+		timeSource: timeSource,
+		logger:     logger,
+	}
+}
+
+// Run performs a sweep right away and then every interval. It blocks until ctx is done.
+func (s *ImageSweeper) Run(ctx context.Context) {
+	const op = "ImageSweeper.Run"
+
+	s.logger.Info("image sweeper started", "op", op, "interval", s.interval.String(), "grace", s.grace.String())
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.RunOnce(ctx, false)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			s.logger.Info("image sweeper stopped", "op", op)
+			return
+		}
+	}
+}
+
+// RunOnce performs a single sweep. With dryRun nothing is deleted, the result lists what would be.
+func (s *ImageSweeper) RunOnce(ctx context.Context, dryRun bool) (SweepResult, error) {
+	const op = "ImageSweeper.RunOnce"
+
+	now := s.timeSource()
+	if !dryRun {
+		s.runs.Add(1)
+		s.lastRun.Store(now.UnixNano())
+	}
+
+	result, err := s.sweep(ctx, now.Add(-s.grace), dryRun)
+	if err != nil {
+		if !dryRun {
+			s.failures.Add(1)
+		}
+		attrs := []any{"op", op, "err", err.Error()}
+		if cause := errors.Unwrap(err); cause != nil {
+			attrs = append(attrs, "cause", cause.Error())
+		}
+		s.logger.Error("image sweep failed", attrs...)
+		return result, err
+	}
+
+	if !dryRun {
+		s.records.Add(int64(result.Records))
+		s.objects.Add(int64(result.Objects))
+		s.bytes.Add(result.Bytes)
+	}
+	if result.Records > 0 || result.Objects > 0 {
+		s.logger.Info("image sweep finished", "op", op, "dry_run", dryRun, "records", result.Records, "objects", result.Objects, "bytes", result.Bytes)
+	} else {
+		s.logger.Debug("image sweep finished", "op", op, "dry_run", dryRun)
+	}
+
+	return result, nil
+}
+
+func (s *ImageSweeper) sweep(ctx context.Context, cutoff time.Time, dryRun bool) (SweepResult, error) {
+	result := SweepResult{DryRun: dryRun}
+
+	// records first, their objects are then picked up as objects without a record
+	stale, err := s.repo.ListUnreferencedImages(ctx, cutoff)
+	if err != nil {
+		return result, fmt.Errorf("list unreferenced images: %w", err)
+	}
+	removed := make(map[string]bool, len(stale))
+	for _, hash := range stale {
+		if !dryRun {
+			deleted, err := s.repo.DeleteUnreferencedImage(ctx, hash, cutoff)
+			if err != nil {
+				return result, fmt.Errorf("delete image %s: %w", hash, err)
+			}
+			if !deleted {
+				continue
+			}
+		}
+		removed[hash] = true
+		result.Records++
+	}
+
+	hashes, err := s.repo.ListImageHashes(ctx)
+	if err != nil {
+		return result, fmt.Errorf("list image hashes: %w", err)
+	}
+	known := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		known[hash] = !removed[hash]
+	}
+
+	objects, err := s.storage.ListImages(ctx)
+	if err != nil {
+		return result, fmt.Errorf("list objects: %w", err)
+	}
+	for _, obj := range objects {
+		// a fresh object may belong to an upload whose record is not saved yet
+		if obj.ModifiedAt.After(cutoff) || known[imageHashFromKey(obj.Key)] {
+			continue
+		}
+		if !dryRun {
+			if err := s.storage.DeleteImage(ctx, obj.Key); err != nil {
+				return result, fmt.Errorf("delete object %s: %w", obj.Key, err)
+			}
+		}
+		result.Objects++
+		result.Bytes += obj.Size
+	}
+
+	return result, nil
+}
+
+func (s *ImageSweeper) Stats() SweepStats {
+	stats := SweepStats{
+		Runs:     s.runs.Load(),
+		Failures: s.failures.Load(),
+		Records:  s.records.Load(),
+		Objects:  s.objects.Load(),
+		Bytes:    s.bytes.Load(),
+	}
+	if lastRun := s.lastRun.Load(); lastRun != 0 {
+		stats.LastRun = time.Unix(0, lastRun).UTC()
+	}
+	return stats
+}
+
+// imageHashFromKey strips the variant suffix from an object key.
+func imageHashFromKey(key string) string {
+	if hash, ok := strings.CutSuffix(key, thumbnailKeySuffix); ok {
+		return hash
+	}
+	if hash, ok := strings.CutSuffix(key, previewKeySuffix); ok {
+		return hash
+	}
+	return key
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+// mockImageSweepRepository keeps the last use of each record, records in referenced are attached to content.
+type mockImageSweepRepository struct {
+	lastUsed   map[string]time.Time
+	referenced map[string]bool
+}
+
+func (m *mockImageSweepRepository) ListUnreferencedImages(ctx context.Context, before time.Time) ([]string, error) {
+	var hashes []string
+	for hash, lastUsed := range m.lastUsed {
+		if !m.referenced[hash] && lastUsed.Before(before) {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+func (m *mockImageSweepRepository) DeleteUnreferencedImage(ctx context.Context, hash string, before time.Time) (bool, error) {
+	lastUsed, ok := m.lastUsed[hash]
+	if !ok || m.referenced[hash] || !lastUsed.Before(before) {
+		return false, nil
+	}
+	delete(m.lastUsed, hash)
+	return true, nil
+}
+
+func (m *mockImageSweepRepository) ListImageHashes(ctx context.Context) ([]string, error) {
+	var hashes []string
+	for hash := range m.lastUsed {
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+func TestImageSweeper_RunOnce(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-48 * time.Hour)
+	fresh := now.Add(-time.Hour)
+
+	repo := &mockImageSweepRepository{
+		lastUsed: map[string]time.Time{
+			"used":   old,
+			"stale":  old,
+			"recent": fresh,
+		},
+		referenced: map[string]bool{"used": true},
+	}
+	var deleted []string
+	objects := []ImageObject{
+		{Key: "used", Size: 10, ModifiedAt: old},
+		{Key: "used-thumb", Size: 1, ModifiedAt: old},
+		{Key: "stale", Size: 10, ModifiedAt: old},
+		{Key: "stale-preview", Size: 5, ModifiedAt: old},
+		{Key: "recent", Size: 10, ModifiedAt: fresh},
+		{Key: "orphan", Size: 7, ModifiedAt: old},
+		{Key: "uploading", Size: 7, ModifiedAt: fresh},
+	}
+	storage := &mockImageStorage{
+		listFunc: func(ctx context.Context) ([]ImageObject, error) {
+			return objects, nil
+		},
+		deleteFunc: func(ctx context.Context, key string) error {
+			deleted = append(deleted, key)
+			return nil
+		},
+	}
+	sweeper := NewImageSweeper(repo, storage, 24*time.Hour, time.Hour, func() time.Time { return now }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	want := SweepResult{Records: 1, Objects: 3, Bytes: 22}
+
+	dry, err := sweeper.RunOnce(context.Background(), true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want.DryRun = true
+	if dry != want {
+		t.Fatalf("expected %+v, got %+v", want, dry)
+	}
+	if len(deleted) != 0 || len(repo.lastUsed) != 3 {
+		t.Fatalf("dry run deleted %v", deleted)
+	}
+	if runs := sweeper.Stats().Runs; runs != 0 {
+		t.Fatalf("expected dry runs not to be counted, got %d", runs)
+	}
+
+	result, err := sweeper.RunOnce(context.Background(), false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want.DryRun = false
+	if result != want {
+		t.Fatalf("expected %+v, got %+v", want, result)
+	}
+	slices.Sort(deleted)
+	if !slices.Equal(deleted, []string{"orphan", "stale", "stale-preview"}) {
+		t.Fatalf("unexpected deleted objects %v", deleted)
+	}
+	if _, ok := repo.lastUsed["stale"]; ok {
+		t.Fatalf("expected the stale record to be deleted")
+	}
+
+	stats := sweeper.Stats()
+	if stats.Runs != 1 || stats.Records != 1 || stats.Objects != 3 || stats.Bytes != 22 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestImageHashFromKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"abc":         "abc",
+		"abc-thumb":   "abc",
+		"abc-preview": "abc",
+		"abc-other":   "abc-other",
+	} {
+		if got := imageHashFromKey(key); got != want {
+			t.Errorf("imageHashFromKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/passhash"
+	"go-hex-forum/pkg/svcerr"
+)
+
+type ModeratorSessionRepository interface {
+	Store(ctx context.Context, session domain.ModeratorSession) error
+	// GetByHash returns ErrSessionNotFound for unknown hashes.
+	GetByHash(ctx context.Context, hash string) (domain.ModeratorSession, error)
+	Delete(ctx context.Context, hash string) error
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ModeratorService signs staff in and out. Accounts come from the configuration, a session only lasts as
+// long as its account is still configured, and always carries the role configured right now.
+type ModeratorService struct {
+	repo       ModeratorSessionRepository
+	accounts   map[string]domain.ModeratorAccount
+	ttl        time.Duration
+	timeSource func() time.Time
+
+	// dummyHash is checked for unknown logins, so they take as long as wrong passwords
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewModeratorService(repo ModeratorSessionRepository, accounts []domain.ModeratorAccount, ttl time.Duration, timeSource func() time.Time) *ModeratorService {
+	if ttl <= 0 {
+		ttl = 8 * time.Hour
+	}
+	byLogin := make(map[string]domain.ModeratorAccount, len(accounts))
+	for _, account := range accounts {
+		byLogin[account.Login] = account
+	}
+	return &ModeratorService{
+		repo:       repo,
+		accounts:   byLogin,
+		ttl:        ttl,
+		timeSource: timeSource,
+	}
+}
+
+// Login checks the credentials of a staff account and starts a session for it.
+func (s *ModeratorService) Login(ctx context.Context, login, password string) (string, domain.ModeratorSession, error) {
+	const op = "ModeratorService.Login"
+
+	account, ok := s.accounts[login]
+	hash := account.PasswordHash
+	if !ok {
+		s.dummyOnce.Do(func() { s.dummyHash, _ = passhash.Hash("") })
+		hash = s.dummyHash
+	}
+	match, err := passhash.Verify(hash, password)
+	if err != nil {
+		return "", domain.ModeratorSession{}, svcerr.NewError("failed to log in", fmt.Errorf("%s: account %q: %w", op, login, err), svcerr.ErrInternal)
+	}
+	if !ok || !match {
+		return "", domain.ModeratorSession{}, svcerr.NewError("invalid login or password", fmt.Errorf("%s: rejected %q", op, login), svcerr.ErrNotAuthorized)
+	}
+
+	token, err := generateSessionToken()
+	if err != nil {
+		return "", domain.ModeratorSession{}, svcerr.NewError("failed to log in", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+	now := s.timeSource().UTC()
+	session := domain.ModeratorSession{
+		TokenHash: hashToken(token),
+		Login:     account.Login,
+		Role:      account.Role,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	// staff sessions are few, expired ones are cleared whenever someone signs in
+	if _, err := s.repo.DeleteExpired(ctx, now); err != nil {
+		return "", domain.ModeratorSession{}, svcerr.NewError("failed to log in", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+	if err := s.repo.Store(ctx, session); err != nil {
+		return "", domain.ModeratorSession{}, svcerr.NewError("failed to log in", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+
+	return token, session, nil
+}
+
+// Authenticate resolves the session of a staff token.
+func (s *ModeratorService) Authenticate(ctx context.Context, token string) (domain.ModeratorSession, error) {
+	const op = "ModeratorService.Authenticate"
+
+	session, err := s.repo.GetByHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return domain.ModeratorSession{}, svcerr.NewError("not authorized", fmt.Errorf("%s: %w", op, err), svcerr.ErrNotAuthorized)
+		}
+		return domain.ModeratorSession{}, svcerr.NewError("failed to authenticate", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+	if session.IsExpired(s.timeSource().UTC()) {
+		return domain.ModeratorSession{}, svcerr.NewError("not authorized", fmt.Errorf("%s: %w", op, ErrSessionExpired), svcerr.ErrNotAuthorized)
+	}
+
+	account, ok := s.accounts[session.Login]
+	if !ok {
+		return domain.ModeratorSession{}, svcerr.NewError("not authorized", fmt.Errorf("%s: account %q removed", op, session.Login), svcerr.ErrNotAuthorized)
+	}
+	session.Role = account.Role
+
+	return session, nil
+}
+
+// Logout ends the session of a staff token, an unknown token is not an error.
+func (s *ModeratorService) Logout(ctx context.Context, token string) error {
+	const op = "ModeratorService.Logout"
+
+	if err := s.repo.Delete(ctx, hashToken(token)); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return svcerr.NewError("failed to log out", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+	return nil
+}
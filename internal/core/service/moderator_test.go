@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/passhash"
+	"go-hex-forum/pkg/svcerr"
+)
+
+type mockModeratorSessionRepository struct {
+	sessions map[string]domain.ModeratorSession
+}
+
+func (m *mockModeratorSessionRepository) Store(ctx context.Context, session domain.ModeratorSession) error {
+	m.sessions[session.TokenHash] = session
+	return nil
+}
+
+func (m *mockModeratorSessionRepository) GetByHash(ctx context.Context, hash string) (domain.ModeratorSession, error) {
+	session, ok := m.sessions[hash]
+	if !ok {
+		return domain.ModeratorSession{}, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (m *mockModeratorSessionRepository) Delete(ctx context.Context, hash string) error {
+	delete(m.sessions, hash)
+	return nil
+}
+
+func (m *mockModeratorSessionRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func expectNotAuthorized(t *testing.T, err error) {
+	t.Helper()
+	var svcErr *svcerr.Error
+	if !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrNotAuthorized {
+		t.Fatalf("expected not authorized, got %v", err)
+	}
+}
+
+func TestModeratorService(t *testing.T) {
+	hash, err := passhash.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	repo := &mockModeratorSessionRepository{sessions: map[string]domain.ModeratorSession{}}
+	accounts := []domain.ModeratorAccount{{Login: "mod", Role: domain.RoleModerator, PasswordHash: hash}}
+	svc := NewModeratorService(repo, accounts, time.Hour, func() time.Time { return now })
+	ctx := context.Background()
+
+	_, _, err = svc.Login(ctx, "mod", "wrong")
+	expectNotAuthorized(t, err)
+	_, _, err = svc.Login(ctx, "nobody", "hunter2")
+	expectNotAuthorized(t, err)
+
+	token, session, err := svc.Login(ctx, "mod", "hunter2")
+	if err != nil {
+		t.Fatalf("expected the login to succeed, got %v", err)
+	}
+	if session.Role != domain.RoleModerator || !session.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected session %+v", session)
+	}
+	if _, ok := repo.sessions[token]; ok {
+		t.Fatalf("expected the token to be stored hashed")
+	}
+
+	// the configured role wins over the one the session was started with
+	svc.accounts["mod"] = domain.ModeratorAccount{Login: "mod", Role: domain.RoleAdmin, PasswordHash: hash}
+	if session, err := svc.Authenticate(ctx, token); err != nil || session.Role != domain.RoleAdmin {
+		t.Fatalf("expected an admin session, got %+v (%v)", session, err)
+	}
+
+	delete(svc.accounts, "mod")
+	_, err = svc.Authenticate(ctx, token)
+	expectNotAuthorized(t, err)
+	svc.accounts["mod"] = accounts[0]
+
+	now = now.Add(time.Hour)
+	_, err = svc.Authenticate(ctx, token)
+	expectNotAuthorized(t, err)
+	now = now.Add(-time.Hour)
+
+	if err := svc.Logout(ctx, token); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	_, err = svc.Authenticate(ctx, token)
+	expectNotAuthorized(t, err)
+}
@@ -11,50 +11,67 @@ import (
 
 type PostRepository interface {
 	SavePost(ctx context.Context, post *domain.Post, userID int64) (int64, error)
+	// GetActivePosts and GetArchivedPosts return up to PageSize+1 posts, the extra one only tells that there is a next page.
 	GetActivePosts(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error)
 	GetArchivedPosts(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error)
+	CountPosts(ctx context.Context, archived bool) (int64, error)
 	GetPostByID(ctx context.Context, postID int64) (domain.Post, error)
-	ArchiveExpiredPosts(ctx context.Context) error
-}
-
-type ImageStorage interface {
-	UploadImage(ctx context.Context, userID int64, data []byte) (publicURL string, err error)
-	GetImageURL(userID int64, code string) string
+	// GetPostsByIDs returns the posts of ids that exist, deleted ones included, in no particular order.
+	GetPostsByIDs(ctx context.Context, ids []int64) ([]domain.Post, error)
+	ArchiveExpiredPosts(ctx context.Context, now time.Time) (int64, error)
+	DeletePost(ctx context.Context, postID int64, deletion domain.Deletion) error
+	// EditPost stores the new title and content of post together with the revision keeping the old ones.
+	EditPost(ctx context.Context, post *domain.Post, revision domain.Revision) error
+	// GetPostRevisions returns the revisions of a post, newest first.
+	GetPostRevisions(ctx context.Context, postID int64) ([]domain.Revision, error)
 }
 
 type PostService struct {
-	postRepo     PostRepository
-	imageStorage ImageStorage
+	postRepo   PostRepository
+	images     ImageUploader
+	policy     domain.LifetimePolicy
+	deletion   domain.DeletionPolicy
+	edit       domain.EditPolicy
+	timeSource func() time.Time
 }
 
-func NewPostService(postRepo PostRepository, imageStorage ImageStorage) *PostService {
-	return &PostService{postRepo, imageStorage}
+func NewPostService(postRepo PostRepository, images ImageUploader, policy domain.LifetimePolicy, deletion domain.DeletionPolicy, edit domain.EditPolicy, timeSource func() time.Time) *PostService {
+	return &PostService{postRepo, images, policy, deletion, edit, timeSource}
 }
 
-func (s *PostService) CreateNewPost(ctx context.Context, post *domain.Post, imageData []byte) (int64, error) {
-	const op = "PostService.CreateNewPost"
-	// validation
-	if post.Title == "" || post.Content == "" {
+// validatePostText checks the title and content a post is created or edited with.
+func validatePostText(op, title, content string) error {
+	if title == "" || content == "" {
 		raw := fmt.Errorf("%s: title and content are not provided", op)
-		return -1, svcerr.NewError("title and content are required", raw, svcerr.ErrBadRequest)
+		return svcerr.NewError("title and content are required", raw, svcerr.ErrBadRequest)
 	}
-	if len(post.Title) > 32 {
-		return -1, svcerr.NewError("too long title", fmt.Errorf("title longer than 32 chars"), svcerr.ErrBadRequest)
+	if len(title) > 32 {
+		return svcerr.NewError("too long title", fmt.Errorf("title longer than 32 chars"), svcerr.ErrBadRequest)
 	}
-	if len(post.Title) < 3 {
-		return -1, svcerr.NewError("too short title", fmt.Errorf("itle shorter than 3 chars"), svcerr.ErrBadRequest)
+	if len(title) < 3 {
+		return svcerr.NewError("too short title", fmt.Errorf("itle shorter than 3 chars"), svcerr.ErrBadRequest)
 	}
-	post.CreatedAt = time.Now().UTC()
-	// initial expiration
-	post.ExpiresAt = post.CreatedAt.Add(10 * time.Minute)
+	return nil
+}
 
-	if len(imageData) > 0 {
-		url, err := s.imageStorage.UploadImage(ctx, post.PostAuthor.ID, imageData)
+func (s *PostService) CreateNewPost(ctx context.Context, post *domain.Post, uploads []domain.AttachmentUpload) (int64, error) {
+	const op = "PostService.CreateNewPost"
+	if err := validatePostText(op, post.Title, post.Content); err != nil {
+		return -1, err
+	}
+	post.CreatedAt = s.timeSource().UTC()
+	post.ExpiresAt = s.policy.InitialExpiry(post.CreatedAt)
+
+	if len(uploads) > 0 {
+		attachments, err := s.images.UploadAttachments(ctx, post.PostAuthor.ID, uploads)
 		if err != nil {
+			if isBadRequest(err) {
+				return -1, err
+			}
 			raw := fmt.Errorf("%s: upload image failed: %w", op, err)
 			return -1, svcerr.NewError("failed to upload image", raw, svcerr.ErrInternal)
 		}
-		post.ImagePath = url
+		post.Attachments = attachments
 	}
 
 	id, err := s.postRepo.SavePost(ctx, post, post.PostAuthor.ID)
@@ -65,22 +82,63 @@ func (s *PostService) CreateNewPost(ctx context.Context, post *domain.Post, imag
 	return id, nil
 }
 
-func (s *PostService) GetActivePosts(ctx context.Context) ([]domain.Post, error) {
-	posts, err := s.postRepo.GetActivePosts(ctx, &domain.Pagination{Page: 1, PageSize: 10})
+func (s *PostService) GetActivePosts(ctx context.Context, pagination domain.Pagination) (domain.PostPage, error) {
+	const op = "PostService.GetActivePosts"
+	page, err := s.listPosts(ctx, false, pagination)
 	if err != nil {
-		raw := fmt.Errorf("PostService.GetActivePosts: %w", err)
-		return nil, svcerr.NewError("failed to get active posts", raw, svcerr.ErrInternal)
+		raw := fmt.Errorf("%s: %w", op, err)
+		return domain.PostPage{}, svcerr.NewError("failed to get active posts", raw, svcerr.ErrInternal)
 	}
-	return posts, nil
+	return page, nil
 }
 
-func (s *PostService) GetArchivedPosts(ctx context.Context) ([]domain.Post, error) {
-	posts, err := s.postRepo.GetArchivedPosts(ctx, &domain.Pagination{Page: 1, PageSize: 10})
+func (s *PostService) GetArchivedPosts(ctx context.Context, pagination domain.Pagination) (domain.PostPage, error) {
+	const op = "PostService.GetArchivedPosts"
+	page, err := s.listPosts(ctx, true, pagination)
 	if err != nil {
-		raw := fmt.Errorf("PostService.GetArchivedPosts: %w", err)
-		return nil, svcerr.NewError("failed to get archived posts", raw, svcerr.ErrInternal)
+		raw := fmt.Errorf("%s: %w", op, err)
+		return domain.PostPage{}, svcerr.NewError("failed to get archived posts", raw, svcerr.ErrInternal)
 	}
-	return posts, nil
+	return page, nil
+}
+
+func (s *PostService) listPosts(ctx context.Context, archived bool, pagination domain.Pagination) (domain.PostPage, error) {
+	pagination.Normalize()
+
+	var (
+		posts []domain.Post
+		err   error
+	)
+	if archived {
+		posts, err = s.postRepo.GetArchivedPosts(ctx, &pagination)
+	} else {
+		posts, err = s.postRepo.GetActivePosts(ctx, &pagination)
+	}
+	if err != nil {
+		return domain.PostPage{}, err
+	}
+
+	total, err := s.postRepo.CountPosts(ctx, archived)
+	if err != nil {
+		return domain.PostPage{}, err
+	}
+
+	page := domain.PostPage{
+		Posts:    posts,
+		Page:     pagination.Page,
+		PageSize: pagination.PageSize,
+		Total:    total,
+	}
+	if pagination.Cursor != nil {
+		page.Page = 0
+	}
+	if len(posts) > pagination.PageSize {
+		page.Posts = posts[:pagination.PageSize]
+		last := page.Posts[len(page.Posts)-1]
+		page.NextCursor = &domain.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	return page, nil
 }
 
 func (s *PostService) GetPostByID(ctx context.Context, postID int64) (domain.Post, error) {
@@ -90,23 +148,111 @@ func (s *PostService) GetPostByID(ctx context.Context, postID int64) (domain.Pos
 		raw := fmt.Errorf("%s: %w", op, err)
 		return domain.Post{}, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
 	}
+	if post.IsDeleted() {
+		raw := fmt.Errorf("%s: post %d was deleted", op, postID)
+		return domain.Post{}, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
+	}
+	// the archive worker may lag behind, expired posts are shown as archived right away
+	post.ArchivePostIfExpired(s.timeSource())
 	return post, nil
 }
 
-func (s *PostService) ArchiveExpiredPostsWorker(ctx context.Context) {
-	ticker := time.NewTicker(time.Minute)
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				err := s.postRepo.ArchiveExpiredPosts(ctx)
-				if err != nil {
-					// log or what
-				}
-			case <-ctx.Done():
-				ticker.Stop()
-				return
-			}
-		}
-	}()
+// ArchiveExpiredPosts archives every post whose lifetime is over and returns how many were archived.
+func (s *PostService) ArchiveExpiredPosts(ctx context.Context) (int64, error) {
+	const op = "PostService.ArchiveExpiredPosts"
+	archived, err := s.postRepo.ArchiveExpiredPosts(ctx, s.timeSource())
+	if err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return 0, svcerr.NewError("failed to archive expired posts", raw, svcerr.ErrInternal)
+	}
+	return archived, nil
+}
+
+// DeletePost removes a post on behalf of actor. The post is kept but no longer listed or shown, authors may
+// delete their own posts for a while after posting, staff at any time.
+func (s *PostService) DeletePost(ctx context.Context, postID int64, actor domain.Actor, reason string) error {
+	const op = "PostService.DeletePost"
+	if len(reason) > domain.MaxDeleteReasonLength {
+		raw := fmt.Errorf("%s: reason longer than %d chars", op, domain.MaxDeleteReasonLength)
+		return svcerr.NewError("too long reason", raw, svcerr.ErrBadRequest)
+	}
+
+	post, err := s.GetPostByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+
+	now := s.timeSource().UTC()
+	if err := s.deletion.Check(actor, post.PostAuthor.ID, post.CreatedAt, now); err != nil {
+		raw := fmt.Errorf("%s: %s may not delete post %d: %w", op, actor, postID, err)
+		return svcerr.NewError(err.Error(), raw, svcerr.ErrForbidden)
+	}
+
+	deletion := domain.Deletion{At: now, By: actor.String(), Reason: reason}
+	if err := s.postRepo.DeletePost(ctx, postID, deletion); err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return svcerr.NewError("failed to delete post", raw, svcerr.ErrInternal)
+	}
+	return nil
+}
+
+// CanDeletePost reports whether actor may delete post right now, pages only offer deletion when they may.
+func (s *PostService) CanDeletePost(post domain.Post, actor domain.Actor) bool {
+	return !post.IsDeleted() && s.deletion.Check(actor, post.PostAuthor.ID, post.CreatedAt, s.timeSource().UTC()) == nil
+}
+
+// EditPost replaces the title and content of a post on behalf of its author, keeping the old text as a
+// revision. Authors may edit for a while after posting, archived posts are no longer edited.
+func (s *PostService) EditPost(ctx context.Context, postID int64, actor domain.Actor, title, content string) (domain.Post, error) {
+	const op = "PostService.EditPost"
+	if err := validatePostText(op, title, content); err != nil {
+		return domain.Post{}, err
+	}
+
+	post, err := s.GetPostByID(ctx, postID)
+	if err != nil {
+		return domain.Post{}, err
+	}
+	if post.IsArchived {
+		raw := fmt.Errorf("%s: post %d is archived", op, postID)
+		return domain.Post{}, svcerr.NewError("post is archived, editing is prohibited", raw, svcerr.ErrBadRequest)
+	}
+
+	now := s.timeSource().UTC()
+	if err := s.edit.Check(actor, post.PostAuthor.ID, post.CreatedAt, now); err != nil {
+		raw := fmt.Errorf("%s: %s may not edit post %d: %w", op, actor, postID, err)
+		return domain.Post{}, svcerr.NewError(err.Error(), raw, svcerr.ErrForbidden)
+	}
+	if title == post.Title && content == post.Content {
+		return post, nil
+	}
+
+	revision := domain.Revision{Title: post.Title, Content: post.Content, EditedBy: actor.String(), EditedAt: now}
+	post.Title, post.Content, post.EditedAt = title, content, now
+	if err := s.postRepo.EditPost(ctx, &post, revision); err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return domain.Post{}, svcerr.NewError("failed to edit post", raw, svcerr.ErrInternal)
+	}
+	return post, nil
+}
+
+// CanEditPost reports whether actor may edit post right now, pages only offer editing when they may.
+func (s *PostService) CanEditPost(post domain.Post, actor domain.Actor) bool {
+	return !post.IsDeleted() && !post.IsArchived && s.edit.Check(actor, post.PostAuthor.ID, post.CreatedAt, s.timeSource().UTC()) == nil
+}
+
+// GetPostHistory returns a post, deleted or not, with its revisions newest first for moderation.
+func (s *PostService) GetPostHistory(ctx context.Context, postID int64) (domain.Post, []domain.Revision, error) {
+	const op = "PostService.GetPostHistory"
+	post, err := s.postRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return domain.Post{}, nil, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
+	}
+	revisions, err := s.postRepo.GetPostRevisions(ctx, postID)
+	if err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return domain.Post{}, nil, svcerr.NewError("failed to load revisions", raw, svcerr.ErrInternal)
+	}
+	return post, revisions, nil
 }
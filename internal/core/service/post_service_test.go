@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
 )
 
 func TestCreatePost_Success(t *testing.T) {
@@ -16,21 +20,21 @@ func TestCreatePost_Success(t *testing.T) {
 		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
 			return domain.Post{ID: 1}, nil
 		},
-		archieveExpiredfunc: func(ctx context.Context) error {
-			return nil
+		archieveExpiredfunc: func(ctx context.Context, now time.Time) (int64, error) {
+			return 0, nil
 		},
 	}
-	imageMock := &mockImageStorage{
-		uploadFunc: func(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
-			return "http://localhost:6969/user-104/dBAvuR", nil
+	imageMock := &mockImageUploader{
+		uploadFunc: func(ctx context.Context, userID int64, data []byte) (domain.Image, error) {
+			return domain.Image{Path: "http://localhost:6969/user-104/dBAvuR"}, nil
 		},
 	}
 
-	service := NewPostService(repoPost, imageMock)
+	service := NewPostService(repoPost, imageMock, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
 	id, err := service.CreateNewPost(context.Background(), &domain.Post{
 		Title:   "title",
 		Content: "content",
-	}, []byte(""))
+	}, nil)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -48,17 +52,17 @@ func TestCreatePost_Fail(t *testing.T) {
 			return domain.Post{ID: 1}, nil
 		},
 	}
-	imageMock := &mockImageStorage{
-		uploadFunc: func(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
-			return "http://localhost:6969/user-104/dBAvuR", nil
+	imageMock := &mockImageUploader{
+		uploadFunc: func(ctx context.Context, userID int64, data []byte) (domain.Image, error) {
+			return domain.Image{Path: "http://localhost:6969/user-104/dBAvuR"}, nil
 		},
 	}
 
-	service := NewPostService(repoPost, imageMock)
+	service := NewPostService(repoPost, imageMock, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
 	id, err := service.CreateNewPost(context.Background(), &domain.Post{
 		Title:   "",
 		Content: "",
-	}, []byte(""))
+	}, nil)
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
@@ -76,17 +80,17 @@ func TestCreatePost_Fail_postRepo(t *testing.T) {
 			return domain.Post{ID: 1}, nil
 		},
 	}
-	imageMock := &mockImageStorage{
-		uploadFunc: func(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
-			return "http://localhost:6969/user-104/dBAvuR", nil
+	imageMock := &mockImageUploader{
+		uploadFunc: func(ctx context.Context, userID int64, data []byte) (domain.Image, error) {
+			return domain.Image{Path: "http://localhost:6969/user-104/dBAvuR"}, nil
 		},
 	}
 
-	service := NewPostService(repoPost, imageMock)
+	service := NewPostService(repoPost, imageMock, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
 	id, err := service.CreateNewPost(context.Background(), &domain.Post{
 		Title:   "title",
 		Content: "content",
-	}, []byte(""))
+	}, nil)
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
@@ -104,17 +108,17 @@ func TestCreatePost_Fail_imgSource(t *testing.T) {
 			return domain.Post{ID: 1}, nil
 		},
 	}
-	imageMock := &mockImageStorage{
-		uploadFunc: func(ctx context.Context, userID int64, data []byte) (publicURL string, err error) {
-			return "", fmt.Errorf("error")
+	imageMock := &mockImageUploader{
+		uploadFunc: func(ctx context.Context, userID int64, data []byte) (domain.Image, error) {
+			return domain.Image{}, fmt.Errorf("error")
 		},
 	}
 
-	service := NewPostService(repoPost, imageMock)
+	service := NewPostService(repoPost, imageMock, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
 	id, err := service.CreateNewPost(context.Background(), &domain.Post{
 		Title:   "title",
 		Content: "content",
-	}, []byte("X"))
+	}, []domain.AttachmentUpload{{File: strings.NewReader("X"), Size: 1}})
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
@@ -238,3 +242,197 @@ func TestCreatePost_Fail_imgSource(t *testing.T) {
 // 		t.Fatalf("expected id -1, got %d", id)
 // 	}
 // }
+
+func TestGetActivePosts_Pagination(t *testing.T) {
+	now := time.Now().UTC()
+	var got *domain.Pagination
+	repoPost := &mockPostRepository{
+		listFunc: func(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error) {
+			got = pagination
+			return []domain.Post{
+				{ID: 3, CreatedAt: now},
+				{ID: 2, CreatedAt: now.Add(-time.Minute)},
+				{ID: 1, CreatedAt: now.Add(-2 * time.Minute)},
+			}, nil
+		},
+		countFunc: func(ctx context.Context, archived bool) (int64, error) {
+			if archived {
+				t.Fatalf("expected active posts count")
+			}
+			return 5, nil
+		},
+	}
+
+	service := NewPostService(repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
+	page, err := service.GetActivePosts(context.Background(), domain.Pagination{Page: 2, PageSize: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Page != 2 || got.PageSize != 2 || got.Offset() != 2 {
+		t.Fatalf("unexpected pagination passed to repo: %+v", got)
+	}
+	if page.Total != 5 || page.TotalPages() != 3 {
+		t.Fatalf("expected 5 posts on 3 pages, got %d on %d", page.Total, page.TotalPages())
+	}
+	if !page.HasPrev() || !page.HasNext() {
+		t.Fatalf("expected both previous and next pages")
+	}
+	if len(page.Posts) != 2 {
+		t.Fatalf("expected the extra post to be dropped, got %d posts", len(page.Posts))
+	}
+	if page.NextCursor == nil || page.NextCursor.ID != 2 || !page.NextCursor.CreatedAt.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("expected next cursor on the last post, got %+v", page.NextCursor)
+	}
+}
+
+func TestGetActivePosts_Pagination_fullLastPage(t *testing.T) {
+	repoPost := &mockPostRepository{
+		listFunc: func(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error) {
+			return []domain.Post{{ID: 2}, {ID: 1}}, nil
+		},
+	}
+
+	service := NewPostService(repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
+	page, err := service.GetActivePosts(context.Background(), domain.Pagination{PageSize: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(page.Posts) != 2 || page.NextCursor != nil {
+		t.Fatalf("expected no next cursor on an exactly full last page, got %+v", page.NextCursor)
+	}
+}
+
+func TestGetActivePosts_Pagination_defaults(t *testing.T) {
+	var got *domain.Pagination
+	repoPost := &mockPostRepository{
+		listFunc: func(ctx context.Context, pagination *domain.Pagination) ([]domain.Post, error) {
+			got = pagination
+			return []domain.Post{{ID: 1}}, nil
+		},
+	}
+
+	service := NewPostService(repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), time.Now)
+	page, err := service.GetActivePosts(context.Background(), domain.Pagination{Page: -3, PageSize: 1000})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Page != 1 || got.PageSize != domain.MaxPageSize {
+		t.Fatalf("expected page 1 of size %d, got %+v", domain.MaxPageSize, got)
+	}
+	if page.NextCursor != nil {
+		t.Fatalf("expected no next cursor on a short page, got %+v", page.NextCursor)
+	}
+}
+
+func TestCursor_EncodeDecode(t *testing.T) {
+	cursor := domain.Cursor{CreatedAt: time.Date(2025, 5, 1, 12, 0, 0, 123, time.UTC), ID: 42}
+	decoded, err := domain.DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if decoded.ID != cursor.ID || !decoded.CreatedAt.Equal(cursor.CreatedAt) {
+		t.Fatalf("expected %+v, got %+v", cursor, decoded)
+	}
+	if _, err := domain.DecodeCursor("not a cursor"); err == nil {
+		t.Fatalf("expected error, got none")
+	}
+}
+
+func expectForbidden(t *testing.T, err error) {
+	t.Helper()
+	var svcErr *svcerr.Error
+	if !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrForbidden {
+		t.Fatalf("expected forbidden error, got %v", err)
+	}
+}
+
+func TestDeletePost(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	post := domain.Post{ID: 1, PostAuthor: domain.UserData{ID: 7}, CreatedAt: now.Add(-5 * time.Minute)}
+	var deleted []domain.Deletion
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return post, nil
+		},
+		deleteFunc: func(ctx context.Context, postID int64, deletion domain.Deletion) error {
+			deleted = append(deleted, deletion)
+			return nil
+		},
+	}
+	service := NewPostService(repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), func() time.Time { return now })
+	ctx := context.Background()
+
+	expectForbidden(t, service.DeletePost(ctx, 1, domain.Actor{UserID: 8}, ""))
+	expectBadRequest(t, service.DeletePost(ctx, 1, domain.Actor{UserID: 7}, strings.Repeat("x", domain.MaxDeleteReasonLength+1)))
+	if service.CanDeletePost(post, domain.Actor{UserID: 8}) || !service.CanDeletePost(post, domain.Actor{UserID: 7}) {
+		t.Fatalf("expected only the author to be offered deletion")
+	}
+
+	if err := service.DeletePost(ctx, 1, domain.Actor{UserID: 7}, "typo"); err != nil {
+		t.Fatalf("expected the author to delete the post, got %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].By != "user:7" || deleted[0].Reason != "typo" || !deleted[0].At.Equal(now) {
+		t.Fatalf("unexpected deletion %+v", deleted)
+	}
+
+	// past the window only staff may delete
+	now = now.Add(time.Hour)
+	expectForbidden(t, service.DeletePost(ctx, 1, domain.Actor{UserID: 7}, ""))
+	if err := service.DeletePost(ctx, 1, domain.Actor{Moderator: "mod"}, "spam"); err != nil {
+		t.Fatalf("expected a moderator to delete the post, got %v", err)
+	}
+
+	post.Deleted = &deleted[0]
+	if _, err := service.GetPostByID(ctx, 1); err == nil {
+		t.Fatalf("expected a deleted post to be gone")
+	}
+}
+
+func TestEditPost(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	post := domain.Post{ID: 1, Title: "old", Content: "old text", PostAuthor: domain.UserData{ID: 7}, CreatedAt: now.Add(-5 * time.Minute), ExpiresAt: now.Add(time.Hour)}
+	var revisions []domain.Revision
+	repoPost := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			return post, nil
+		},
+		editFunc: func(ctx context.Context, edited *domain.Post, revision domain.Revision) error {
+			revisions = append(revisions, revision)
+			return nil
+		},
+	}
+	service := NewPostService(repoPost, &mockImageUploader{}, domain.DefaultLifetimePolicy(), domain.DefaultDeletionPolicy(), domain.DefaultEditPolicy(), func() time.Time { return now })
+	ctx := context.Background()
+
+	_, err := service.EditPost(ctx, 1, domain.Actor{UserID: 8}, "new", "new text")
+	expectForbidden(t, err)
+	_, err = service.EditPost(ctx, 1, domain.Actor{Moderator: "mod"}, "new", "new text")
+	expectForbidden(t, err)
+	_, err = service.EditPost(ctx, 1, domain.Actor{UserID: 7}, "", "new text")
+	expectBadRequest(t, err)
+
+	if _, err := service.EditPost(ctx, 1, domain.Actor{UserID: 7}, "old", "old text"); err != nil || len(revisions) != 0 {
+		t.Fatalf("expected an unchanged edit to store nothing, got %v and %+v", err, revisions)
+	}
+
+	edited, err := service.EditPost(ctx, 1, domain.Actor{UserID: 7}, "new", "new text")
+	if err != nil {
+		t.Fatalf("expected the author to edit the post, got %v", err)
+	}
+	if edited.Title != "new" || edited.Content != "new text" || !edited.EditedAt.Equal(now) {
+		t.Fatalf("unexpected edited post %+v", edited)
+	}
+	if len(revisions) != 1 || revisions[0].Title != "old" || revisions[0].Content != "old text" || revisions[0].EditedBy != "user:7" {
+		t.Fatalf("expected the old text in the revision, got %+v", revisions)
+	}
+
+	post.IsArchived = true
+	_, err = service.EditPost(ctx, 1, domain.Actor{UserID: 7}, "newer", "newer text")
+	expectBadRequest(t, err)
+
+	post.IsArchived = false
+	now = now.Add(time.Hour)
+	post.ExpiresAt = now.Add(time.Hour)
+	_, err = service.EditPost(ctx, 1, domain.Actor{UserID: 7}, "newer", "newer text")
+	expectForbidden(t, err)
+}
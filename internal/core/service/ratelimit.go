@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
+)
+
+type RateLimitStore interface {
+	// Take atomically refills the buckets stored under keys and takes cost tokens from every one of them, or from
+	// none when one has too few left, see domain.RateLimit.TakeAll.
+	Take(ctx context.Context, keys []string, limit domain.RateLimit, cost int, now time.Time) (time.Duration, error)
+	// Peek returns the bucket stored under key without changing it, a missing bucket is returned as a zero bucket.
+	Peek(ctx context.Context, key string) (domain.Bucket, error)
+	// Prune removes buckets not used since before, they would be full again anyway.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+// RateLimiter counts actions against token buckets kept per action and subject, a subject being a user or a
+// client address. Every subject of a request has to have tokens left. Limits without a configuration are not
+// enforced. When the store fails requests are let through, an outage of the limiter must not take down posting.
+// Refused requests are counted as strikes against their subjects in the domain.RateActionStrike buckets.
+type RateLimiter struct {
+	store      RateLimitStore
+	limits     map[domain.RateAction]domain.RateLimit
+	timeSource func() time.Time
+	logger     *slog.Logger
+}
+
+func NewRateLimiter(store RateLimitStore, limits map[domain.RateAction]domain.RateLimit, timeSource func() time.Time, logger *slog.Logger) *RateLimiter {
+	return &RateLimiter{
+		store:      store,
+		limits:     limits,
+		timeSource: timeSource,
+		logger:     logger,
+	}
+}
+
+// Allow takes cost tokens for action from the bucket of every subject. It returns a svcerr.ErrTooManyRequests
+// error wrapping a *domain.RateLimitExceeded when one of them is empty, nothing is taken from the others then.
+func (l *RateLimiter) Allow(ctx context.Context, action domain.RateAction, cost int, subjects ...string) error {
+	const op = "RateLimiter.Allow"
+
+	limit, ok := l.limits[action]
+	if !ok || !limit.Enabled() || cost <= 0 {
+		return nil
+	}
+
+	var keys []string
+	for _, subject := range subjects {
+		if subject != "" {
+			keys = append(keys, rateLimitKey(action, subject))
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	now := l.timeSource().UTC()
+	retryAfter, err := l.store.Take(ctx, keys, limit, cost, now)
+	if err != nil {
+		l.logger.Warn("rate limit check failed, letting the request through", "op", op, "action", action, "err", err)
+		return nil
+	}
+	if retryAfter > 0 {
+		l.strike(ctx, now, subjects)
+		return svcerr.NewError("too many requests, try again later",
+			&domain.RateLimitExceeded{Action: action, RetryAfter: retryAfter}, svcerr.ErrTooManyRequests)
+	}
+
+	return nil
+}
+
+// Strikes returns the most strikes any of the subjects has collected recently.
+func (l *RateLimiter) Strikes(ctx context.Context, subjects ...string) int {
+	const op = "RateLimiter.Strikes"
+
+	limit, ok := l.limits[domain.RateActionStrike]
+	if !ok || !limit.Enabled() {
+		return 0
+	}
+
+	now := l.timeSource().UTC()
+	strikes := 0
+	for _, subject := range subjects {
+		if subject == "" {
+			continue
+		}
+		bucket, err := l.store.Peek(ctx, rateLimitKey(domain.RateActionStrike, subject))
+		if err != nil {
+			l.logger.Warn("failed to read strikes", "op", op, "err", err)
+			return 0
+		}
+		if bucket.UpdatedAt.IsZero() {
+			continue
+		}
+		strikes = max(strikes, limit.Used(bucket, now))
+	}
+	return strikes
+}
+
+func (l *RateLimiter) strike(ctx context.Context, now time.Time, subjects []string) {
+	const op = "RateLimiter.strike"
+
+	limit, ok := l.limits[domain.RateActionStrike]
+	if !ok || !limit.Enabled() {
+		return
+	}
+	for _, subject := range subjects {
+		if subject == "" {
+			continue
+		}
+		// strikes are taken one subject at a time, an empty strike bucket only means the subject is at the maximum already
+		if _, err := l.store.Take(ctx, []string{rateLimitKey(domain.RateActionStrike, subject)}, limit, 1, now); err != nil {
+			l.logger.Warn("failed to record strike", "op", op, "err", err)
+			return
+		}
+	}
+}
+
+// Run prunes idle buckets every interval until ctx is done. A bucket untouched for the longest window is full
+// and can be forgotten.
+func (l *RateLimiter) Run(ctx context.Context) {
+	const op = "RateLimiter.Run"
+
+	interval := l.maxWindow()
+	if interval <= 0 {
+		return
+	}
+	interval = max(interval, time.Minute)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		pruned, err := l.store.Prune(ctx, l.timeSource().UTC().Add(-l.maxWindow()))
+		if err != nil {
+			attrs := []any{"op", op, "err", err.Error()}
+			if cause := errors.Unwrap(err); cause != nil {
+				attrs = append(attrs, "cause", cause.Error())
+			}
+			l.logger.Error("rate limit pruning failed", attrs...)
+			continue
+		}
+		if pruned > 0 {
+			l.logger.Debug("pruned idle rate limit buckets", "op", op, "buckets", pruned)
+		}
+	}
+}
+
+func (l *RateLimiter) maxWindow() time.Duration {
+	var window time.Duration
+	for _, limit := range l.limits {
+		if limit.Enabled() {
+			window = max(window, limit.Window)
+		}
+	}
+	return window
+}
+
+func rateLimitKey(action domain.RateAction, subject string) string {
+	return strings.Join([]string{string(action), subject}, ":")
+}
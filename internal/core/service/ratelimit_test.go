@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
+)
+
+// mockRateLimitStore keeps buckets in a map, takes fail with err when it is set.
+type mockRateLimitStore struct {
+	buckets map[string]domain.Bucket
+	err     error
+}
+
+func (m *mockRateLimitStore) Take(ctx context.Context, keys []string, limit domain.RateLimit, cost int, now time.Time) (time.Duration, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	buckets := make([]domain.Bucket, len(keys))
+	for i, key := range keys {
+		buckets[i] = m.buckets[key]
+	}
+	buckets, retryAfter := limit.TakeAll(buckets, cost, now)
+	for i, key := range keys {
+		m.buckets[key] = buckets[i]
+	}
+	return retryAfter, nil
+}
+
+func (m *mockRateLimitStore) Peek(ctx context.Context, key string) (domain.Bucket, error) {
+	return m.buckets[key], m.err
+}
+
+func (m *mockRateLimitStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newTestRateLimiter(store RateLimitStore, now time.Time) *RateLimiter {
+	limits := map[domain.RateAction]domain.RateLimit{
+		domain.RateActionPost:   {Burst: 1, Window: time.Minute},
+		domain.RateActionStrike: {Burst: 5, Window: time.Hour},
+	}
+	return NewRateLimiter(store, limits, func() time.Time { return now }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	store := &mockRateLimitStore{buckets: map[string]domain.Bucket{}}
+	limiter := newTestRateLimiter(store, now)
+	ctx := context.Background()
+
+	if err := limiter.Allow(ctx, domain.RateActionPost, 1, "ip:1.2.3.4", "user:1"); err != nil {
+		t.Fatalf("expected the first post to pass, got %v", err)
+	}
+
+	// another user behind the same address shares its bucket
+	err := limiter.Allow(ctx, domain.RateActionPost, 1, "ip:1.2.3.4", "user:2")
+	var svcErr *svcerr.Error
+	var exceeded *domain.RateLimitExceeded
+	if !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrTooManyRequests || !errors.As(err, &exceeded) {
+		t.Fatalf("expected a too many requests error, got %v", err)
+	}
+	if exceeded.RetryAfter != time.Minute {
+		t.Fatalf("expected to retry after a minute, got %v", exceeded.RetryAfter)
+	}
+
+	if strikes := limiter.Strikes(ctx, "ip:1.2.3.4"); strikes != 1 {
+		t.Fatalf("expected the refused request to count as a strike, got %d", strikes)
+	}
+	if strikes := limiter.Strikes(ctx, "ip:5.6.7.8", "user:1"); strikes != 0 {
+		t.Fatalf("expected no strikes for subjects that were let through, got %d", strikes)
+	}
+
+	if err := limiter.Allow(ctx, domain.RateActionPost, 1, "ip:5.6.7.8", "user:3"); err != nil {
+		t.Fatalf("expected another address to pass, got %v", err)
+	}
+	if err := limiter.Allow(ctx, domain.RateActionComment, 1, "ip:1.2.3.4"); err != nil {
+		t.Fatalf("expected an action without a limit to pass, got %v", err)
+	}
+}
+
+func TestRateLimiter_Allow_refusedByLaterSubject(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	store := &mockRateLimitStore{buckets: map[string]domain.Bucket{}}
+	limiter := newTestRateLimiter(store, now)
+	ctx := context.Background()
+
+	if err := limiter.Allow(ctx, domain.RateActionPost, 1, "ip:1.2.3.4", "user:1"); err != nil {
+		t.Fatalf("expected the first post to pass, got %v", err)
+	}
+
+	// the address has a token left, the user does not, so the address must keep it
+	err := limiter.Allow(ctx, domain.RateActionPost, 1, "ip:5.6.7.8", "user:1")
+	var svcErr *svcerr.Error
+	if !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrTooManyRequests {
+		t.Fatalf("expected a too many requests error, got %v", err)
+	}
+
+	if err := limiter.Allow(ctx, domain.RateActionPost, 1, "ip:5.6.7.8", "user:2"); err != nil {
+		t.Fatalf("expected the refused request to leave the address its token, got %v", err)
+	}
+}
+
+func TestRateLimiter_AllowFailsOpen(t *testing.T) {
+	store := &mockRateLimitStore{err: errors.New("connection refused")}
+	limiter := newTestRateLimiter(store, time.Now())
+
+	if err := limiter.Allow(context.Background(), domain.RateActionPost, 1, "ip:1.2.3.4"); err != nil {
+		t.Fatalf("expected a failing store to let requests through, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
+)
+
+type ReportRepository interface {
+	// SaveReport stores an open report, it returns ErrDuplicateReport when the reporter already has one open on the target.
+	SaveReport(ctx context.Context, report *domain.Report) (int64, error)
+	// GetReportQueue returns a page of the targets with open reports, most reported first, ties going to the
+	// most recently reported. Each group holds the latest MaxQueuedReports of its reports, oldest first.
+	GetReportQueue(ctx context.Context, pagination domain.Pagination) ([]domain.ReportGroup, error)
+	// CountReportedTargets counts the posts and comments with open reports.
+	CountReportedTargets(ctx context.Context) (int64, error)
+	// ResolveReports closes the open reports on target and returns how many there were.
+	// Repositories deleting a post or comment close its open reports too.
+	ResolveReports(ctx context.Context, target domain.ReportTarget, resolution domain.Resolution) (int64, error)
+}
+
+// PostDeleter and CommentDeleter delete reported content, PostService and CommentService implement them.
+type PostDeleter interface {
+	DeletePost(ctx context.Context, postID int64, actor domain.Actor, reason string) error
+}
+
+type CommentDeleter interface {
+	DeleteComment(ctx context.Context, commentID int64, actor domain.Actor, reason string) error
+}
+
+// ReportService takes reports from readers and hands them to moderators grouped by what they are about.
+type ReportService struct {
+	transactor  Transactor
+	repo        ReportRepository
+	postRepo    PostRepository
+	commentRepo CommentRepository
+	posts       PostDeleter
+	comments    CommentDeleter
+	timeSource  func() time.Time
+}
+
+func NewReportService(
+	transactor Transactor,
+	repo ReportRepository,
+	postRepo PostRepository,
+	commentRepo CommentRepository,
+	posts PostDeleter,
+	comments CommentDeleter,
+	timeSource func() time.Time,
+) *ReportService {
+	return &ReportService{
+		transactor:  transactor,
+		repo:        repo,
+		postRepo:    postRepo,
+		commentRepo: commentRepo,
+		posts:       posts,
+		comments:    comments,
+		timeSource:  timeSource,
+	}
+}
+
+// Report files a report by reporterID. Deleted posts and comments cannot be reported, and every reader
+// has at most one open report per target.
+func (s *ReportService) Report(ctx context.Context, reporterID int64, target domain.ReportTarget, reason domain.ReportReason, details string) (domain.Report, error) {
+	const op = "ReportService.Report"
+
+	if !target.Valid() {
+		raw := fmt.Errorf("%s: target %+v", op, target)
+		return domain.Report{}, svcerr.NewError("a post or a comment has to be reported", raw, svcerr.ErrBadRequest)
+	}
+	if !reason.Valid() {
+		raw := fmt.Errorf("%s: reason %q", op, reason)
+		return domain.Report{}, svcerr.NewError("unknown report reason", raw, svcerr.ErrBadRequest)
+	}
+	if reason == domain.ReportOther && details == "" {
+		raw := fmt.Errorf("%s: no details for %q", op, reason)
+		return domain.Report{}, svcerr.NewError("details are required for this reason", raw, svcerr.ErrBadRequest)
+	}
+	if len([]rune(details)) > domain.MaxReportDetailsLength {
+		raw := fmt.Errorf("%s: details of %d runes", op, len([]rune(details)))
+		return domain.Report{}, svcerr.NewError(fmt.Sprintf("details may be at most %d characters", domain.MaxReportDetailsLength), raw, svcerr.ErrBadRequest)
+	}
+
+	if err := s.checkTarget(ctx, op, target); err != nil {
+		return domain.Report{}, err
+	}
+
+	report := domain.Report{
+		Target:     target,
+		ReporterID: reporterID,
+		Reason:     reason,
+		Details:    details,
+		CreatedAt:  s.timeSource().UTC(),
+	}
+	id, err := s.repo.SaveReport(ctx, &report)
+	if err != nil {
+		if errors.Is(err, ErrDuplicateReport) {
+			raw := fmt.Errorf("%s: user %d: %w", op, reporterID, err)
+			return domain.Report{}, svcerr.NewError("you have already reported this", raw, svcerr.ErrConflict)
+		}
+		raw := fmt.Errorf("%s: %w", op, err)
+		return domain.Report{}, svcerr.NewError("failed to save report", raw, svcerr.ErrInternal)
+	}
+	report.ID = id
+	return report, nil
+}
+
+// checkTarget makes sure the reported post or comment exists and was not deleted.
+func (s *ReportService) checkTarget(ctx context.Context, op string, target domain.ReportTarget) error {
+	deleted, err := s.targetDeleted(ctx, op, target)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		raw := fmt.Errorf("%s: %+v was deleted", op, target)
+		if target.IsComment() {
+			return svcerr.NewError("comment not found", raw, svcerr.ErrNotFound)
+		}
+		return svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
+	}
+	return nil
+}
+
+// targetDeleted tells whether the post or comment of target was deleted, a missing one is not found.
+func (s *ReportService) targetDeleted(ctx context.Context, op string, target domain.ReportTarget) (bool, error) {
+	if target.IsComment() {
+		comment, err := s.commentRepo.GetByID(ctx, target.CommentID)
+		if err != nil {
+			if errors.Is(err, ErrCommentNotFound) {
+				raw := fmt.Errorf("%s: comment %d: %w", op, target.CommentID, err)
+				return false, svcerr.NewError("comment not found", raw, svcerr.ErrNotFound)
+			}
+			raw := fmt.Errorf("%s: get comment: %w", op, err)
+			return false, svcerr.NewError("failed to load comment", raw, svcerr.ErrInternal)
+		}
+		return comment.IsDeleted(), nil
+	}
+
+	post, err := s.postRepo.GetPostByID(ctx, target.PostID)
+	if err != nil {
+		raw := fmt.Errorf("%s: post %d: %w", op, target.PostID, err)
+		return false, svcerr.NewError("post not found", raw, svcerr.ErrNotFound)
+	}
+	return post.IsDeleted(), nil
+}
+
+// Queue returns a page of the open reports grouped by target, most reported first, together with what was reported.
+func (s *ReportService) Queue(ctx context.Context, pagination domain.Pagination) (domain.ReportPage, error) {
+	const op = "ReportService.Queue"
+
+	pagination.Normalize()
+	pagination.Cursor = nil
+
+	groups, err := s.repo.GetReportQueue(ctx, pagination)
+	if err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return domain.ReportPage{}, svcerr.NewError("failed to load reports", raw, svcerr.ErrInternal)
+	}
+	total, err := s.repo.CountReportedTargets(ctx)
+	if err != nil {
+		raw := fmt.Errorf("%s: %w", op, err)
+		return domain.ReportPage{}, svcerr.NewError("failed to load reports", raw, svcerr.ErrInternal)
+	}
+
+	var postIDs, commentIDs []int64
+	for _, g := range groups {
+		if g.Target.IsComment() {
+			commentIDs = append(commentIDs, g.Target.CommentID)
+		} else {
+			postIDs = append(postIDs, g.Target.PostID)
+		}
+	}
+	posts, err := s.postRepo.GetPostsByIDs(ctx, postIDs)
+	if err != nil {
+		raw := fmt.Errorf("%s: posts: %w", op, err)
+		return domain.ReportPage{}, svcerr.NewError("failed to load reported posts", raw, svcerr.ErrInternal)
+	}
+	comments, err := s.commentRepo.GetByIDs(ctx, commentIDs)
+	if err != nil {
+		raw := fmt.Errorf("%s: comments: %w", op, err)
+		return domain.ReportPage{}, svcerr.NewError("failed to load reported comments", raw, svcerr.ErrInternal)
+	}
+
+	postsByID := make(map[int64]*domain.Post, len(posts))
+	for i := range posts {
+		postsByID[posts[i].ID] = &posts[i]
+	}
+	commentsByID := make(map[int64]*domain.Comment, len(comments))
+	for _, c := range comments {
+		commentsByID[c.ID] = c
+	}
+	for i := range groups {
+		groups[i].Post = postsByID[groups[i].Target.PostID]
+		groups[i].Comment = commentsByID[groups[i].Target.CommentID]
+	}
+
+	return domain.ReportPage{Groups: groups, Page: pagination.Page, PageSize: pagination.PageSize, Total: total}, nil
+}
+
+// Resolve closes every open report on target on behalf of moderator and returns how many were closed.
+// The deleted action also deletes the target, with the note as the reason, in the same transaction. A target
+// deleted already, whose reports were closed with it, is no error then.
+func (s *ReportService) Resolve(ctx context.Context, target domain.ReportTarget, moderator domain.Actor, action domain.ReportAction, note string) (int64, error) {
+	const op = "ReportService.Resolve"
+
+	if !target.Valid() {
+		raw := fmt.Errorf("%s: target %+v", op, target)
+		return 0, svcerr.NewError("a post or a comment has to be given", raw, svcerr.ErrBadRequest)
+	}
+	if !action.Valid() {
+		raw := fmt.Errorf("%s: action %q", op, action)
+		return 0, svcerr.NewError("unknown action", raw, svcerr.ErrBadRequest)
+	}
+	if len([]rune(note)) > domain.MaxReportNoteLength {
+		raw := fmt.Errorf("%s: note of %d runes", op, len([]rune(note)))
+		return 0, svcerr.NewError(fmt.Sprintf("note may be at most %d characters", domain.MaxReportNoteLength), raw, svcerr.ErrBadRequest)
+	}
+
+	resolution := domain.Resolution{Action: action, By: moderator.String(), Note: note, At: s.timeSource().UTC()}
+	var closed int64
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		closed, err = s.repo.ResolveReports(ctx, target, resolution)
+		if err != nil {
+			raw := fmt.Errorf("%s: %w", op, err)
+			return svcerr.NewError("failed to resolve reports", raw, svcerr.ErrInternal)
+		}
+
+		deleted := false
+		if action == domain.ReportDeleted {
+			if deleted, err = s.targetDeleted(ctx, op, target); err != nil {
+				return err
+			}
+		}
+		if closed == 0 && !deleted {
+			raw := fmt.Errorf("%s: no open reports on %+v", op, target)
+			return svcerr.NewError("no open reports", raw, svcerr.ErrNotFound)
+		}
+		if action != domain.ReportDeleted || deleted {
+			return nil
+		}
+
+		if target.IsComment() {
+			return s.comments.DeleteComment(ctx, target.CommentID, moderator, note)
+		}
+		return s.posts.DeletePost(ctx, target.PostID, moderator, note)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return closed, nil
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
+)
+
+// mockReportRepository keeps reports in memory and refuses a second open report of a reader on a target.
+type mockReportRepository struct {
+	reports []domain.Report
+}
+
+func (m *mockReportRepository) SaveReport(ctx context.Context, report *domain.Report) (int64, error) {
+	for _, r := range m.reports {
+		if r.IsOpen() && r.ReporterID == report.ReporterID && r.Target == report.Target {
+			return 0, ErrDuplicateReport
+		}
+	}
+	report.ID = int64(len(m.reports) + 1)
+	m.reports = append(m.reports, *report)
+	return report.ID, nil
+}
+
+// groups gathers the open reports by target in the order of the queue.
+func (m *mockReportRepository) groups() []domain.ReportGroup {
+	index := make(map[domain.ReportTarget]int)
+	var groups []domain.ReportGroup
+	for _, r := range m.reports {
+		if !r.IsOpen() {
+			continue
+		}
+		i, ok := index[r.Target]
+		if !ok {
+			i = len(groups)
+			index[r.Target] = i
+			groups = append(groups, domain.ReportGroup{Target: r.Target, Reasons: make(map[domain.ReportReason]int), FirstAt: r.CreatedAt})
+		}
+		g := &groups[i]
+		g.Open++
+		g.Reasons[r.Reason]++
+		g.LastAt = r.CreatedAt
+		if len(g.Reports) < domain.MaxQueuedReports {
+			g.Reports = append(g.Reports, r)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Open != groups[j].Open {
+			return groups[i].Open > groups[j].Open
+		}
+		return groups[i].LastAt.After(groups[j].LastAt)
+	})
+	return groups
+}
+
+func (m *mockReportRepository) GetReportQueue(ctx context.Context, pagination domain.Pagination) ([]domain.ReportGroup, error) {
+	groups := m.groups()
+	from := min(pagination.Offset(), len(groups))
+	return groups[from:min(from+pagination.PageSize, len(groups))], nil
+}
+
+func (m *mockReportRepository) CountReportedTargets(ctx context.Context) (int64, error) {
+	return int64(len(m.groups())), nil
+}
+
+func (m *mockReportRepository) ResolveReports(ctx context.Context, target domain.ReportTarget, resolution domain.Resolution) (int64, error) {
+	var closed int64
+	for i := range m.reports {
+		if m.reports[i].IsOpen() && m.reports[i].Target == target {
+			m.reports[i].Resolution = &resolution
+			closed++
+		}
+	}
+	return closed, nil
+}
+
+func expectNotFound(t *testing.T, err error) {
+	t.Helper()
+	var svcErr *svcerr.Error
+	if !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrNotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func expectConflict(t *testing.T, err error) {
+	t.Helper()
+	var svcErr *svcerr.Error
+	if !errors.As(err, &svcErr) || svcErr.AppErr != svcerr.ErrConflict {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
+
+// mockContentDeleter marks posts and comments deleted and closes their reports, as the repositories do.
+type mockContentDeleter struct {
+	repo    *mockReportRepository
+	deleted map[domain.ReportTarget]*domain.Deletion
+}
+
+func (m *mockContentDeleter) delete(ctx context.Context, target domain.ReportTarget, actor domain.Actor, reason string) error {
+	if m.deleted[target] != nil {
+		return svcerr.NewError("deleted already", errors.New("deleted already"), svcerr.ErrConflict)
+	}
+	deletion := &domain.Deletion{By: actor.String(), Reason: reason}
+	m.deleted[target] = deletion
+	_, err := m.repo.ResolveReports(ctx, target, domain.Resolution{Action: domain.ReportDeleted, By: deletion.By, Note: reason})
+	return err
+}
+
+func (m *mockContentDeleter) DeletePost(ctx context.Context, postID int64, actor domain.Actor, reason string) error {
+	return m.delete(ctx, domain.ReportTarget{PostID: postID}, actor, reason)
+}
+
+func (m *mockContentDeleter) DeleteComment(ctx context.Context, commentID int64, actor domain.Actor, reason string) error {
+	return m.delete(ctx, domain.ReportTarget{CommentID: commentID}, actor, reason)
+}
+
+// newTestReportService knows post 1 with comment 3, and post 2 and comment 4, which were deleted.
+func newTestReportService(repo *mockReportRepository, now time.Time) (*ReportService, *mockContentDeleter) {
+	deleter := &mockContentDeleter{repo: repo, deleted: map[domain.ReportTarget]*domain.Deletion{
+		{PostID: 2}:    {By: "moderator:mod"},
+		{CommentID: 4}: {By: "user:1"},
+	}}
+	posts := &mockPostRepository{
+		getFunc: func(ctx context.Context, postID int64) (domain.Post, error) {
+			if postID != 1 && postID != 2 {
+				return domain.Post{}, errors.New("no rows")
+			}
+			return domain.Post{ID: postID, Title: "hello", Deleted: deleter.deleted[domain.ReportTarget{PostID: postID}]}, nil
+		},
+	}
+	comments := &mockCommentRepository{
+		getByIDFunc: func(ctx context.Context, commentID int64) (*domain.Comment, error) {
+			if commentID != 3 && commentID != 4 {
+				return nil, ErrCommentNotFound
+			}
+			return &domain.Comment{ID: commentID, PostID: 1, Content: "rude", Deleted: deleter.deleted[domain.ReportTarget{CommentID: commentID}]}, nil
+		},
+	}
+	return NewReportService(&mockTransactor{}, repo, posts, comments, deleter, deleter, func() time.Time { return now }), deleter
+}
+
+func TestReport(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := &mockReportRepository{}
+	service, _ := newTestReportService(repo, now)
+	ctx := context.Background()
+
+	report, err := service.Report(ctx, 7, domain.ReportTarget{CommentID: 3}, domain.ReportAbuse, "insults")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if report.ID == 0 || report.ReporterID != 7 || !report.CreatedAt.Equal(now) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	_, err = service.Report(ctx, 7, domain.ReportTarget{CommentID: 3}, domain.ReportSpam, "")
+	expectConflict(t, err)
+	if _, err := service.Report(ctx, 8, domain.ReportTarget{CommentID: 3}, domain.ReportSpam, ""); err != nil {
+		t.Fatalf("expected another reader to report too, got %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		target  domain.ReportTarget
+		reason  domain.ReportReason
+		details string
+		expect  func(*testing.T, error)
+	}{
+		{"no target", domain.ReportTarget{}, domain.ReportSpam, "", expectBadRequest},
+		{"two targets", domain.ReportTarget{PostID: 1, CommentID: 3}, domain.ReportSpam, "", expectBadRequest},
+		{"unknown reason", domain.ReportTarget{PostID: 1}, "boring", "", expectBadRequest},
+		{"other without details", domain.ReportTarget{PostID: 1}, domain.ReportOther, "", expectBadRequest},
+		{"long details", domain.ReportTarget{PostID: 1}, domain.ReportSpam, strings.Repeat("x", domain.MaxReportDetailsLength+1), expectBadRequest},
+		{"missing post", domain.ReportTarget{PostID: 9}, domain.ReportSpam, "", expectNotFound},
+		{"deleted post", domain.ReportTarget{PostID: 2}, domain.ReportSpam, "", expectNotFound},
+		{"missing comment", domain.ReportTarget{CommentID: 9}, domain.ReportSpam, "", expectNotFound},
+		{"deleted comment", domain.ReportTarget{CommentID: 4}, domain.ReportSpam, "", expectNotFound},
+	}
+	for _, c := range cases {
+		_, err := service.Report(ctx, 9, c.target, c.reason, c.details)
+		if err == nil {
+			t.Fatalf("%s: expected an error", c.name)
+		}
+		c.expect(t, err)
+	}
+	if len(repo.reports) != 2 {
+		t.Fatalf("expected 2 stored reports, got %d", len(repo.reports))
+	}
+}
+
+func TestReportQueue(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := &mockReportRepository{}
+	service, deleter := newTestReportService(repo, now)
+	ctx := context.Background()
+
+	for reporter := int64(1); reporter <= 2; reporter++ {
+		if _, err := service.Report(ctx, reporter, domain.ReportTarget{CommentID: 3}, domain.ReportAbuse, ""); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if _, err := service.Report(ctx, 1, domain.ReportTarget{PostID: 1}, domain.ReportSpam, ""); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	page, err := service.Queue(ctx, domain.Pagination{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	queue := page.Groups
+	if page.Total != 2 || len(queue) != 2 || queue[0].Count() != 2 || queue[0].Comment == nil || queue[0].Comment.Content != "rude" || queue[1].Post == nil {
+		t.Fatalf("unexpected queue %+v", page)
+	}
+	page, err = service.Queue(ctx, domain.Pagination{Page: 2, PageSize: 1})
+	if err != nil || len(page.Groups) != 1 || page.Groups[0].Target.PostID != 1 || page.HasNext() {
+		t.Fatalf("expected the post alone on the second page, got %+v and %v", page, err)
+	}
+
+	moderator := domain.Actor{Moderator: "mod"}
+	closed, err := service.Resolve(ctx, domain.ReportTarget{CommentID: 3}, moderator, domain.ReportDeleted, "insults")
+	if err != nil || closed != 2 {
+		t.Fatalf("expected 2 reports closed, got %d and %v", closed, err)
+	}
+	if deletion := deleter.deleted[domain.ReportTarget{CommentID: 3}]; deletion == nil || deletion.By != "moderator:mod" || deletion.Reason != "insults" {
+		t.Fatalf("expected the comment to be deleted by the moderator, got %+v", deletion)
+	}
+	resolution := repo.reports[0].Resolution
+	if resolution == nil || resolution.By != "moderator:mod" || resolution.Action != domain.ReportDeleted || resolution.Note != "insults" || !resolution.At.Equal(now) {
+		t.Fatalf("unexpected resolution %+v", resolution)
+	}
+
+	_, err = service.Resolve(ctx, domain.ReportTarget{CommentID: 3}, moderator, domain.ReportDismissed, "")
+	expectNotFound(t, err)
+	_, err = service.Resolve(ctx, domain.ReportTarget{PostID: 1}, moderator, "ignored", "")
+	expectBadRequest(t, err)
+
+	page, _ = service.Queue(ctx, domain.Pagination{})
+	if len(page.Groups) != 1 || page.Groups[0].Target.PostID != 1 {
+		t.Fatalf("unexpected queue after resolving %+v", page)
+	}
+
+	// a closed report no longer blocks reporting again
+	if _, err := service.Resolve(ctx, domain.ReportTarget{PostID: 1}, moderator, domain.ReportDismissed, ""); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := service.Report(ctx, 1, domain.ReportTarget{PostID: 1}, domain.ReportAbuse, ""); err != nil {
+		t.Fatalf("expected a new report after the old one was closed, got %v", err)
+	}
+	if deleter.deleted[domain.ReportTarget{PostID: 1}] != nil {
+		t.Fatalf("expected a dismissed post to be kept")
+	}
+}
+
+func TestReportResolve_deletedTarget(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := &mockReportRepository{}
+	service, deleter := newTestReportService(repo, now)
+	ctx := context.Background()
+	moderator := domain.Actor{Moderator: "mod"}
+
+	if _, err := service.Report(ctx, 7, domain.ReportTarget{PostID: 1}, domain.ReportSpam, ""); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// the author deletes the post before a moderator gets to the report, which closes it
+	if err := deleter.DeletePost(ctx, 1, domain.Actor{UserID: 7}, ""); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resolution := repo.reports[0].Resolution; resolution == nil || resolution.Action != domain.ReportDeleted || resolution.By != "user:7" {
+		t.Fatalf("expected the report closed by the deletion, got %+v", resolution)
+	}
+	page, _ := service.Queue(ctx, domain.Pagination{})
+	if len(page.Groups) != 0 || page.Total != 0 {
+		t.Fatalf("expected an empty queue, got %+v", page)
+	}
+
+	// deleting what is gone already is no error
+	for _, target := range []domain.ReportTarget{{PostID: 1}, {CommentID: 4}} {
+		closed, err := service.Resolve(ctx, target, moderator, domain.ReportDeleted, "")
+		if err != nil || closed != 0 {
+			t.Fatalf("%+v: expected nothing to do, got %d and %v", target, closed, err)
+		}
+	}
+	_, err := service.Resolve(ctx, domain.ReportTarget{CommentID: 4}, moderator, domain.ReportDismissed, "")
+	expectNotFound(t, err)
+
+	// without open reports nothing is deleted
+	_, err = service.Resolve(ctx, domain.ReportTarget{CommentID: 3}, moderator, domain.ReportDeleted, "")
+	expectNotFound(t, err)
+	if deleter.deleted[domain.ReportTarget{CommentID: 3}] != nil {
+		t.Fatalf("expected the comment to be kept")
+	}
+}
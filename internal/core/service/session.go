@@ -19,6 +19,9 @@ type SessionRepository interface {
 	Store(context.Context, domain.Session) error
 	GetByHashedToken(context.Context, string) (*domain.Session, error)
 	UpdateByToken(context.Context, string, func(*domain.Session) (bool, error)) error
+	Rotate(ctx context.Context, hashedToken, newHashedToken string, rotatedAt, expiresAt time.Time) error
+	RevokeByHash(ctx context.Context, hashedToken string) error
+	RevokeByUser(ctx context.Context, userID int64) (int64, error)
 }
 
 type UserDataProvider interface {
@@ -53,13 +56,19 @@ func (s *SessionService) StoreNewSession(ctx context.Context) (string, error) {
 	if err != nil {
 		return "", svcerr.NewError("failed to generate token", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
 	}
+	csrfToken, err := generateSessionToken()
+	if err != nil {
+		return "", svcerr.NewError("failed to generate token", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
 
-	h := sha256.Sum256([]byte(plainToken))
+	now := s.timeSource().UTC()
 	session := domain.Session{
-		TokenHash: hex.EncodeToString(h[:]),
+		TokenHash: hashToken(plainToken),
 		User:      userData,
-		CreatedAt: s.timeSource(),
-		ExpiresAt: s.timeSource().Add(s.cfg.DefaultTTL),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.DefaultTTL),
+		RotatedAt: now,
+		CSRFToken: csrfToken,
 	}
 
 	if err := s.sessionRepo.Store(ctx, session); err != nil {
@@ -72,8 +81,7 @@ func (s *SessionService) StoreNewSession(ctx context.Context) (string, error) {
 func (s *SessionService) GetSessionByToken(ctx context.Context, plainToken string) (*domain.Session, error) {
 	const op = "SessionService.GetSessionByToken"
 
-	h := sha256.Sum256([]byte(plainToken))
-	tokenHash := hex.EncodeToString(h[:])
+	tokenHash := hashToken(plainToken)
 
 	session, err := s.sessionRepo.GetByHashedToken(ctx, tokenHash)
 	if err != nil {
@@ -83,6 +91,11 @@ func (s *SessionService) GetSessionByToken(ctx context.Context, plainToken strin
 		return nil, svcerr.NewError("session not found", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
 	}
 
+	// a replaced token only works until the grace period after the rotation is over
+	if tokenHash != session.TokenHash && s.timeSource().UTC().Sub(session.RotatedAt) > s.cfg.RotateGrace {
+		return nil, svcerr.NewError("session not found", ErrSessionNotFound, svcerr.ErrBadRequest)
+	}
+
 	if session.IsExpired() {
 		return nil, svcerr.NewError("session expired", ErrSessionExpired, svcerr.ErrBadRequest)
 	}
@@ -90,6 +103,70 @@ func (s *SessionService) GetSessionByToken(ctx context.Context, plainToken strin
 	return session, nil
 }
 
+// RefreshSession validates a token and, once the rotation interval has passed, replaces it with a new one
+// and slides the expiry forward. The token to keep using is returned together with the session.
+func (s *SessionService) RefreshSession(ctx context.Context, plainToken string) (string, *domain.Session, error) {
+	const op = "SessionService.RefreshSession"
+
+	session, err := s.GetSessionByToken(ctx, plainToken)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := s.timeSource().UTC()
+	tokenHash := hashToken(plainToken)
+	// a request still carrying the replaced token must not rotate again, the new token is already out
+	if tokenHash != session.TokenHash || !session.NeedsRotation(now, s.cfg.RotateInterval) {
+		return plainToken, session, nil
+	}
+
+	newToken, err := generateSessionToken()
+	if err != nil {
+		return "", nil, svcerr.NewError("failed to generate token", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+	newHash := hashToken(newToken)
+	expiresAt := now.Add(s.cfg.DefaultTTL)
+
+	if err := s.sessionRepo.Rotate(ctx, tokenHash, newHash, now, expiresAt); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			// a concurrent request rotated it first, the grace period covers this one
+			return plainToken, session, nil
+		}
+		return "", nil, svcerr.NewError("failed to rotate session", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+
+	session.PreviousTokenHash = tokenHash
+	session.TokenHash = newHash
+	session.RotatedAt = now
+	session.ExpiresAt = expiresAt
+
+	return newToken, session, nil
+}
+
+// Logout revokes the session of a token. Logging out of a session that is already gone is not an error.
+func (s *SessionService) Logout(ctx context.Context, plainToken string) error {
+	const op = "SessionService.Logout"
+
+	err := s.sessionRepo.RevokeByHash(ctx, hashToken(plainToken))
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return svcerr.NewError("failed to log out", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+
+	return nil
+}
+
+// RevokeUserSessions ends every session of a user and returns how many were revoked.
+func (s *SessionService) RevokeUserSessions(ctx context.Context, userID int64) (int64, error) {
+	const op = "SessionService.RevokeUserSessions"
+
+	revoked, err := s.sessionRepo.RevokeByUser(ctx, userID)
+	if err != nil {
+		return 0, svcerr.NewError("failed to revoke sessions", fmt.Errorf("%s: %w", op, err), svcerr.ErrInternal)
+	}
+
+	return revoked, nil
+}
+
 func (s *SessionService) UpdateUserName(ctx context.Context, plainToken string, username string) error {
 	const op = "SessionService.UpdateUserName"
 
@@ -99,8 +176,7 @@ func (s *SessionService) UpdateUserName(ctx context.Context, plainToken string,
 		return svcerr.NewError("too long name", errors.New("too short name min 3 characters"), svcerr.ErrBadRequest)
 	}
 
-	h := sha256.Sum256([]byte(plainToken))
-	tokenHash := hex.EncodeToString(h[:])
+	tokenHash := hashToken(plainToken)
 
 	err := s.sessionRepo.UpdateByToken(ctx, tokenHash, func(s *domain.Session) (bool, error) {
 		if username != "" {
@@ -116,6 +192,12 @@ func (s *SessionService) UpdateUserName(ctx context.Context, plainToken string,
 	return nil
 }
 
+// hashToken is what sessions are stored and looked up by, plain tokens never reach the repository.
+func hashToken(plainToken string) string {
+	h := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(h[:])
+}
+
 func generateSessionToken() (string, error) {
 	bytes := make([]byte, 32)
 	_, err := rand.Read(bytes)
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+type SessionCleanupRepository interface {
+	// DeleteExpiredSessions removes at most limit sessions that expired before the given time.
+	DeleteExpiredSessions(ctx context.Context, before time.Time, limit int) (int64, error)
+	// DeleteOrphanedUsers removes at most limit users left without sessions, posts, comments or images.
+	DeleteOrphanedUsers(ctx context.Context, limit int) (int64, error)
+}
+
+// CleanupResult reports what a cleanup pass removed.
+type CleanupResult struct {
+	Sessions int64 `json:"sessions"`
+	Users    int64 `json:"users"`
+}
+
+type CleanupStats struct {
+	Runs     int64     `json:"runs"`
+	Failures int64     `json:"failures"`
+	Sessions int64     `json:"sessions"`
+	Users    int64     `json:"users"`
+	LastRun  time.Time `json:"last_run"`
+}
+
+// SessionCleaner deletes expired sessions and then the anonymous users they leave behind.
+// Every session is created with its own user row, so users without sessions or content are reclaimed too.
+// Rows are deleted in batches to keep transactions and locks short.
+type SessionCleaner struct {
+	repo       SessionCleanupRepository
+	interval   time.Duration
+	batchSize  int
+	timeSource func() time.Time
+	logger     *slog.Logger
+
+	runs     atomic.Int64
+	failures atomic.Int64
+	sessions atomic.Int64
+	users    atomic.Int64
+	lastRun  atomic.Int64 // unix nanoseconds
+}
+
+func NewSessionCleaner(repo SessionCleanupRepository, interval time.Duration, batchSize int, timeSource func() time.Time, logger *slog.Logger) *SessionCleaner {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	return &SessionCleaner{
+		repo:       repo,
+		interval:   interval,
+		batchSize:  batchSize,
+		timeSource: timeSource,
+		logger:     logger,
+	}
+}
+
+// Run performs a cleanup right away and then every interval. It blocks until ctx is done.
+func (c *SessionCleaner) Run(ctx context.Context) {
+	const op = "SessionCleaner.Run"
+
+	c.logger.Info("session cleaner started", "op", op, "interval", c.interval.String(), "batch_size", c.batchSize)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.RunOnce(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			c.logger.Info("session cleaner stopped", "op", op)
+			return
+		}
+	}
+}
+
+// RunOnce performs a single cleanup pass and records its outcome. Counts removed before a failure are kept.
+func (c *SessionCleaner) RunOnce(ctx context.Context) (CleanupResult, error) {
+	const op = "SessionCleaner.RunOnce"
+
+	now := c.timeSource().UTC()
+	c.runs.Add(1)
+	c.lastRun.Store(now.UnixNano())
+
+	result, err := c.cleanup(ctx, now)
+	c.sessions.Add(result.Sessions)
+	c.users.Add(result.Users)
+	if err != nil {
+		failures := c.failures.Add(1)
+		attrs := []any{"op", op, "failures", failures, "sessions", result.Sessions, "users", result.Users, "err", err.Error()}
+		if cause := errors.Unwrap(err); cause != nil {
+			attrs = append(attrs, "cause", cause.Error())
+		}
+		c.logger.Error("session cleanup failed", attrs...)
+		return result, err
+	}
+
+	if result.Sessions > 0 || result.Users > 0 {
+		c.logger.Info("session cleanup finished", "op", op, "sessions", result.Sessions, "users", result.Users)
+	} else {
+		c.logger.Debug("session cleanup finished", "op", op)
+	}
+
+	return result, nil
+}
+
+func (c *SessionCleaner) cleanup(ctx context.Context, now time.Time) (CleanupResult, error) {
+	var result CleanupResult
+
+	// sessions first, their users only become orphaned once they are gone
+	for {
+		deleted, err := c.repo.DeleteExpiredSessions(ctx, now, c.batchSize)
+		result.Sessions += deleted
+		if err != nil {
+			return result, fmt.Errorf("delete expired sessions: %w", err)
+		}
+		if deleted < int64(c.batchSize) {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+	}
+
+	for {
+		deleted, err := c.repo.DeleteOrphanedUsers(ctx, c.batchSize)
+		result.Users += deleted
+		if err != nil {
+			return result, fmt.Errorf("delete orphaned users: %w", err)
+		}
+		if deleted < int64(c.batchSize) {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (c *SessionCleaner) Stats() CleanupStats {
+	stats := CleanupStats{
+		Runs:     c.runs.Load(),
+		Failures: c.failures.Load(),
+		Sessions: c.sessions.Load(),
+		Users:    c.users.Load(),
+	}
+	if lastRun := c.lastRun.Load(); lastRun != 0 {
+		stats.LastRun = time.Unix(0, lastRun).UTC()
+	}
+	return stats
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// mockSessionCleanupRepository holds expiry times of sessions and counts orphaned users.
+type mockSessionCleanupRepository struct {
+	expiresAt []time.Time
+	orphans   int64
+	calls     int
+	usersErr  error
+}
+
+func (m *mockSessionCleanupRepository) DeleteExpiredSessions(ctx context.Context, before time.Time, limit int) (int64, error) {
+	m.calls++
+	var kept []time.Time
+	var deleted int64
+	for _, expiresAt := range m.expiresAt {
+		if expiresAt.Before(before) && deleted < int64(limit) {
+			deleted++
+			// every expired session leaves its user behind
+			m.orphans++
+			continue
+		}
+		kept = append(kept, expiresAt)
+	}
+	m.expiresAt = kept
+	return deleted, nil
+}
+
+func (m *mockSessionCleanupRepository) DeleteOrphanedUsers(ctx context.Context, limit int) (int64, error) {
+	if m.usersErr != nil {
+		return 0, m.usersErr
+	}
+	deleted := min(m.orphans, int64(limit))
+	m.orphans -= deleted
+	return deleted, nil
+}
+
+func TestSessionCleaner_RunOnce(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	repo := &mockSessionCleanupRepository{orphans: 1}
+	for i := 0; i < 5; i++ {
+		repo.expiresAt = append(repo.expiresAt, now.Add(-time.Hour))
+	}
+	repo.expiresAt = append(repo.expiresAt, now.Add(time.Hour))
+
+	cleaner := NewSessionCleaner(repo, time.Hour, 2, func() time.Time { return now }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	result, err := cleaner.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Sessions != 5 || result.Users != 6 {
+		t.Fatalf("expected 5 sessions and 6 users, got %+v", result)
+	}
+	if repo.calls != 3 {
+		t.Fatalf("expected 3 batches, got %d", repo.calls)
+	}
+	if len(repo.expiresAt) != 1 {
+		t.Fatalf("expected the live session to be kept, got %d sessions", len(repo.expiresAt))
+	}
+
+	stats := cleaner.Stats()
+	if stats.Runs != 1 || stats.Sessions != 5 || stats.Users != 6 || !stats.LastRun.Equal(now) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSessionCleaner_RunOnce_Fail(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	repo := &mockSessionCleanupRepository{
+		expiresAt: []time.Time{now.Add(-time.Hour)},
+		usersErr:  errors.New("connection reset"),
+	}
+
+	cleaner := NewSessionCleaner(repo, time.Hour, 10, func() time.Time { return now }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	result, err := cleaner.RunOnce(context.Background())
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+	if result.Sessions != 1 {
+		t.Fatalf("expected the deleted session to be reported, got %+v", result)
+	}
+	if stats := cleaner.Stats(); stats.Failures != 1 || stats.Sessions != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
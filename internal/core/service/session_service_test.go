@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -9,28 +10,39 @@ import (
 	"go-hex-forum/internal/core/domain"
 )
 
+// mockSessionRepository keeps sessions in memory, like the postgres repository they are found by
+// their current or previous token hash.
 type mockSessionRepository struct {
 	saveFunc func(context.Context, domain.Session) error
-	// GetByHashedToken(context.Context, string) (*domain.Session, error)
-	// UpdateByToken(context.Context, string, func(*domain.Session) (bool, error)) error
-}
-
-func (m *mockSessionRepository) GetByHashedToken(context.Context, string) (*domain.Session, error) {
-	return &domain.Session{
-		ID:        1,
-		TokenHash: "",
-		User: domain.UserData{
-			ID:        0,
-			Name:      "Ex",
-			AvatarURL: "https://rickandmortyapi.com/api/character/avatar/1.jpeg",
-		},
-	}, nil
+	sessions []*domain.Session
+}
+
+func (m *mockSessionRepository) find(hashedToken string) (int, *domain.Session) {
+	for i, s := range m.sessions {
+		if s.TokenHash == hashedToken || (s.PreviousTokenHash != "" && s.PreviousTokenHash == hashedToken) {
+			return i, s
+		}
+	}
+	return -1, nil
+}
+
+func (m *mockSessionRepository) GetByHashedToken(ctx context.Context, hashedToken string) (*domain.Session, error) {
+	_, s := m.find(hashedToken)
+	if s == nil {
+		return nil, ErrSessionNotFound
+	}
+	session := *s
+	return &session, nil
 }
 
 func (m *mockSessionRepository) Store(ctx context.Context, s domain.Session) error {
 	if m.saveFunc != nil {
-		return m.saveFunc(ctx, s)
+		if err := m.saveFunc(ctx, s); err != nil {
+			return err
+		}
 	}
+	s.ID = int64(len(m.sessions) + 1)
+	m.sessions = append(m.sessions, &s)
 	return nil
 }
 
@@ -38,6 +50,38 @@ func (m *mockSessionRepository) UpdateByToken(context.Context, string, func(*dom
 	return nil
 }
 
+func (m *mockSessionRepository) Rotate(ctx context.Context, hashedToken, newHashedToken string, rotatedAt, expiresAt time.Time) error {
+	for _, s := range m.sessions {
+		if s.TokenHash == hashedToken {
+			s.PreviousTokenHash, s.TokenHash = s.TokenHash, newHashedToken
+			s.RotatedAt, s.ExpiresAt = rotatedAt, expiresAt
+			return nil
+		}
+	}
+	return ErrSessionNotFound
+}
+
+func (m *mockSessionRepository) RevokeByHash(ctx context.Context, hashedToken string) error {
+	i, _ := m.find(hashedToken)
+	if i < 0 {
+		return ErrSessionNotFound
+	}
+	m.sessions = append(m.sessions[:i], m.sessions[i+1:]...)
+	return nil
+}
+
+func (m *mockSessionRepository) RevokeByUser(ctx context.Context, userID int64) (int64, error) {
+	var kept []*domain.Session
+	for _, s := range m.sessions {
+		if s.User.ID != userID {
+			kept = append(kept, s)
+		}
+	}
+	revoked := int64(len(m.sessions) - len(kept))
+	m.sessions = kept
+	return revoked, nil
+}
+
 type mockUserDataProvider struct {
 	getFn func(ttl time.Duration) (domain.UserData, error)
 }
@@ -74,3 +118,129 @@ func TestStoreNewSession_Success(t *testing.T) {
 		t.Fatalf("expected no error, got %v", err)
 	}
 }
+
+// testClock is a settable time source.
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func newTestSessionService(repo *mockSessionRepository, clock *testClock) *SessionService {
+	return NewSessionService(repo, clock.Now, &mockUserDataProvider{}, config.SessionConfig{
+		DefaultTTL:     24 * time.Hour,
+		RotateInterval: time.Hour,
+		RotateGrace:    time.Minute,
+	})
+}
+
+func expectSessionNotFound(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected session not found, got %v", err)
+	}
+}
+
+func TestLogout_revokedTokenIsRejected(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	service := newTestSessionService(&mockSessionRepository{}, clock)
+
+	token, err := service.StoreNewSession(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := service.GetSessionByToken(context.Background(), token); err != nil {
+		t.Fatalf("expected a valid session, got %v", err)
+	}
+
+	if err := service.Logout(context.Background(), token); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_, err = service.GetSessionByToken(context.Background(), token)
+	expectSessionNotFound(t, err)
+	_, _, err = service.RefreshSession(context.Background(), token)
+	expectSessionNotFound(t, err)
+
+	// logging out twice is harmless
+	if err := service.Logout(context.Background(), token); err != nil {
+		t.Fatalf("expected no error on repeated logout, got %v", err)
+	}
+}
+
+func TestRefreshSession_rotates(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	service := newTestSessionService(&mockSessionRepository{}, clock)
+
+	token, err := service.StoreNewSession(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// not due yet
+	clock.now = clock.now.Add(30 * time.Minute)
+	same, _, err := service.RefreshSession(context.Background(), token)
+	if err != nil || same != token {
+		t.Fatalf("expected the token to be kept, got %q, %v", same, err)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	rotated, session, err := service.RefreshSession(context.Background(), token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rotated == token {
+		t.Fatalf("expected a new token")
+	}
+	if want := clock.now.UTC().Add(24 * time.Hour); !session.ExpiresAt.Equal(want) {
+		t.Fatalf("expected expiry to slide to %v, got %v", want, session.ExpiresAt)
+	}
+
+	// the old token keeps working during the grace period without rotating again
+	again, _, err := service.RefreshSession(context.Background(), token)
+	if err != nil || again != token {
+		t.Fatalf("expected the old token to be accepted during grace, got %q, %v", again, err)
+	}
+
+	clock.now = clock.now.Add(2 * time.Minute)
+	_, err = service.GetSessionByToken(context.Background(), token)
+	expectSessionNotFound(t, err)
+	if _, err := service.GetSessionByToken(context.Background(), rotated); err != nil {
+		t.Fatalf("expected the new token to work, got %v", err)
+	}
+}
+
+func TestLogout_revokesRotatedSession(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	service := newTestSessionService(&mockSessionRepository{}, clock)
+
+	token, _ := service.StoreNewSession(context.Background())
+	clock.now = clock.now.Add(2 * time.Hour)
+	rotated, _, err := service.RefreshSession(context.Background(), token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// logging out with the replaced token still ends the session
+	if err := service.Logout(context.Background(), token); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_, err = service.GetSessionByToken(context.Background(), rotated)
+	expectSessionNotFound(t, err)
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	repo := &mockSessionRepository{}
+	service := newTestSessionService(repo, &testClock{now: time.Now()})
+
+	first, _ := service.StoreNewSession(context.Background())
+	second, _ := service.StoreNewSession(context.Background())
+	repo.sessions[1].User.ID = 2
+
+	revoked, err := service.RevokeUserSessions(context.Background(), 0)
+	if err != nil || revoked != 1 {
+		t.Fatalf("expected 1 revoked session, got %d, %v", revoked, err)
+	}
+	_, err = service.GetSessionByToken(context.Background(), first)
+	expectSessionNotFound(t, err)
+	if _, err := service.GetSessionByToken(context.Background(), second); err != nil {
+		t.Fatalf("expected the other user's session to survive, got %v", err)
+	}
+}
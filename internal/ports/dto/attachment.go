@@ -0,0 +1,23 @@
+package dto
+
+import "go-hex-forum/internal/core/domain"
+
+type AttachmentResponse struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	PreviewURL   string `json:"preview_url,omitempty"`
+	AltText      string `json:"alt_text,omitempty"`
+}
+
+func ToAttachmentResponses(attachments []domain.Attachment) []AttachmentResponse {
+	resp := make([]AttachmentResponse, 0, len(attachments))
+	for _, a := range attachments {
+		resp = append(resp, AttachmentResponse{
+			URL:          a.Image.Path,
+			ThumbnailURL: a.Image.Thumbnail(),
+			PreviewURL:   a.Image.Preview(),
+			AltText:      a.AltText,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"encoding/base64"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+// ChallengeResponse describes a challenge to API clients. A proof of work is solved by finding a nonce such
+// that SHA-256 of seed followed by the nonce starts with difficulty zero bits, a captcha by reading image.
+type ChallengeResponse struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Difficulty int       `json:"difficulty"`
+	Seed       string    `json:"seed,omitempty"`
+	Image      string    `json:"image,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func ToChallengeResponse(c *domain.Challenge) ChallengeResponse {
+	resp := ChallengeResponse{
+		ID:         c.ID,
+		Kind:       string(c.Kind),
+		Difficulty: c.Difficulty,
+		ExpiresAt:  c.ExpiresAt,
+		Image:      ImageDataURI(c.Image),
+	}
+	if c.Kind == domain.ChallengeProofOfWork {
+		resp.Seed = c.Seed
+	}
+	return resp
+}
+
+// ImageDataURI embeds a PNG into a data URI, empty without an image.
+func ImageDataURI(png []byte) string {
+	if len(png) == 0 {
+		return ""
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+}
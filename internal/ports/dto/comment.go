@@ -12,12 +12,20 @@ type CreateCommentRequest struct {
 }
 
 type CommentResponse struct {
-	ID        int64     `json:"id"`
-	PostID    int64     `json:"post_id"`
-	Content   string    `json:"content"`
-	ImageURL  string    `json:"image_url,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	Author    UserData  `json:"author"`
+	ID              int64                `json:"id"`
+	PostID          int64                `json:"post_id"`
+	ParentCommentID *int64               `json:"parent_comment_id,omitempty"`
+	Content         string               `json:"content"`
+	ImageURL        string               `json:"image_url,omitempty"`
+	ThumbnailURL    string               `json:"thumbnail_url,omitempty"`
+	PreviewURL      string               `json:"preview_url,omitempty"`
+	Attachments     []AttachmentResponse `json:"attachments"`
+	CreatedAt       time.Time            `json:"created_at"`
+	EditedAt        *time.Time           `json:"edited_at,omitempty"`
+	Author          UserData             `json:"author"`
+	// Deleted marks a tombstone, kept so its replies stay in place
+	Deleted bool               `json:"deleted,omitempty"`
+	Replies []*CommentResponse `json:"replies"`
 }
 
 type UserData struct {
@@ -26,12 +34,59 @@ type UserData struct {
 }
 
 func RequestToDomain(req CreateCommentRequest, postID, userID int64) domain.Comment {
-	return domain.Comment{
-		PostID:    postID,
-		Content:   req.Content,
-		ImagePath: req.ImagePath,
+	comment := domain.Comment{
+		PostID:  postID,
+		Content: req.Content,
 		Author: domain.UserData{
 			ID: userID,
 		},
 	}
+	if req.ImagePath != "" {
+		comment.Attachments = []domain.Attachment{{Image: domain.Image{Path: req.ImagePath}}}
+	}
+	return comment
+}
+
+func ToCommentResponse(c *domain.Comment) *CommentResponse {
+	return &CommentResponse{
+		ID:              c.ID,
+		PostID:          c.PostID,
+		ParentCommentID: c.ParentCommentID,
+		Content:         c.Content,
+		ImageURL:        c.Image().Path,
+		ThumbnailURL:    c.Image().Thumbnail(),
+		PreviewURL:      c.Image().Preview(),
+		Attachments:     ToAttachmentResponses(c.Attachments),
+		CreatedAt:       c.CreatedAt,
+		EditedAt:        editedAt(c.EditedAt),
+		Author: UserData{
+			Name:   c.Author.Name,
+			Avatar: c.Author.AvatarURL,
+		},
+		Deleted: c.IsDeleted(),
+		Replies: []*CommentResponse{},
+	}
+}
+
+// ToCommentTree nests a flat, chronologically ordered comment list under their parents.
+// Replies whose parent is missing from the list are promoted to the top level.
+func ToCommentTree(comments []*domain.Comment) []*CommentResponse {
+	byID := make(map[int64]*CommentResponse, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = ToCommentResponse(c)
+	}
+
+	roots := []*CommentResponse{}
+	for _, c := range comments {
+		resp := byID[c.ID]
+		if c.ParentCommentID != nil {
+			if parent, ok := byID[*c.ParentCommentID]; ok {
+				parent.Replies = append(parent.Replies, resp)
+				continue
+			}
+		}
+		roots = append(roots, resp)
+	}
+
+	return roots
 }
@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"testing"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+func TestToCommentTree(t *testing.T) {
+	one, two := int64(1), int64(2)
+	missing := int64(99)
+	comments := []*domain.Comment{
+		{ID: 1, PostID: 1, Content: "root"},
+		{ID: 2, PostID: 1, ParentCommentID: &one, Content: "reply"},
+		{ID: 3, PostID: 1, ParentCommentID: &two, Content: "nested reply"},
+		{ID: 4, PostID: 1, Content: "second root"},
+		{ID: 5, PostID: 1, ParentCommentID: &missing, Content: "orphan"},
+	}
+
+	tree := ToCommentTree(comments)
+	if len(tree) != 3 {
+		t.Fatalf("expected 3 top-level comments, got %d", len(tree))
+	}
+	if tree[0].ID != 1 || tree[1].ID != 4 || tree[2].ID != 5 {
+		t.Fatalf("unexpected top-level order: %d, %d, %d", tree[0].ID, tree[1].ID, tree[2].ID)
+	}
+	if len(tree[0].Replies) != 1 || tree[0].Replies[0].ID != 2 {
+		t.Fatalf("expected comment 2 under comment 1, got %v", tree[0].Replies)
+	}
+	if len(tree[0].Replies[0].Replies) != 1 || tree[0].Replies[0].Replies[0].ID != 3 {
+		t.Fatalf("expected comment 3 under comment 2, got %v", tree[0].Replies[0].Replies)
+	}
+	if tree[1].Replies == nil {
+		t.Fatalf("expected empty replies slice, got nil")
+	}
+}
+
+func TestToCommentResponse_attachments(t *testing.T) {
+	resp := ToCommentResponse(&domain.Comment{
+		ID: 1,
+		Attachments: []domain.Attachment{
+			{Image: domain.Image{Path: "/first", ThumbnailPath: "/first-thumb"}, AltText: "first", Position: 0},
+			{Image: domain.Image{Path: "/second"}, Position: 1},
+		},
+	})
+	if resp.ImageURL != "/first" || resp.ThumbnailURL != "/first-thumb" {
+		t.Fatalf("expected the first attachment as cover, got %q, %q", resp.ImageURL, resp.ThumbnailURL)
+	}
+	if len(resp.Attachments) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(resp.Attachments))
+	}
+	if resp.Attachments[0].AltText != "first" || resp.Attachments[1].URL != "/second" || resp.Attachments[1].ThumbnailURL != "/second" {
+		t.Fatalf("unexpected attachments %+v", resp.Attachments)
+	}
+}
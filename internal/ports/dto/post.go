@@ -7,14 +7,28 @@ import (
 )
 
 type PostResponse struct {
-	ID              int64     `json:"id"`
-	AuthorName      string    `json:"author_name"`
-	AuthorAvatarURL string    `json:"author_avatar_url"`
-	Title           string    `json:"title"`
-	Content         string    `json:"content"`
-	ImageURL        string    `json:"image_url"`
-	CreatedAt       time.Time `json:"created_at"`
-	IsArchived      bool      `json:"is_archived"`
+	ID              int64  `json:"id"`
+	AuthorName      string `json:"author_name"`
+	AuthorAvatarURL string `json:"author_avatar_url"`
+	Title           string `json:"title"`
+	Content         string `json:"content"`
+	ImageURL        string `json:"image_url"`
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`
+	PreviewURL      string `json:"preview_url,omitempty"`
+	// Attachments are all images of the post in order, the URLs above describe the first one
+	Attachments []AttachmentResponse `json:"attachments"`
+	CreatedAt   time.Time            `json:"created_at"`
+	EditedAt    *time.Time           `json:"edited_at,omitempty"`
+	IsArchived  bool                 `json:"is_archived"`
+}
+
+type PostListResponse struct {
+	Posts      []*PostResponse `json:"posts"`
+	Page       int             `json:"page,omitempty"`
+	PageSize   int             `json:"page_size"`
+	Total      int64           `json:"total"`
+	TotalPages int             `json:"total_pages"`
+	NextCursor string          `json:"next_cursor,omitempty"`
 }
 
 type CreatePostRequest struct {
@@ -30,17 +44,38 @@ func ToPostResponse(p *domain.Post) *PostResponse {
 		AuthorAvatarURL: p.PostAuthor.AvatarURL,
 		Title:           p.Title,
 		Content:         p.Content,
-		ImageURL:        p.ImagePath, // при необходимости конверсия S3 path → публичный URL
+		ImageURL:        p.Image().Path, // при необходимости конверсия S3 path → публичный URL
+		ThumbnailURL:    p.Image().Thumbnail(),
+		PreviewURL:      p.Image().Preview(),
+		Attachments:     ToAttachmentResponses(p.Attachments),
 		CreatedAt:       p.CreatedAt,
+		EditedAt:        editedAt(p.EditedAt),
 		IsArchived:      p.IsArchived,
 	}
 }
 
+func ToPostListResponse(page domain.PostPage) *PostListResponse {
+	resp := &PostListResponse{
+		Posts:      make([]*PostResponse, 0, len(page.Posts)),
+		Page:       page.Page,
+		PageSize:   page.PageSize,
+		Total:      page.Total,
+		TotalPages: page.TotalPages(),
+	}
+	for i := range page.Posts {
+		resp.Posts = append(resp.Posts, ToPostResponse(&page.Posts[i]))
+	}
+	if page.NextCursor != nil {
+		resp.NextCursor = page.NextCursor.Encode()
+	}
+	return resp
+}
+
 func FromCreatePostRequest(req *CreatePostRequest, author domain.UserData) *domain.Post {
 	return &domain.Post{
 		PostAuthor: author,
 		Title:      req.Title,
 		Content:    req.Content,
-		// ImagePath заполняется позднее — например, после загрузки в S3
+		// Attachments заполняются позднее — после загрузки в хранилище
 	}
 }
@@ -0,0 +1,88 @@
+package dto
+
+import (
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+type ReportResponse struct {
+	ID        int64               `json:"id"`
+	PostID    int64               `json:"post_id,omitempty"`
+	CommentID int64               `json:"comment_id,omitempty"`
+	Reason    domain.ReportReason `json:"reason"`
+	Details   string              `json:"details,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// ReportGroupResponse is one entry of the moderation queue, the open reports on a post or comment.
+type ReportGroupResponse struct {
+	PostID    int64                       `json:"post_id,omitempty"`
+	CommentID int64                       `json:"comment_id,omitempty"`
+	Count     int                         `json:"count"`
+	Reasons   map[domain.ReportReason]int `json:"reasons"`
+	FirstAt   time.Time                   `json:"first_reported_at"`
+	LastAt    time.Time                   `json:"last_reported_at"`
+	Title     string                      `json:"title,omitempty"`
+	Content   string                      `json:"content"`
+	Deleted   bool                        `json:"deleted"`
+	Reports   []QueuedReportResponse      `json:"reports"`
+}
+
+// ReportQueueResponse is a page of the moderation queue.
+type ReportQueueResponse struct {
+	Groups     []ReportGroupResponse `json:"groups"`
+	Page       int                   `json:"page"`
+	PageSize   int                   `json:"page_size"`
+	Total      int64                 `json:"total"`
+	TotalPages int                   `json:"total_pages"`
+}
+
+// QueuedReportResponse is a report as moderators see it, with the user who filed it.
+type QueuedReportResponse struct {
+	ReportResponse
+	ReporterID int64 `json:"reporter_id,omitempty"`
+}
+
+func ToReportResponse(r domain.Report) ReportResponse {
+	return ReportResponse{
+		ID:        r.ID,
+		PostID:    r.Target.PostID,
+		CommentID: r.Target.CommentID,
+		Reason:    r.Reason,
+		Details:   r.Details,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+func ToReportQueueResponse(page domain.ReportPage) ReportQueueResponse {
+	resp := ReportQueueResponse{
+		Groups:     make([]ReportGroupResponse, 0, len(page.Groups)),
+		Page:       page.Page,
+		PageSize:   page.PageSize,
+		Total:      page.Total,
+		TotalPages: page.TotalPages(),
+	}
+	for _, g := range page.Groups {
+		group := ReportGroupResponse{
+			PostID:    g.Target.PostID,
+			CommentID: g.Target.CommentID,
+			Count:     g.Count(),
+			Reasons:   g.Reasons,
+			FirstAt:   g.FirstAt,
+			LastAt:    g.LastAt,
+			Reports:   make([]QueuedReportResponse, 0, len(g.Reports)),
+		}
+		switch {
+		case g.Post != nil:
+			group.Title, group.Content, group.Deleted = g.Post.Title, g.Post.Content, g.Post.IsDeleted()
+		case g.Comment != nil:
+			group.Content, group.Deleted = g.Comment.Content, g.Comment.IsDeleted()
+		}
+		for _, r := range g.Reports {
+			group.Reports = append(group.Reports, QueuedReportResponse{ReportResponse: ToReportResponse(r), ReporterID: r.ReporterID})
+		}
+		resp.Groups = append(resp.Groups, group)
+	}
+	return resp
+}
@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+type RevisionResponse struct {
+	ID       int64     `json:"id"`
+	Title    string    `json:"title,omitempty"`
+	Content  string    `json:"content"`
+	EditedBy string    `json:"edited_by"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// HistoryResponse is the current text of a post or comment followed by the texts it replaced, newest first.
+type HistoryResponse struct {
+	Kind      string             `json:"kind"`
+	ID        int64              `json:"id"`
+	Title     string             `json:"title,omitempty"`
+	Content   string             `json:"content"`
+	CreatedAt time.Time          `json:"created_at"`
+	EditedAt  *time.Time         `json:"edited_at,omitempty"`
+	Deleted   bool               `json:"deleted"`
+	Revisions []RevisionResponse `json:"revisions"`
+}
+
+func ToPostHistoryResponse(post domain.Post, revisions []domain.Revision) HistoryResponse {
+	return HistoryResponse{
+		Kind:      "post",
+		ID:        post.ID,
+		Title:     post.Title,
+		Content:   post.Content,
+		CreatedAt: post.CreatedAt,
+		EditedAt:  editedAt(post.EditedAt),
+		Deleted:   post.IsDeleted(),
+		Revisions: toRevisionResponses(revisions),
+	}
+}
+
+func ToCommentHistoryResponse(comment *domain.Comment, revisions []domain.Revision) HistoryResponse {
+	return HistoryResponse{
+		Kind:      "comment",
+		ID:        comment.ID,
+		Content:   comment.Content,
+		CreatedAt: comment.CreatedAt,
+		EditedAt:  editedAt(comment.EditedAt),
+		Deleted:   comment.IsDeleted(),
+		Revisions: toRevisionResponses(revisions),
+	}
+}
+
+func toRevisionResponses(revisions []domain.Revision) []RevisionResponse {
+	resp := make([]RevisionResponse, 0, len(revisions))
+	for _, r := range revisions {
+		resp = append(resp, RevisionResponse{
+			ID:       r.ID,
+			Title:    r.Title,
+			Content:  r.Content,
+			EditedBy: r.EditedBy,
+			EditedAt: r.EditedAt,
+		})
+	}
+	return resp
+}
+
+// editedAt leaves the edit time out of responses for content that was never edited.
+func editedAt(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
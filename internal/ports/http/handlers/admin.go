@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go-hex-forum/internal/core/service"
+	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/internal/utils"
+	"go-hex-forum/pkg/svcerr"
+)
+
+type ArchiveWorker interface {
+	RunOnce(ctx context.Context) (int64, error)
+	Stats() service.ArchiveStats
+}
+
+type SessionRevoker interface {
+	RevokeUserSessions(ctx context.Context, userID int64) (int64, error)
+}
+
+type AdminHandler struct {
+	archiveWorker ArchiveWorker
+	sessions      SessionRevoker
+}
+
+func NewAdminHandler(archiveWorker ArchiveWorker, sessions SessionRevoker) *AdminHandler {
+	return &AdminHandler{archiveWorker, sessions}
+}
+
+// RegisterEndpoints mounts the admin routes behind authMW.
+func (h *AdminHandler) RegisterEndpoints(mux *http.ServeMux, authMW func(http.Handler) http.Handler) {
+	mux.Handle("POST /admin/archive", authMW(http.HandlerFunc(h.RunArchive)))
+	mux.Handle("GET /admin/archive", authMW(http.HandlerFunc(h.GetArchiveStats)))
+	mux.Handle("DELETE /admin/users/{id}/sessions", authMW(http.HandlerFunc(h.RevokeUserSessions)))
+}
+
+func (h *AdminHandler) RunArchive(w http.ResponseWriter, r *http.Request) {
+	archived, err := h.archiveWorker.RunOnce(r.Context())
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]int64{"archived": archived})
+}
+
+func (h *AdminHandler) GetArchiveStats(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, h.archiveWorker.Stats())
+}
+
+func (h *AdminHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httperror.WriteError(w, svcerr.NewError("invalid user id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	revoked, err := h.sessions.RevokeUserSessions(r.Context(), userID)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]int64{"revoked": revoked})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
+)
+
+// deleteReasonField is the form field, or query parameter of API requests, a deletion reason is sent in.
+const deleteReasonField = "reason"
+
+// sessionActor returns the visitor behind r. Editing and deleting need an existing session, a new one would own nothing.
+func sessionActor(r *http.Request) (domain.Actor, error) {
+	session, ok := r.Context().Value("session").(*domain.Session)
+	if !ok {
+		return domain.Actor{}, svcerr.NewError("not authorized", errors.New("no session"), svcerr.ErrNotAuthorized)
+	}
+	return domain.Actor{UserID: session.User.ID}, nil
+}
+
+// viewerActor returns the visitor pages are rendered for, the zero actor for anonymous visitors.
+func viewerActor(r *http.Request) domain.Actor {
+	actor, _ := sessionActor(r)
+	return actor
+}
+
+// CommentActions are what the viewer of a page may do to one of its comments.
+type CommentActions struct {
+	Edit   bool
+	Delete bool
+}
+
+// commentActions lists what actor may do to each comment, for pages to offer the matching forms.
+func commentActions(comments []*domain.Comment, actor domain.Actor, service CommentService) map[int64]CommentActions {
+	actions := make(map[int64]CommentActions)
+	if actor == (domain.Actor{}) {
+		return actions
+	}
+	for _, c := range comments {
+		a := CommentActions{Edit: service.CanEditComment(c, actor), Delete: service.CanDeleteComment(c, actor)}
+		if a != (CommentActions{}) {
+			actions[c.ID] = a
+		}
+	}
+	return actions
+}
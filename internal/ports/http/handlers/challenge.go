@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/ports/dto"
+	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/internal/ports/http/middleware"
+	"go-hex-forum/internal/utils"
+)
+
+type ChallengeService interface {
+	Enabled() bool
+	Issue(ctx context.Context, subjects ...string) (*domain.Challenge, error)
+	Verify(ctx context.Context, id, answer string) error
+}
+
+// The form fields a solved challenge is sent back in.
+const (
+	challengeIDField     = "challenge_id"
+	challengeAnswerField = "challenge_answer"
+)
+
+// verifyChallenge checks the challenge answered by the form of r, it runs before anything is handed to a service.
+func verifyChallenge(r *http.Request, challenges ChallengeService) error {
+	return challenges.Verify(r.Context(), r.FormValue(challengeIDField), r.FormValue(challengeAnswerField))
+}
+
+// issueChallenge creates a challenge for the client of r, harder when its subjects tripped the rate limiter.
+func issueChallenge(r *http.Request, challenges ChallengeService) (*domain.Challenge, error) {
+	return challenges.Issue(r.Context(), middleware.RateLimitSubjects(r)...)
+}
+
+type ChallengeHandler struct {
+	challenges ChallengeService
+}
+
+func NewChallengeHandler(challenges ChallengeService) *ChallengeHandler {
+	return &ChallengeHandler{challenges}
+}
+
+func (h *ChallengeHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("GET /challenge", h.GetChallenge)
+}
+
+// GetChallenge issues a challenge for API clients and the forms of the pages, which fetch theirs once they
+// are used. Without challenges it answers 204.
+func (h *ChallengeHandler) GetChallenge(w http.ResponseWriter, r *http.Request) {
+	challenge, err := issueChallenge(r, h.challenges)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	if challenge == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToChallengeResponse(challenge))
+}
@@ -4,64 +4,73 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strconv"
 	"time"
 
 	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/ports/dto"
+	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/internal/ports/http/middleware"
 	"go-hex-forum/internal/utils"
+	"go-hex-forum/pkg/svcerr"
 )
 
 type CommentService interface {
-	SaveComment(ctx context.Context, comment *domain.Comment, imageData []byte) (int64, error)
+	SaveComment(ctx context.Context, comment *domain.Comment, uploads []domain.AttachmentUpload) (int64, error)
 	GetByPostID(ctx context.Context, postID int64) ([]*domain.Comment, error)
+	DeleteComment(ctx context.Context, commentID int64, actor domain.Actor, reason string) error
+	CanDeleteComment(comment *domain.Comment, actor domain.Actor) bool
+	EditComment(ctx context.Context, commentID int64, actor domain.Actor, content string) (*domain.Comment, error)
+	CanEditComment(comment *domain.Comment, actor domain.Actor) bool
 }
 
 type CommentHandler struct {
 	CommentService CommentService
+	challenges     ChallengeService
 }
 
-func NewCommentHandler(service CommentService) *CommentHandler {
-	return &CommentHandler{service}
+func NewCommentHandler(service CommentService, challenges ChallengeService) *CommentHandler {
+	return &CommentHandler{service, challenges}
 }
 
-func (h *CommentHandler) RegisterEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("POST /post/{id}/comment", h.CreateNewComment)
+// RegisterEndpoints mounts the comment routes, writes go through guard, which resolves the session and counts uploads.
+func (h *CommentHandler) RegisterEndpoints(mux *http.ServeMux, guard func(http.Handler) http.Handler) {
+	mux.Handle("POST /post/{id}/comment", guard(http.HandlerFunc(h.CreateNewComment)))
+	mux.HandleFunc("GET /post/{id}/comments", h.GetPostComments)
+	mux.HandleFunc("PATCH /comment/{id}", h.EditComment)
+	mux.HandleFunc("DELETE /comment/{id}", h.DeleteComment)
 }
 
 func (h *CommentHandler) CreateNewComment(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseMultipartForm(10 << 20)
+	err := r.ParseMultipartForm(middleware.MultipartMemory)
 	if err != nil {
 		utils.WriteError(w, http.StatusBadRequest, errors.New("could not parse form"))
+		return
+	}
+
+	if err := verifyChallenge(r, h.challenges); err != nil {
+		httperror.WriteError(w, err)
+		return
 	}
 
 	content := r.FormValue("comment")
 	var parentCommentID *int64
 	if rawParentID := r.FormValue("parent_comment_id"); rawParentID != "" {
 		id, err := strconv.ParseInt(rawParentID, 10, 64)
-		if err == nil {
-			parentCommentID = &id
+		if err != nil {
+			httperror.WriteError(w, svcerr.NewError("invalid parent comment id", err, svcerr.ErrBadRequest))
+			return
 		}
+		parentCommentID = &id
 	}
 
-	file, _, err := r.FormFile("image")
-	if err != nil && err != http.ErrMissingFile {
+	uploads, err := formAttachments(r)
+	if err != nil {
 		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("error reading image: %w", err))
 		return
 	}
-	if file != nil {
-		defer file.Close()
-	}
-
-	var imageData []byte
-	if file != nil {
-		imageData, err = io.ReadAll(file)
-		if err != nil {
-			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("error reading image: %w", err))
-			return
-		}
-	}
+	defer closeAttachments(uploads)
 
 	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
@@ -85,10 +94,68 @@ func (h *CommentHandler) CreateNewComment(w http.ResponseWriter, r *http.Request
 		CreatedAt: time.Now(),
 	}
 
-	_, err = h.CommentService.SaveComment(r.Context(), &comment, imageData)
+	_, err = h.CommentService.SaveComment(r.Context(), &comment, uploads)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		httperror.WriteError(w, err)
 		return
 	}
 	http.Redirect(w, r, fmt.Sprintf("/post/%d", postID), http.StatusSeeOther)
 }
+
+func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httperror.WriteError(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	comments, err := h.CommentService.GetByPostID(r.Context(), postID)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToCommentTree(comments))
+}
+
+// EditComment lets the author change the content of their comment, sent in the comment form field.
+func (h *CommentHandler) EditComment(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httperror.WriteError(w, svcerr.NewError("invalid comment id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	actor, err := sessionActor(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+
+	comment, err := h.CommentService.EditComment(r.Context(), commentID, actor, r.FormValue("comment"))
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToCommentResponse(comment))
+}
+
+// DeleteComment lets the author delete their comment, with an optional reason in the query string.
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httperror.WriteError(w, svcerr.NewError("invalid comment id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	actor, err := sessionActor(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+
+	if err := h.CommentService.DeleteComment(r.Context(), commentID, actor, r.URL.Query().Get(deleteReasonField)); err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
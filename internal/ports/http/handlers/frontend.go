@@ -3,7 +3,6 @@ package handlers
 import (
 	"fmt"
 	"html/template"
-	"io"
 	"log/slog"
 	"net/http"
 	"path/filepath"
@@ -12,6 +11,7 @@ import (
 
 	"go-hex-forum/internal/core/domain"
 	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/internal/ports/http/middleware"
 	"go-hex-forum/pkg/svcerr"
 )
 
@@ -20,33 +20,51 @@ type FrontendHandler struct {
 	postService    PostService
 	commentService CommentService
 	sessionService SessionService
+	challenges     ChallengeService
+	reports        ReportService
 	logger         *slog.Logger
 }
 
-func NewFrontendHandler(postService PostService, sessionService SessionService, commentService CommentService, tpl *template.Template, logger *slog.Logger) *FrontendHandler {
+func NewFrontendHandler(postService PostService, sessionService SessionService, commentService CommentService, challenges ChallengeService, reports ReportService, tpl *template.Template, logger *slog.Logger) *FrontendHandler {
 	tpl.ParseGlob(filepath.Join("web", "templates", "components", "*.html"))
 	return &FrontendHandler{
 		templates:      tpl,
 		postService:    postService,
 		sessionService: sessionService,
 		commentService: commentService,
+		challenges:     challenges,
+		reports:        reports,
 		logger:         logger,
 	}
 }
 
-func (h *FrontendHandler) RegisterFrontendEndpoints(mux *http.ServeMux) {
+// RegisterFrontendEndpoints mounts the pages. Pages are served without a session, the forms posting
+// content or reports go through guardPost, guardComment and guardReport, which resolve the session and
+// count uploads. Their other rate limits are applied before the router.
+func (h *FrontendHandler) RegisterFrontendEndpoints(mux *http.ServeMux, guardPost, guardComment, guardReport func(http.Handler) http.Handler) {
 	mux.HandleFunc("/", h.ShowIndex)
 	mux.HandleFunc("/create-post", h.ShowCreatePost)
-	mux.HandleFunc("/post", h.CreateNewPost)
+	mux.Handle("POST /post", guardPost(http.HandlerFunc(h.CreateNewPost)))
 	mux.HandleFunc("/archive", h.ShowArchive)
 	mux.HandleFunc("/post/{id}", h.ShowPost)
-	mux.HandleFunc("/post/{id}/comment", h.CreateNewComment)
+	mux.Handle("POST /post/{id}/comment", guardComment(http.HandlerFunc(h.CreateNewComment)))
+	mux.HandleFunc("POST /post/{id}/edit", h.EditPost)
+	mux.HandleFunc("POST /post/{id}/delete", h.DeletePost)
+	mux.HandleFunc("POST /post/{id}/comment/{commentID}/edit", h.EditComment)
+	mux.HandleFunc("POST /post/{id}/comment/{commentID}/delete", h.DeleteComment)
+	mux.Handle("POST /post/{id}/report", guardReport(http.HandlerFunc(h.ReportPost)))
+	mux.Handle("POST /post/{id}/comment/{commentID}/report", guardReport(http.HandlerFunc(h.ReportComment)))
 }
 
 func (h *FrontendHandler) ShowIndex(w http.ResponseWriter, r *http.Request) {
 	const op = "FrontendHandler.ShowIndex"
 	h.logger.Info("handling ShowIndex", "op", op, "method", r.Method)
-	posts, err := h.postService.GetActivePosts(r.Context())
+	pagination, err := parsePagination(r)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	page, err := h.postService.GetActivePosts(r.Context(), pagination)
 	if err != nil {
 		h.logger.Warn("failed to load active posts", "op", op, "err", err)
 		h.renderErrorPage(w, svcerr.NewError("failed to load posts", err, svcerr.ErrInternal))
@@ -54,16 +72,23 @@ func (h *FrontendHandler) ShowIndex(w http.ResponseWriter, r *http.Request) {
 	}
 	session, _ := r.Context().Value("session").(*domain.Session)
 	h.renderTemplate(w, "catalog.html", map[string]interface{}{
-		"Session": session,
-		"Posts":   posts,
+		"Session":    session,
+		"Posts":      page.Posts,
+		"Pagination": page,
+		"CSRFToken":  middleware.CSRFToken(r.Context()),
 	})
-	h.logger.Info("rendered ShowIndex", "op", op, "count", len(posts))
+	h.logger.Info("rendered ShowIndex", "op", op, "count", len(page.Posts))
 }
 
 func (h *FrontendHandler) ShowArchive(w http.ResponseWriter, r *http.Request) {
 	const op = "FrontendHandler.ShowArchive"
 	h.logger.Info("handling ShowArchive", "op", op, "method", r.Method)
-	posts, err := h.postService.GetArchivedPosts(r.Context())
+	pagination, err := parsePagination(r)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	page, err := h.postService.GetArchivedPosts(r.Context(), pagination)
 	if err != nil {
 		h.logger.Warn("failed to load archived posts", "op", op, "err", err)
 		h.renderErrorPage(w, err)
@@ -71,15 +96,20 @@ func (h *FrontendHandler) ShowArchive(w http.ResponseWriter, r *http.Request) {
 	}
 	session, _ := r.Context().Value("session").(*domain.Session)
 	h.renderTemplate(w, "archive.html", map[string]interface{}{
-		"Session": session,
-		"Posts":   posts,
+		"Session":    session,
+		"Posts":      page.Posts,
+		"Pagination": page,
+		"CSRFToken":  middleware.CSRFToken(r.Context()),
 	})
-	h.logger.Info("rendered ShowArchive", "op", op, "count", len(posts))
+	h.logger.Info("rendered ShowArchive", "op", op, "count", len(page.Posts))
 }
 
 func (h *FrontendHandler) ShowCreatePost(w http.ResponseWriter, r *http.Request) {
 	const op = "FrontendHandler.ShowCreatePost"
-	h.renderTemplate(w, "create-post.html", nil)
+	h.renderTemplate(w, "create-post.html", map[string]interface{}{
+		"CSRFToken":  middleware.CSRFToken(r.Context()),
+		"Challenges": h.challenges.Enabled(),
+	})
 	h.logger.Info("rendered ShowCreatePost", "op", op)
 }
 
@@ -87,31 +117,28 @@ func (h *FrontendHandler) CreateNewPost(w http.ResponseWriter, r *http.Request)
 	const op = "FrontendHandler.CreateNewPost"
 	h.logger.Info("handling CreateNewPost", "op", op)
 
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(middleware.MultipartMemory); err != nil {
 		h.logger.Warn("invalid form data", "op", op, "err", err)
 		h.renderErrorPage(w, svcerr.NewError("invalid form data", err, svcerr.ErrBadRequest))
 		return
 	}
 
+	if err := verifyChallenge(r, h.challenges); err != nil {
+		h.logger.Info("challenge failed", "op", op, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
 	title, content := r.FormValue("title"), r.FormValue("content")
 	h.logger.Info("form values", "op", op, "title", title)
 
-	file, _, err := r.FormFile("image")
-	if err != nil && err != http.ErrMissingFile {
+	uploads, err := formAttachments(r)
+	if err != nil {
 		h.logger.Warn("failed to read upload", "op", op, "err", err)
 		h.renderErrorPage(w, svcerr.NewError("failed to read upload", err, svcerr.ErrInternal))
 		return
 	}
-	var imageData []byte
-	if file != nil {
-		defer file.Close()
-		imageData, err = io.ReadAll(file)
-		if err != nil {
-			h.logger.Warn("failed to read image", "op", op, "err", err)
-			h.renderErrorPage(w, svcerr.NewError("failed to read image", err, svcerr.ErrInternal))
-			return
-		}
-	}
+	defer closeAttachments(uploads)
 
 	session, ok := r.Context().Value("session").(*domain.Session)
 	if !ok {
@@ -121,7 +148,7 @@ func (h *FrontendHandler) CreateNewPost(w http.ResponseWriter, r *http.Request)
 	}
 
 	post := &domain.Post{PostAuthor: session.User, Title: title, Content: content}
-	id, err := h.postService.CreateNewPost(r.Context(), post, imageData)
+	id, err := h.postService.CreateNewPost(r.Context(), post, uploads)
 	if err != nil {
 		h.logger.Warn("create post failed", "op", op, "err", err)
 		h.renderErrorPage(w, err)
@@ -153,19 +180,27 @@ func (h *FrontendHandler) ShowPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewer := viewerActor(r)
 	tpl := "post.html"
 	if post.IsArchived {
 		tpl = "archive-post.html"
 	}
 	h.renderTemplate(w, tpl, map[string]interface{}{
-		"UserAvatar": post.PostAuthor.AvatarURL,
-		"UserName":   post.PostAuthor.Name,
-		"DataTime":   post.CreatedAt,
-		"PostID":     post.ID,
-		"ImagePath":  post.ImagePath,
-		"Title":      post.Title,
-		"Content":    post.Content,
-		"Comments":   comments,
+		"UserAvatar":  post.PostAuthor.AvatarURL,
+		"UserName":    post.PostAuthor.Name,
+		"DataTime":    post.CreatedAt,
+		"PostID":      post.ID,
+		"Attachments": post.Attachments,
+		"Title":       post.Title,
+		"Content":     post.Content,
+		"EditedAt":    post.EditedAt,
+		"Comments":    comments,
+		"CanEdit":     h.postService.CanEditPost(post, viewer),
+		"CanDelete":   h.postService.CanDeletePost(post, viewer),
+		"Actions":     commentActions(comments, viewer, h.commentService),
+		"Reported":    r.URL.Query().Get("reported") != "",
+		"CSRFToken":   middleware.CSRFToken(r.Context()),
+		"Challenges":  h.challenges.Enabled(),
 	})
 }
 
@@ -173,12 +208,18 @@ func (h *FrontendHandler) CreateNewComment(w http.ResponseWriter, r *http.Reques
 	const op = "FrontendHandler.CreateNewComment"
 	h.logger.Info("handling CreateNewComment", "op", op)
 
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(middleware.MultipartMemory); err != nil {
 		h.logger.Warn("invalid comment form", "op", op, "err", err)
 		h.renderErrorPage(w, svcerr.NewError("invalid comment form", err, svcerr.ErrBadRequest))
 		return
 	}
 
+	if err := verifyChallenge(r, h.challenges); err != nil {
+		h.logger.Info("challenge failed", "op", op, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
 	content := r.FormValue("comment")
 	h.logger.Info("comment content received", "op", op, "len", len(content))
 
@@ -193,22 +234,13 @@ func (h *FrontendHandler) CreateNewComment(w http.ResponseWriter, r *http.Reques
 		parentID = &id
 	}
 
-	file, _, err := r.FormFile("image")
-	if err != nil && err != http.ErrMissingFile {
+	uploads, err := formAttachments(r)
+	if err != nil {
 		h.logger.Warn("failed to read upload file", "op", op, "err", err)
 		h.renderErrorPage(w, svcerr.NewError("failed to read upload", err, svcerr.ErrInternal))
 		return
 	}
-	var imageData []byte
-	if file != nil {
-		defer file.Close()
-		imageData, err = io.ReadAll(file)
-		if err != nil {
-			h.logger.Warn("failed to read comment image", "op", op, "err", err)
-			h.renderErrorPage(w, svcerr.NewError("failed to read image", err, svcerr.ErrInternal))
-			return
-		}
-	}
+	defer closeAttachments(uploads)
 
 	idStr := r.URL.Path[len("/post/") : len(r.URL.Path)-len("/comment")]
 	postID, err := strconv.ParseInt(idStr, 10, 64)
@@ -232,9 +264,9 @@ func (h *FrontendHandler) CreateNewComment(w http.ResponseWriter, r *http.Reques
 		Author:          domain.UserData{ID: session.User.ID},
 		CreatedAt:       time.Now(),
 	}
-	if _, err := h.commentService.SaveComment(r.Context(), &comment, imageData); err != nil {
+	if _, err := h.commentService.SaveComment(r.Context(), &comment, uploads); err != nil {
 		h.logger.Warn("save comment failed", "op", op, "err", err)
-		h.renderErrorPage(w, svcerr.NewError("could not save comment", err, svcerr.ErrInternal))
+		h.renderErrorPage(w, err)
 		return
 	}
 
@@ -242,6 +274,112 @@ func (h *FrontendHandler) CreateNewComment(w http.ResponseWriter, r *http.Reques
 	http.Redirect(w, r, fmt.Sprintf("/post/%d", postID), http.StatusSeeOther)
 }
 
+func (h *FrontendHandler) EditPost(w http.ResponseWriter, r *http.Request) {
+	const op = "FrontendHandler.EditPost"
+
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+	actor, err := sessionActor(r)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	if _, err := h.postService.EditPost(r.Context(), postID, actor, r.PostFormValue("title"), r.PostFormValue("content")); err != nil {
+		h.logger.Warn("edit post failed", "op", op, "postID", postID, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	h.logger.Info("post edited", "op", op, "postID", postID, "by", actor)
+	http.Redirect(w, r, fmt.Sprintf("/post/%d", postID), http.StatusSeeOther)
+}
+
+func (h *FrontendHandler) EditComment(w http.ResponseWriter, r *http.Request) {
+	const op = "FrontendHandler.EditComment"
+
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+	commentID, err := strconv.ParseInt(r.PathValue("commentID"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid comment id", err, svcerr.ErrBadRequest))
+		return
+	}
+	actor, err := sessionActor(r)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	if _, err := h.commentService.EditComment(r.Context(), commentID, actor, r.PostFormValue("comment")); err != nil {
+		h.logger.Warn("edit comment failed", "op", op, "commentID", commentID, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	h.logger.Info("comment edited", "op", op, "commentID", commentID, "by", actor)
+	http.Redirect(w, r, fmt.Sprintf("/post/%d#comment-%d", postID, commentID), http.StatusSeeOther)
+}
+
+func (h *FrontendHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	const op = "FrontendHandler.DeletePost"
+
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+	actor, err := sessionActor(r)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	if err := h.postService.DeletePost(r.Context(), postID, actor, r.PostFormValue(deleteReasonField)); err != nil {
+		h.logger.Warn("delete post failed", "op", op, "postID", postID, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	h.logger.Info("post deleted", "op", op, "postID", postID, "by", actor)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *FrontendHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	const op = "FrontendHandler.DeleteComment"
+
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+	commentID, err := strconv.ParseInt(r.PathValue("commentID"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid comment id", err, svcerr.ErrBadRequest))
+		return
+	}
+	actor, err := sessionActor(r)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	if err := h.commentService.DeleteComment(r.Context(), commentID, actor, r.PostFormValue(deleteReasonField)); err != nil {
+		h.logger.Warn("delete comment failed", "op", op, "commentID", commentID, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	h.logger.Info("comment deleted", "op", op, "commentID", commentID, "by", actor)
+	http.Redirect(w, r, fmt.Sprintf("/post/%d", postID), http.StatusSeeOther)
+}
+
 func (h *FrontendHandler) renderTemplate(w http.ResponseWriter, name string, data interface{}) {
 	if err := h.templates.ExecuteTemplate(w, name, data); err != nil {
 		httperror.WriteError(w, err)
@@ -256,3 +394,54 @@ func (h *FrontendHandler) renderErrorPage(w http.ResponseWriter, err error) {
 		"StatusCode": apiErr.StatusCode,
 	})
 }
+
+func (h *FrontendHandler) ReportPost(w http.ResponseWriter, r *http.Request) {
+	const op = "FrontendHandler.ReportPost"
+
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+	if err := h.report(r, domain.ReportTarget{PostID: postID}); err != nil {
+		h.logger.Warn("report post failed", "op", op, "postID", postID, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	h.logger.Info("post reported", "op", op, "postID", postID)
+	http.Redirect(w, r, fmt.Sprintf("/post/%d?reported=1", postID), http.StatusSeeOther)
+}
+
+func (h *FrontendHandler) ReportComment(w http.ResponseWriter, r *http.Request) {
+	const op = "FrontendHandler.ReportComment"
+
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+	commentID, err := strconv.ParseInt(r.PathValue("commentID"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid comment id", err, svcerr.ErrBadRequest))
+		return
+	}
+	if err := h.report(r, domain.ReportTarget{CommentID: commentID}); err != nil {
+		h.logger.Warn("report comment failed", "op", op, "commentID", commentID, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	h.logger.Info("comment reported", "op", op, "commentID", commentID)
+	http.Redirect(w, r, fmt.Sprintf("/post/%d?reported=1#comment-%d", postID, commentID), http.StatusSeeOther)
+}
+
+// report files the report form of r against target for the visitor's session.
+func (h *FrontendHandler) report(r *http.Request, target domain.ReportTarget) error {
+	actor, err := sessionActor(r)
+	if err != nil {
+		return err
+	}
+	_, err = h.reports.Report(r.Context(), actor.UserID, target, domain.ReportReason(r.PostFormValue(reportReasonField)), r.PostFormValue(reportDetailsField))
+	return err
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"go-hex-forum/internal/utils"
+)
+
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	db Pinger
+}
+
+func NewHealthHandler(db Pinger) *HealthHandler {
+	return &HealthHandler{db}
+}
+
+// RegisterEndpoints mounts the health check. It is meant for load balancers and monitoring,
+// so it is mounted outside the session middleware.
+func (h *HealthHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.Health)
+}
+
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.PingContext(r.Context()); err != nil {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
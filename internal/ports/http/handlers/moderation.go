@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/ports/dto"
+	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/internal/ports/http/middleware"
+	"go-hex-forum/internal/utils"
+	"go-hex-forum/pkg/svcerr"
+)
+
+type ModeratorService interface {
+	Login(ctx context.Context, login, password string) (string, domain.ModeratorSession, error)
+	Authenticate(ctx context.Context, token string) (domain.ModeratorSession, error)
+	Logout(ctx context.Context, token string) error
+}
+
+type ModerationCommentService interface {
+	GetRecentComments(ctx context.Context, limit int) ([]*domain.Comment, error)
+	DeleteComment(ctx context.Context, commentID int64, actor domain.Actor, reason string) error
+	GetCommentHistory(ctx context.Context, commentID int64) (*domain.Comment, []domain.Revision, error)
+}
+
+type ModerationReportService interface {
+	Queue(ctx context.Context, pagination domain.Pagination) (domain.ReportPage, error)
+	Resolve(ctx context.Context, target domain.ReportTarget, moderator domain.Actor, action domain.ReportAction, note string) (int64, error)
+}
+
+// Form fields closing reports.
+const (
+	reportActionField = "action"
+	reportNoteField   = "note"
+)
+
+// moderatorCookie holds the staff session, it is only sent to the admin pages.
+const moderatorCookie = "moderator_session"
+
+// recentCommentsLimit is how many comments the admin panel lists.
+const recentCommentsLimit = 30
+
+func setModeratorCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     moderatorCookie,
+		Value:    token,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/admin",
+	})
+}
+
+func clearModeratorCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     moderatorCookie,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/admin",
+	})
+}
+
+// ModerationHandler serves the admin panel staff run the board from.
+type ModerationHandler struct {
+	templates  *template.Template
+	moderators ModeratorService
+	posts      PostService
+	comments   ModerationCommentService
+	reports    ModerationReportService
+	sessions   SessionRevoker
+	archive    ArchiveWorker
+	logger     *slog.Logger
+}
+
+func NewModerationHandler(tpl *template.Template, moderators ModeratorService, posts PostService, comments ModerationCommentService, reports ModerationReportService, sessions SessionRevoker, archive ArchiveWorker, logger *slog.Logger) *ModerationHandler {
+	return &ModerationHandler{
+		templates:  tpl,
+		moderators: moderators,
+		posts:      posts,
+		comments:   comments,
+		reports:    reports,
+		sessions:   sessions,
+		archive:    archive,
+		logger:     logger,
+	}
+}
+
+// RegisterEndpoints mounts the admin pages and, under /admin/api/, their JSON counterparts. Signing in goes
+// through loginLimit, everything else behind it needs a staff session with the role of the action.
+func (h *ModerationHandler) RegisterEndpoints(mux *http.ServeMux, loginLimit func(http.Handler) http.Handler) {
+	moderator := h.RequireModerator(domain.RoleModerator)
+	admin := h.RequireModerator(domain.RoleAdmin)
+
+	mux.HandleFunc("GET /admin/login", h.ShowLogin)
+	mux.Handle("POST /admin/login", loginLimit(http.HandlerFunc(h.Login)))
+	mux.HandleFunc("POST /admin/logout", h.Logout)
+	mux.Handle("GET /admin", moderator(http.HandlerFunc(h.ShowPanel)))
+	mux.Handle("POST /admin/users/{id}/revoke", moderator(http.HandlerFunc(h.RevokeUserSessions)))
+	mux.Handle("POST /admin/posts/{id}/delete", moderator(http.HandlerFunc(h.DeletePost)))
+	mux.Handle("POST /admin/comments/{id}/delete", moderator(http.HandlerFunc(h.DeleteComment)))
+	mux.Handle("GET /admin/posts/{id}/revisions", moderator(http.HandlerFunc(h.ShowPostHistory)))
+	mux.Handle("GET /admin/comments/{id}/revisions", moderator(http.HandlerFunc(h.ShowCommentHistory)))
+	mux.Handle("GET /admin/api/posts/{id}/revisions", moderator(http.HandlerFunc(h.GetPostHistory)))
+	mux.Handle("GET /admin/api/comments/{id}/revisions", moderator(http.HandlerFunc(h.GetCommentHistory)))
+	mux.Handle("GET /admin/reports", moderator(http.HandlerFunc(h.ShowReports)))
+	mux.Handle("POST /admin/reports/resolve", moderator(http.HandlerFunc(h.ResolveReports)))
+	mux.Handle("GET /admin/api/reports", moderator(http.HandlerFunc(h.GetReports)))
+	mux.Handle("POST /admin/api/reports/resolve", moderator(http.HandlerFunc(h.ResolveReportsJSON)))
+	mux.Handle("POST /admin/archive", admin(http.HandlerFunc(h.RunArchive)))
+}
+
+// RequireModerator lets through staff whose role includes role. Pages opened without a staff session are
+// redirected to the login form.
+func (h *ModerationHandler) RequireModerator(role domain.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie(moderatorCookie)
+			if err != nil || cookie.Value == "" {
+				h.rejectAnonymous(w, r)
+				return
+			}
+
+			session, err := h.moderators.Authenticate(r.Context(), cookie.Value)
+			if err != nil {
+				var svcErr *svcerr.Error
+				if errors.As(err, &svcErr) && svcErr.AppErr == svcerr.ErrNotAuthorized {
+					clearModeratorCookie(w)
+					h.rejectAnonymous(w, r)
+					return
+				}
+				h.fail(w, r, err)
+				return
+			}
+			if !session.Role.Includes(role) {
+				h.fail(w, r, svcerr.NewError("forbidden", fmt.Errorf("%s is a %s, %s required", session.Login, session.Role, role), svcerr.ErrForbidden))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "moderator", &session)))
+		})
+	}
+}
+
+func (h *ModerationHandler) rejectAnonymous(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && !isModerationAPI(r) {
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	}
+	h.fail(w, r, svcerr.NewError("not authorized", errors.New("no moderator session"), svcerr.ErrNotAuthorized))
+}
+
+// isModerationAPI reports whether r asks for JSON rather than a page.
+func isModerationAPI(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/admin/api/")
+}
+
+// fail answers JSON requests with a JSON error and page requests with the error page.
+func (h *ModerationHandler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if isModerationAPI(r) {
+		httperror.WriteError(w, err)
+		return
+	}
+	h.renderErrorPage(w, err)
+}
+
+func (h *ModerationHandler) ShowLogin(w http.ResponseWriter, r *http.Request) {
+	h.renderTemplate(w, "admin-login.html", map[string]interface{}{
+		"CSRFToken": middleware.CSRFToken(r.Context()),
+	})
+}
+
+func (h *ModerationHandler) Login(w http.ResponseWriter, r *http.Request) {
+	const op = "ModerationHandler.Login"
+
+	if err := r.ParseForm(); err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid form data", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	login := r.PostFormValue("login")
+	token, session, err := h.moderators.Login(r.Context(), login, r.PostFormValue("password"))
+	if err != nil {
+		h.logger.Warn("moderator login failed", "op", op, "login", login, "ip", middleware.ClientIP(r), "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+	h.logger.Info("moderator logged in", "op", op, "login", session.Login, "role", session.Role)
+
+	setModeratorCookie(w, token, session.ExpiresAt)
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// Logout ends the staff session, the anonymous session of the board is left alone.
+func (h *ModerationHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(moderatorCookie); err == nil && cookie.Value != "" {
+		if err := h.moderators.Logout(r.Context(), cookie.Value); err != nil {
+			h.renderErrorPage(w, err)
+			return
+		}
+	}
+
+	clearModeratorCookie(w)
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+func (h *ModerationHandler) ShowPanel(w http.ResponseWriter, r *http.Request) {
+	const op = "ModerationHandler.ShowPanel"
+
+	pagination, err := parsePagination(r)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	posts, err := h.posts.GetActivePosts(r.Context(), pagination)
+	if err != nil {
+		h.logger.Warn("failed to load posts", "op", op, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+	comments, err := h.comments.GetRecentComments(r.Context(), recentCommentsLimit)
+	if err != nil {
+		h.logger.Warn("failed to load comments", "op", op, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	moderator, _ := r.Context().Value("moderator").(*domain.ModeratorSession)
+	h.renderTemplate(w, "admin.html", map[string]interface{}{
+		"Moderator":  moderator,
+		"IsAdmin":    moderator.Role.Includes(domain.RoleAdmin),
+		"Posts":      posts.Posts,
+		"Pagination": posts,
+		"Comments":   comments,
+		"Archive":    h.archive.Stats(),
+		"CSRFToken":  middleware.CSRFToken(r.Context()),
+	})
+}
+
+func (h *ModerationHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	const op = "ModerationHandler.RevokeUserSessions"
+
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid user id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	revoked, err := h.sessions.RevokeUserSessions(r.Context(), userID)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	moderator, _ := r.Context().Value("moderator").(*domain.ModeratorSession)
+	h.logger.Info("user sessions revoked", "op", op, "moderator", moderator.Login, "userID", userID, "revoked", revoked)
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (h *ModerationHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	const op = "ModerationHandler.DeletePost"
+
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	moderator, _ := r.Context().Value("moderator").(*domain.ModeratorSession)
+	reason := r.PostFormValue(deleteReasonField)
+	if err := h.posts.DeletePost(r.Context(), postID, domain.Actor{Moderator: moderator.Login}, reason); err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	h.logger.Info("post deleted", "op", op, "moderator", moderator.Login, "postID", postID, "reason", reason)
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (h *ModerationHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	const op = "ModerationHandler.DeleteComment"
+
+	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid comment id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	moderator, _ := r.Context().Value("moderator").(*domain.ModeratorSession)
+	reason := r.PostFormValue(deleteReasonField)
+	if err := h.comments.DeleteComment(r.Context(), commentID, domain.Actor{Moderator: moderator.Login}, reason); err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	h.logger.Info("comment deleted", "op", op, "moderator", moderator.Login, "commentID", commentID, "reason", reason)
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (h *ModerationHandler) ShowPostHistory(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	post, revisions, err := h.posts.GetPostHistory(r.Context(), postID)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	h.renderTemplate(w, "admin-revisions.html", map[string]interface{}{
+		"Kind":      "Post",
+		"ID":        post.ID,
+		"Link":      fmt.Sprintf("/post/%d", post.ID),
+		"Title":     post.Title,
+		"Content":   post.Content,
+		"CreatedAt": post.CreatedAt,
+		"EditedAt":  post.EditedAt,
+		"Deleted":   post.Deleted,
+		"Revisions": revisions,
+	})
+}
+
+func (h *ModerationHandler) ShowCommentHistory(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.renderErrorPage(w, svcerr.NewError("invalid comment id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	comment, revisions, err := h.comments.GetCommentHistory(r.Context(), commentID)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	h.renderTemplate(w, "admin-revisions.html", map[string]interface{}{
+		"Kind":      "Comment",
+		"ID":        comment.ID,
+		"Link":      fmt.Sprintf("/post/%d#comment-%d", comment.PostID, comment.ID),
+		"Content":   comment.Content,
+		"CreatedAt": comment.CreatedAt,
+		"EditedAt":  comment.EditedAt,
+		"Deleted":   comment.Deleted,
+		"Revisions": revisions,
+	})
+}
+
+func (h *ModerationHandler) GetPostHistory(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httperror.WriteError(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	post, revisions, err := h.posts.GetPostHistory(r.Context(), postID)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToPostHistoryResponse(post, revisions))
+}
+
+func (h *ModerationHandler) GetCommentHistory(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httperror.WriteError(w, svcerr.NewError("invalid comment id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	comment, revisions, err := h.comments.GetCommentHistory(r.Context(), commentID)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToCommentHistoryResponse(comment, revisions))
+}
+
+func (h *ModerationHandler) ShowReports(w http.ResponseWriter, r *http.Request) {
+	const op = "ModerationHandler.ShowReports"
+
+	pagination, err := parsePagination(r)
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	page, err := h.reports.Queue(r.Context(), pagination)
+	if err != nil {
+		h.logger.Warn("failed to load reports", "op", op, "err", err)
+		h.renderErrorPage(w, err)
+		return
+	}
+
+	moderator, _ := r.Context().Value("moderator").(*domain.ModeratorSession)
+	h.renderTemplate(w, "admin-reports.html", map[string]interface{}{
+		"Moderator":  moderator,
+		"Groups":     page.Groups,
+		"Pagination": page,
+		"CSRFToken":  middleware.CSRFToken(r.Context()),
+	})
+}
+
+func (h *ModerationHandler) ResolveReports(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.resolveReports(r); err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+}
+
+func (h *ModerationHandler) GetReports(w http.ResponseWriter, r *http.Request) {
+	pagination, err := parsePagination(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	page, err := h.reports.Queue(r.Context(), pagination)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToReportQueueResponse(page))
+}
+
+func (h *ModerationHandler) ResolveReportsJSON(w http.ResponseWriter, r *http.Request) {
+	closed, err := h.resolveReports(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]int64{"closed": closed})
+}
+
+// resolveReports closes the open reports on the target of the form in r with its action and note. The
+// deleted action deletes the target too, with the note as the reason.
+func (h *ModerationHandler) resolveReports(r *http.Request) (int64, error) {
+	const op = "ModerationHandler.resolveReports"
+
+	target, err := formReportTarget(r)
+	if err != nil {
+		return 0, err
+	}
+	moderator, _ := r.Context().Value("moderator").(*domain.ModeratorSession)
+	action := domain.ReportAction(r.PostFormValue(reportActionField))
+
+	closed, err := h.reports.Resolve(r.Context(), target, domain.Actor{Moderator: moderator.Login}, action, r.PostFormValue(reportNoteField))
+	if err != nil {
+		return 0, err
+	}
+	h.logger.Info("reports closed", "op", op, "moderator", moderator.Login, "target", target, "action", action, "closed", closed)
+	return closed, nil
+}
+
+func (h *ModerationHandler) RunArchive(w http.ResponseWriter, r *http.Request) {
+	const op = "ModerationHandler.RunArchive"
+
+	archived, err := h.archive.RunOnce(r.Context())
+	if err != nil {
+		h.renderErrorPage(w, err)
+		return
+	}
+	moderator, _ := r.Context().Value("moderator").(*domain.ModeratorSession)
+	h.logger.Info("archive pass run", "op", op, "moderator", moderator.Login, "archived", archived)
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (h *ModerationHandler) renderTemplate(w http.ResponseWriter, name string, data interface{}) {
+	if err := h.templates.ExecuteTemplate(w, name, data); err != nil {
+		httperror.WriteError(w, err)
+	}
+}
+
+func (h *ModerationHandler) renderErrorPage(w http.ResponseWriter, err error) {
+	apiErr := httperror.FromError(err)
+	w.WriteHeader(apiErr.StatusCode)
+	h.templates.ExecuteTemplate(w, "error.html", map[string]interface{}{
+		"Message":    apiErr.Message,
+		"StatusCode": apiErr.StatusCode,
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
+)
+
+// fakeModeratorService knows one token per role.
+type fakeModeratorService struct{}
+
+func (fakeModeratorService) Login(ctx context.Context, login, password string) (string, domain.ModeratorSession, error) {
+	return "", domain.ModeratorSession{}, errors.New("not used")
+}
+
+func (fakeModeratorService) Authenticate(ctx context.Context, token string) (domain.ModeratorSession, error) {
+	if role := domain.Role(token); role.Valid() {
+		return domain.ModeratorSession{Login: token, Role: role}, nil
+	}
+	return domain.ModeratorSession{}, svcerr.NewError("not authorized", errors.New("unknown token"), svcerr.ErrNotAuthorized)
+}
+
+func (fakeModeratorService) Logout(ctx context.Context, token string) error {
+	return nil
+}
+
+func TestRequireModerator(t *testing.T) {
+	h := &ModerationHandler{
+		templates:  template.Must(template.New("error.html").Parse(`{{.Message}}`)),
+		moderators: fakeModeratorService{},
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	handler := h.RequireModerator(domain.RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("moderator").(*domain.ModeratorSession); !ok {
+			t.Fatalf("expected the staff session in the context")
+		}
+	}))
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		code   int
+	}{
+		{"page without session", http.MethodGet, "/admin/archive", "", http.StatusSeeOther},
+		{"action without session", http.MethodPost, "/admin/archive", "", http.StatusUnauthorized},
+		{"json without session", http.MethodGet, "/admin/api/reports", "", http.StatusUnauthorized},
+		{"unknown token", http.MethodGet, "/admin/archive", "forged", http.StatusSeeOther},
+		{"moderator on an admin action", http.MethodPost, "/admin/archive", "moderator", http.StatusForbidden},
+		{"admin", http.MethodPost, "/admin/archive", "admin", http.StatusOK},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, nil)
+		if c.token != "" {
+			r.AddCookie(&http.Cookie{Name: moderatorCookie, Value: c.token})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != c.code {
+			t.Fatalf("%s: expected %d, got %d", c.name, c.code, rec.Code)
+		}
+	}
+}
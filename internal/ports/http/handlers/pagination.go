@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
+)
+
+// parsePagination reads the page, page_size and cursor query parameters.
+// Missing values fall back to the service defaults.
+func parsePagination(r *http.Request) (domain.Pagination, error) {
+	var pagination domain.Pagination
+	query := r.URL.Query()
+
+	if raw := query.Get("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil {
+			return pagination, svcerr.NewError("invalid page", fmt.Errorf("parse page %q: %w", raw, err), svcerr.ErrBadRequest)
+		}
+		pagination.Page = page
+	}
+
+	if raw := query.Get("page_size"); raw != "" {
+		pageSize, err := strconv.Atoi(raw)
+		if err != nil {
+			return pagination, svcerr.NewError("invalid page size", fmt.Errorf("parse page_size %q: %w", raw, err), svcerr.ErrBadRequest)
+		}
+		pagination.PageSize = pageSize
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := domain.DecodeCursor(raw)
+		if err != nil {
+			return pagination, svcerr.NewError("invalid cursor", err, svcerr.ErrBadRequest)
+		}
+		pagination.Cursor = cursor
+	}
+
+	return pagination, nil
+}
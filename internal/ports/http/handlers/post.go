@@ -3,59 +3,69 @@ package handlers
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
+	"strconv"
 
 	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/ports/dto"
 	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/internal/ports/http/middleware"
+	"go-hex-forum/internal/utils"
+	"go-hex-forum/pkg/svcerr"
 )
 
 type PostService interface {
-	CreateNewPost(ctx context.Context, post *domain.Post, imageData []byte) (int64, error)
-	GetActivePosts(context.Context) ([]domain.Post, error)
-	GetArchivedPosts(context.Context) ([]domain.Post, error)
+	CreateNewPost(ctx context.Context, post *domain.Post, uploads []domain.AttachmentUpload) (int64, error)
+	GetActivePosts(context.Context, domain.Pagination) (domain.PostPage, error)
+	GetArchivedPosts(context.Context, domain.Pagination) (domain.PostPage, error)
 	GetPostByID(ctx context.Context, postID int64) (domain.Post, error)
+	DeletePost(ctx context.Context, postID int64, actor domain.Actor, reason string) error
+	CanDeletePost(post domain.Post, actor domain.Actor) bool
+	EditPost(ctx context.Context, postID int64, actor domain.Actor, title, content string) (domain.Post, error)
+	CanEditPost(post domain.Post, actor domain.Actor) bool
+	GetPostHistory(ctx context.Context, postID int64) (domain.Post, []domain.Revision, error)
 }
 
 type PostHandler struct {
 	postService PostService
+	challenges  ChallengeService
 }
 
-func NewPostHandler(postService PostService) *PostHandler {
-	return &PostHandler{postService}
+func NewPostHandler(postService PostService, challenges ChallengeService) *PostHandler {
+	return &PostHandler{postService, challenges}
 }
 
-func (h *PostHandler) RegisterEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("POST /post", h.CreateNewPost)
+// RegisterEndpoints mounts the post routes, writes go through guard, which resolves the session and counts uploads.
+func (h *PostHandler) RegisterEndpoints(mux *http.ServeMux, guard func(http.Handler) http.Handler) {
+	mux.Handle("POST /post", guard(http.HandlerFunc(h.CreateNewPost)))
+	mux.HandleFunc("GET /posts", h.GetActivePosts)
+	mux.HandleFunc("GET /archive", h.GetArchivedPosts)
+	mux.HandleFunc("GET /post/{id}", h.GetPostByID)
+	mux.HandleFunc("PATCH /post/{id}", h.EditPost)
+	mux.HandleFunc("DELETE /post/{id}", h.DeletePost)
 }
 
 func (h *PostHandler) CreateNewPost(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseMultipartForm(10 << 20)
+	err := r.ParseMultipartForm(middleware.MultipartMemory)
 	if err != nil {
 		httperror.WriteError(w, err)
 		return
 	}
 
+	if err := verifyChallenge(r, h.challenges); err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+
 	title := r.FormValue("title")
 	content := r.FormValue("content")
 
-	file, _, err := r.FormFile("image")
-	if err != nil && err != http.ErrMissingFile {
+	uploads, err := formAttachments(r)
+	if err != nil {
 		httperror.WriteError(w, err)
 		return
 	}
-	if file != nil {
-		defer file.Close()
-	}
-
-	var imageData []byte
-	if file != nil {
-		imageData, err = io.ReadAll(file)
-		if err != nil {
-			httperror.WriteError(w, err)
-			return
-		}
-	}
+	defer closeAttachments(uploads)
 
 	session, ok := r.Context().Value("session").(*domain.Session)
 	if !ok {
@@ -70,10 +80,98 @@ func (h *PostHandler) CreateNewPost(w http.ResponseWriter, r *http.Request) {
 	}
 	fmt.Printf("%v", post)
 
-	id, err := h.postService.CreateNewPost(r.Context(), post, imageData)
+	id, err := h.postService.CreateNewPost(r.Context(), post, uploads)
 	if err != nil {
 		httperror.WriteError(w, err)
 		return
 	}
 	http.Redirect(w, r, fmt.Sprintf("/post/%d", id), http.StatusSeeOther)
 }
+
+func (h *PostHandler) GetActivePosts(w http.ResponseWriter, r *http.Request) {
+	pagination, err := parsePagination(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+
+	page, err := h.postService.GetActivePosts(r.Context(), pagination)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToPostListResponse(page))
+}
+
+func (h *PostHandler) GetArchivedPosts(w http.ResponseWriter, r *http.Request) {
+	pagination, err := parsePagination(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+
+	page, err := h.postService.GetArchivedPosts(r.Context(), pagination)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToPostListResponse(page))
+}
+
+func (h *PostHandler) GetPostByID(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httperror.WriteError(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	post, err := h.postService.GetPostByID(r.Context(), postID)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToPostResponse(&post))
+}
+
+// EditPost lets the author change the title and content of their post, sent as form fields.
+func (h *PostHandler) EditPost(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httperror.WriteError(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	actor, err := sessionActor(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+
+	post, err := h.postService.EditPost(r.Context(), postID, actor, r.FormValue("title"), r.FormValue("content"))
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.ToPostResponse(&post))
+}
+
+// DeletePost lets the author delete their post, with an optional reason in the query string.
+func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httperror.WriteError(w, svcerr.NewError("invalid post id", err, svcerr.ErrBadRequest))
+		return
+	}
+
+	actor, err := sessionActor(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+
+	if err := h.postService.DeletePost(r.Context(), postID, actor, r.URL.Query().Get(deleteReasonField)); err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
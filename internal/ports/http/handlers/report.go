@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/ports/dto"
+	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/internal/utils"
+	"go-hex-forum/pkg/svcerr"
+)
+
+type ReportService interface {
+	Report(ctx context.Context, reporterID int64, target domain.ReportTarget, reason domain.ReportReason, details string) (domain.Report, error)
+}
+
+// Form fields of a report.
+const (
+	reportReasonField  = "reason"
+	reportDetailsField = "details"
+)
+
+type ReportHandler struct {
+	reports ReportService
+}
+
+func NewReportHandler(reports ReportService) *ReportHandler {
+	return &ReportHandler{reports}
+}
+
+// RegisterEndpoints mounts the report route behind guard, which resolves the session.
+func (h *ReportHandler) RegisterEndpoints(mux *http.ServeMux, guard func(http.Handler) http.Handler) {
+	mux.Handle("POST /report", guard(http.HandlerFunc(h.CreateReport)))
+}
+
+func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	target, err := formReportTarget(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	actor, err := sessionActor(r)
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+
+	report, err := h.reports.Report(r.Context(), actor.UserID, target, domain.ReportReason(r.PostFormValue(reportReasonField)), r.PostFormValue(reportDetailsField))
+	if err != nil {
+		httperror.WriteError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, dto.ToReportResponse(report))
+}
+
+// formReportTarget reads the post_id or comment_id field of a form, the service rejects forms with both or neither.
+func formReportTarget(r *http.Request) (domain.ReportTarget, error) {
+	var target domain.ReportTarget
+	for field, id := range map[string]*int64{"post_id": &target.PostID, "comment_id": &target.CommentID} {
+		raw := r.PostFormValue(field)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return domain.ReportTarget{}, svcerr.NewError("invalid "+field, err, svcerr.ErrBadRequest)
+		}
+		*id = parsed
+	}
+	return target, nil
+}
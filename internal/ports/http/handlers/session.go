@@ -11,6 +11,7 @@ import (
 	"go-hex-forum/internal/core/domain"
 	"go-hex-forum/internal/core/service"
 	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/internal/ports/http/middleware"
 	"go-hex-forum/internal/utils"
 )
 
@@ -18,6 +19,34 @@ type SessionService interface {
 	StoreNewSession(context.Context) (sessionToken string, err error)
 	GetSessionByToken(context.Context, string) (*domain.Session, error)
 	UpdateUserName(ctx context.Context, token string, username string) error
+	RefreshSession(ctx context.Context, token string) (string, *domain.Session, error)
+	Logout(ctx context.Context, token string) error
+}
+
+const sessionCookie = "session_token"
+
+func setSessionCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	})
 }
 
 type SessionHandler struct {
@@ -30,8 +59,30 @@ func NewSessionHandler(tmpl *template.Template, sessionService SessionService, l
 	return SessionHandler{tmpl, sessionService, logger}
 }
 
-func (s *SessionHandler) RegisterEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("POST /username", s.UpdateUserName)
+// RegisterEndpoints mounts the session routes, renaming goes through requireSession, RequireSession or
+// whatever wraps it.
+func (s *SessionHandler) RegisterEndpoints(mux *http.ServeMux, requireSession func(http.Handler) http.Handler) {
+	mux.Handle("POST /username", requireSession(http.HandlerFunc(s.UpdateUserName)))
+	mux.HandleFunc("POST /logout", s.Logout)
+	mux.HandleFunc("GET /csrf", s.GetCSRFToken)
+}
+
+// GetCSRFToken returns the token API clients send back in the X-CSRF-Token header.
+func (s *SessionHandler) GetCSRFToken(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"csrf_token": middleware.CSRFToken(r.Context())})
+}
+
+// Logout revokes the current session and clears its cookie. Without a session there is nothing to revoke.
+func (s *SessionHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if token, ok := r.Context().Value("session_token").(string); ok && token != "" {
+		if err := s.SessionService.Logout(r.Context(), token); err != nil {
+			httperror.WriteError(w, err)
+			return
+		}
+	}
+
+	clearSessionCookie(w)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
 func (s *SessionHandler) UpdateUserName(w http.ResponseWriter, r *http.Request) {
@@ -55,64 +106,61 @@ func (s *SessionHandler) UpdateUserName(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func (s *SessionHandler) WithSessionToken(expirationInSec int64) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			tokenCookie, err := r.Cookie("session_token")
-			var token string
-			if err != nil || tokenCookie.Value == "" {
-				token, err = s.SessionService.StoreNewSession(r.Context())
-				if err != nil {
-					utils.WriteError(w, http.StatusInternalServerError, errors.New("failed to create session"))
-					return
-				}
-
-				http.SetCookie(w, &http.Cookie{
-					Name:     "session_token",
-					Value:    token,
-					Expires:  time.Now().Add(time.Duration(expirationInSec) * time.Second),
-					HttpOnly: true,
-					Secure:   true,
-					SameSite: http.SameSiteLaxMode,
-					Path:     "/",
-				})
+// WithSessionToken resolves the session of the request cookie, if there is one, and rotates its token when due.
+// Requests without a valid session are served anonymously, nothing is created for them.
+// An unknown, revoked or expired cookie is cleared.
+func (s *SessionHandler) WithSessionToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenCookie, err := r.Cookie(sessionCookie)
+		if err != nil || tokenCookie.Value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, session, err := s.SessionService.RefreshSession(r.Context(), tokenCookie.Value)
+		if err != nil {
+			if errors.Is(err, service.ErrSessionNotFound) || errors.Is(err, service.ErrSessionExpired) {
+				clearSessionCookie(w)
 			} else {
-				token = tokenCookie.Value
+				s.logger.Warn("failed to refresh session", "err", err)
 			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token != tokenCookie.Value {
+			setSessionCookie(w, token, session.ExpiresAt)
+		}
 
-			ctx := context.WithValue(r.Context(), "session_token", token)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
-	}
+		next.ServeHTTP(w, r.WithContext(withSession(r.Context(), token, session)))
+	})
 }
 
-func (s *SessionHandler) RequireValidSession(next http.Handler) http.Handler {
+// RequireSession guards actions that need an identity. A visitor without a session gets one here, on their
+// first write, instead of on every page view.
+func (s *SessionHandler) RequireSession(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_token")
-		token := cookie.Value
-		if err != nil || token == "" {
-			ctxSessionToken, ok := r.Context().Value("session_token").(string)
-			if !ok || ctxSessionToken == "" {
-				utils.WriteError(w, http.StatusUnauthorized, errors.New("unauthorized: no session token"))
-				return
-			}
-			token = ctxSessionToken
+		if _, ok := r.Context().Value("session").(*domain.Session); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := s.SessionService.StoreNewSession(r.Context())
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("failed to create session"))
+			return
 		}
 		session, err := s.SessionService.GetSessionByToken(r.Context(), token)
-		if err != nil || session == nil {
-			if errors.Is(err, service.ErrSessionExpired) {
-				http.SetCookie(w, &http.Cookie{
-					Name:   "session_token",
-					Value:  "",
-					MaxAge: -1,
-				})
-				utils.WriteError(w, http.StatusUnauthorized, errors.New("unauthorized: expired session"))
-				return
-			}
-			utils.WriteError(w, http.StatusUnauthorized, errors.New("unauthorized: invalid session"))
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("failed to create session"))
 			return
 		}
-		ctx := context.WithValue(r.Context(), "session", session)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		setSessionCookie(w, token, session.ExpiresAt)
+
+		next.ServeHTTP(w, r.WithContext(withSession(r.Context(), token, session)))
 	})
 }
+
+func withSession(ctx context.Context, token string, session *domain.Session) context.Context {
+	ctx = context.WithValue(ctx, "session_token", token)
+	return context.WithValue(ctx, "session", session)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/core/service"
+	"go-hex-forum/pkg/svcerr"
+)
+
+// fakeSessionService knows a set of valid tokens and rotates them on request.
+type fakeSessionService struct {
+	valid    map[string]bool
+	rotateTo string
+	created  int
+}
+
+func (f *fakeSessionService) StoreNewSession(context.Context) (string, error) {
+	f.created++
+	f.valid["fresh"] = true
+	return "fresh", nil
+}
+
+func (f *fakeSessionService) GetSessionByToken(ctx context.Context, token string) (*domain.Session, error) {
+	if !f.valid[token] {
+		return nil, svcerr.NewError("session not found", service.ErrSessionNotFound, svcerr.ErrBadRequest)
+	}
+	return &domain.Session{ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+func (f *fakeSessionService) UpdateUserName(context.Context, string, string) error {
+	return nil
+}
+
+func (f *fakeSessionService) RefreshSession(ctx context.Context, token string) (string, *domain.Session, error) {
+	session, err := f.GetSessionByToken(ctx, token)
+	if err != nil {
+		return "", nil, err
+	}
+	if f.rotateTo != "" {
+		delete(f.valid, token)
+		f.valid[f.rotateTo] = true
+		return f.rotateTo, session, nil
+	}
+	return token, session, nil
+}
+
+func (f *fakeSessionService) Logout(ctx context.Context, token string) error {
+	delete(f.valid, token)
+	return nil
+}
+
+// sessionChain serves "/" as a page that reports whether a session was resolved, and "POST /write" as an action.
+func sessionChain(h *SessionHandler) http.Handler {
+	mux := http.NewServeMux()
+	h.RegisterEndpoints(mux, h.RequireSession)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("session").(*domain.Session); ok {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.Handle("POST /write", h.RequireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := r.Context().Value("session_token").(string)
+		w.Write([]byte(token))
+	})))
+	return h.WithSessionToken(mux)
+}
+
+func newTestSessionHandler(sessions SessionService) *SessionHandler {
+	return &SessionHandler{SessionService: sessions, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func sessionRequest(method, path, token string) *http.Request {
+	r := httptest.NewRequest(method, path, nil)
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: token})
+	return r
+}
+
+func responseCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("expected a session cookie to be set")
+	}
+	return cookies[len(cookies)-1]
+}
+
+func TestLogout_revokedTokenIsRejected(t *testing.T) {
+	sessions := &fakeSessionService{valid: map[string]bool{"token": true}}
+	handler := sessionChain(newTestSessionHandler(sessions))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, sessionRequest(http.MethodPost, "/logout", "token"))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect after logout, got %d", rec.Code)
+	}
+	if c := responseCookie(t, rec); c.Value != "" || c.MaxAge >= 0 {
+		t.Fatalf("expected the cookie to be cleared, got %+v", c)
+	}
+
+	// pages are still served, but anonymously
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, sessionRequest(http.MethodGet, "/", "token"))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected a revoked token to resolve no session, got %d", rec.Code)
+	}
+	if c := responseCookie(t, rec); c.Value != "" {
+		t.Fatalf("expected the revoked cookie to be cleared, got %+v", c)
+	}
+
+	// a write gets a new identity, never the revoked one
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, sessionRequest(http.MethodPost, "/write", "token"))
+	if rec.Code != http.StatusOK || rec.Body.String() != "fresh" {
+		t.Fatalf("expected a new session for the write, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestWithSessionToken_anonymous(t *testing.T) {
+	sessions := &fakeSessionService{valid: map[string]bool{}}
+	handler := sessionChain(newTestSessionHandler(sessions))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected an anonymous page view, got %d", rec.Code)
+	}
+	if sessions.created != 0 || len(rec.Result().Cookies()) != 0 {
+		t.Fatalf("expected no session for a page view, created %d", sessions.created)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/write", nil))
+	if rec.Code != http.StatusOK || sessions.created != 1 {
+		t.Fatalf("expected the first write to create a session, got %d, created %d", rec.Code, sessions.created)
+	}
+	if c := responseCookie(t, rec); c.Value != "fresh" {
+		t.Fatalf("expected the new session cookie, got %+v", c)
+	}
+}
+
+func TestWithSessionToken_rotates(t *testing.T) {
+	sessions := &fakeSessionService{valid: map[string]bool{"old": true}, rotateTo: "new"}
+	handler := sessionChain(newTestSessionHandler(sessions))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, sessionRequest(http.MethodGet, "/", "old"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if c := responseCookie(t, rec); c.Value != "new" || c.Expires.IsZero() {
+		t.Fatalf("expected the rotated token in the cookie, got %+v", c)
+	}
+}
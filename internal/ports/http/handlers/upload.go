@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+// attachmentField matches the file fields of a form: "image", "image1", "image2" and so on.
+var attachmentField = regexp.MustCompile(`^image(\d*)$`)
+
+// formAttachments returns the image files of a parsed multipart form ordered by their field number,
+// "image" counting as zero. The alt text of "imageN" is read from "altN". Empty files are skipped.
+// The files are released with closeAttachments by the caller.
+func formAttachments(r *http.Request) ([]domain.AttachmentUpload, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+
+	type field struct {
+		name   string
+		suffix string
+		n      int
+	}
+	var fields []field
+	for name, headers := range r.MultipartForm.File {
+		m := attachmentField.FindStringSubmatch(name)
+		if m == nil || len(headers) == 0 || headers[0].Size == 0 {
+			continue
+		}
+		n := 0
+		if m[1] != "" {
+			var err error
+			if n, err = strconv.Atoi(m[1]); err != nil {
+				continue
+			}
+		}
+		fields = append(fields, field{name: name, suffix: m[1], n: n})
+	}
+	slices.SortFunc(fields, func(a, b field) int {
+		if a.n != b.n {
+			return a.n - b.n
+		}
+		return len(a.name) - len(b.name)
+	})
+
+	uploads := make([]domain.AttachmentUpload, 0, len(fields))
+	for _, f := range fields {
+		header := r.MultipartForm.File[f.name][0]
+		file, err := header.Open()
+		if err != nil {
+			closeAttachments(uploads)
+			return nil, err
+		}
+		uploads = append(uploads, domain.AttachmentUpload{
+			File:    file,
+			Size:    header.Size,
+			AltText: r.FormValue("alt" + f.suffix),
+		})
+	}
+
+	return uploads, nil
+}
+
+// closeAttachments closes the files opened by formAttachments.
+func closeAttachments(uploads []domain.AttachmentUpload) {
+	for _, upload := range uploads {
+		if c, ok := upload.File.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
+	"go-hex-forum/internal/core/domain"
 	"go-hex-forum/pkg/svcerr"
 )
 
@@ -19,17 +22,26 @@ func FromError(err error) APIError {
 		Message:    "internal server error",
 		StatusCode: http.StatusInternalServerError,
 	}
+	// a body over the limit of http.MaxBytesReader fails whichever form it was parsed as
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return APIError{"request body is too large", http.StatusRequestEntityTooLarge}
+	}
 	if errors.As(err, &svcErr) {
 		apiErr.Message = svcErr.Message
 		switch svcErr.AppErr {
 		case svcerr.ErrNotAuthorized:
 			apiErr.StatusCode = http.StatusUnauthorized
+		case svcerr.ErrForbidden:
+			apiErr.StatusCode = http.StatusForbidden
 		case svcerr.ErrBadRequest:
 			apiErr.StatusCode = http.StatusBadRequest
 		case svcerr.ErrNotFound:
 			apiErr.StatusCode = http.StatusNotFound
 		case svcerr.ErrConflict:
 			apiErr.StatusCode = http.StatusConflict
+		case svcerr.ErrTooManyRequests:
+			apiErr.StatusCode = http.StatusTooManyRequests
 		case svcerr.ErrInternal:
 			apiErr.StatusCode = http.StatusInternalServerError
 		default:
@@ -43,6 +55,10 @@ func FromError(err error) APIError {
 func WriteError(w http.ResponseWriter, err error) {
 	apiErr := FromError(err)
 
+	var limited *domain.RateLimitExceeded
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.Itoa(RetryAfterSeconds(limited.RetryAfter)))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(apiErr.StatusCode)
 
@@ -50,3 +66,8 @@ func WriteError(w http.ResponseWriter, err error) {
 		"error": apiErr.Message,
 	})
 }
+
+// RetryAfterSeconds rounds d up to whole seconds for the Retry-After header, which has no finer resolution.
+func RetryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/pkg/svcerr"
+)
+
+const (
+	// CSRFField is the form field state-changing forms carry the token in, API clients may send CSRFHeader instead.
+	CSRFField  = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+
+	csrfCookie = "csrf_token"
+)
+
+type csrfKey struct{}
+
+// CSRFToken returns the token forms rendered for the request have to carry.
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfKey{}).(string)
+	return token
+}
+
+// NewCSRFMW protects state-changing requests with synchronizer tokens. A visitor with a session has to send
+// the token stored with it. Before the first write there is no session yet, a random token is then kept in
+// a cookie and has to be echoed back. Requests carrying an Authorization header use no cookies and are
+// not checked. It has to run after the session middleware.
+func NewCSRFMW() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			expected := ""
+			if session, ok := r.Context().Value("session").(*domain.Session); ok && session.CSRFToken != "" {
+				expected = session.CSRFToken
+			} else if cookie, err := r.Cookie(csrfCookie); err == nil && cookie.Value != "" {
+				expected = cookie.Value
+			} else if isSafeMethod(r.Method) {
+				token, err := generateCSRFToken()
+				if err != nil {
+					httperror.WriteError(w, svcerr.NewError("failed to generate csrf token", err, svcerr.ErrInternal))
+					return
+				}
+				setCSRFCookie(w, token)
+				expected = token
+			}
+
+			if !isSafeMethod(r.Method) && r.Header.Get("Authorization") == "" {
+				provided, err := providedCSRFToken(r)
+				if r.MultipartForm != nil {
+					// the server only cleans up forms parsed on the original request, not on copies
+					defer r.MultipartForm.RemoveAll()
+				}
+				if err != nil {
+					httperror.WriteError(w, svcerr.NewError("invalid form data", err, svcerr.ErrBadRequest))
+					return
+				}
+				if expected == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+					httperror.WriteError(w, svcerr.NewError("invalid csrf token", errors.New("csrf token mismatch"), svcerr.ErrForbidden))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfKey{}, expected)))
+		})
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func providedCSRFToken(r *http.Request) (string, error) {
+	if token := r.Header.Get(CSRFHeader); token != "" {
+		return token, nil
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(MultipartMemory); err != nil {
+			return "", err
+		}
+	} else if err := r.ParseForm(); err != nil {
+		return "", err
+	}
+	return r.PostFormValue(CSRFField), nil
+}
+
+func setCSRFCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	})
+}
+
+func generateCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go-hex-forum/internal/core/domain"
+)
+
+// csrfHandler echoes the token pages would render.
+func csrfHandler() http.Handler {
+	return NewCSRFMW()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CSRFToken(r.Context())))
+	}))
+}
+
+func formRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/post", strings.NewReader(url.Values{CSRFField: {token}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func withSession(r *http.Request, csrfToken string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), "session", &domain.Session{CSRFToken: csrfToken}))
+}
+
+func TestCSRF_anonymous(t *testing.T) {
+	handler := csrfHandler()
+
+	// a page view hands out a token and keeps it in a cookie
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == "" || cookies[0].Value != rec.Body.String() {
+		t.Fatalf("expected the rendered token in a cookie, got %v and %q", cookies, rec.Body.String())
+	}
+	token := cookies[0].Value
+
+	rec = httptest.NewRecorder()
+	r := formRequest(token)
+	r.AddCookie(cookies[0])
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the echoed token to pass, got %d", rec.Code)
+	}
+
+	for name, r := range map[string]*http.Request{
+		"no cookie":   formRequest(token),
+		"wrong token": func() *http.Request { r := formRequest("forged"); r.AddCookie(cookies[0]); return r }(),
+		"no token":    func() *http.Request { r := formRequest(""); r.AddCookie(cookies[0]); return r }(),
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestCSRF_session(t *testing.T) {
+	handler := csrfHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, withSession(httptest.NewRequest(http.MethodGet, "/", nil), "session-token"))
+	if rec.Body.String() != "session-token" || len(rec.Result().Cookies()) != 0 {
+		t.Fatalf("expected the session token without a cookie, got %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, withSession(formRequest("session-token"), "session-token"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the session token to pass, got %d", rec.Code)
+	}
+
+	// once there is a session the cookie token no longer counts
+	rec = httptest.NewRecorder()
+	r := withSession(formRequest("cookie-token"), "session-token")
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "cookie-token"})
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+}
+
+func TestCSRF_multipartAndHeader(t *testing.T) {
+	handler := csrfHandler()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField(CSRFField, "session-token")
+	part, _ := mw.CreateFormFile("image1", "a.png")
+	part.Write([]byte("not really an image"))
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/post", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, withSession(r, "session-token"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a multipart form with the token to pass, got %d", rec.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/api/post", nil)
+	r.Header.Set(CSRFHeader, "session-token")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, withSession(r, "session-token"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the header token to pass, got %d", rec.Code)
+	}
+
+	// bearer authenticated requests carry no ambient credentials
+	r = httptest.NewRequest(http.MethodPost, "/api/admin/archive", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a bearer request to pass, got %d", rec.Code)
+	}
+}
+
+func TestCSRF_bodyLimit(t *testing.T) {
+	handler := NewBodyLimitMW(1 << 10)(csrfHandler())
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("image1", "a.png")
+	part.Write(bytes.Repeat([]byte("x"), 4<<10))
+	mw.WriteField(CSRFField, "session-token")
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/post", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, withSession(r, "session-token"))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a body over the limit, got %d", rec.Code)
+	}
+}
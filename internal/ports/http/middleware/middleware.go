@@ -2,12 +2,23 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
+
+	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/pkg/svcerr"
 )
 
 type Middleware func(next http.Handler) http.Handler
 
+// MultipartMemory is how much of a multipart form is kept in memory. Larger files are spooled to
+// temporary files by net/http and streamed from there, so concurrent uploads do not pile up in memory.
+// Middlewares and handlers parse forms with the same value, a form parsed once is reused by the rest.
+const MultipartMemory = 32 << 10
+
 func NewMiddlewareChain(xs ...Middleware) Middleware {
 	return func(next http.Handler) http.Handler {
 		for i := len(xs) - 1; i >= 0; i-- {
@@ -18,11 +29,25 @@ func NewMiddlewareChain(xs ...Middleware) Middleware {
 	}
 }
 
+// NewRouteMW runs the middleware of the route a request matches in front of next, requests matching none go
+// to next as they are. Routes are http.ServeMux patterns, so a chain can act on routes before the router
+// behind it is reached.
+func NewRouteMW(routes map[string]Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		mux := http.NewServeMux()
+		mux.Handle("/", next)
+		for pattern, mw := range routes {
+			mux.Handle(pattern, mw(next))
+		}
+		return mux
+	}
+}
+
 func NewTimeoutContextMW(timeoutInSec int) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
-				ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(timeoutInSec))
+				ctx, cancel := context.WithTimeout(r.Context(), time.Second*time.Duration(timeoutInSec))
 				defer cancel()
 
 				r = r.WithContext(ctx)
@@ -31,6 +56,19 @@ func NewTimeoutContextMW(timeoutInSec int) func(next http.Handler) http.Handler
 	}
 }
 
+// NewBodyLimitMW caps request bodies at limit bytes, reading past it fails with *http.MaxBytesError,
+// which is answered with 413. It goes in front of everything parsing forms, zero disables the cap.
+func NewBodyLimitMW(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limit > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func RecoveryMW(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -41,3 +79,18 @@ func RecoveryMW(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// NewAdminTokenMW only lets through requests carrying "Authorization: Bearer <token>".
+// With an empty token every request is rejected.
+func NewAdminTokenMW(token string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				httperror.WriteError(w, svcerr.NewError("not authorized", errors.New("invalid admin token"), svcerr.ErrNotAuthorized))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/internal/ports/http/httperror"
+	"go-hex-forum/pkg/svcerr"
+)
+
+type RateLimiter interface {
+	Allow(ctx context.Context, action domain.RateAction, cost int, subjects ...string) error
+}
+
+type clientIPKey struct{}
+
+// NewClientIPMW resolves the address of the client once for everything after it. Behind a trusted reverse
+// proxy it is the last entry of X-Forwarded-For, the one appended by the proxy itself, earlier entries are
+// supplied by the client.
+func NewClientIPMW(trustProxy bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := peerIP(r)
+			if trustProxy {
+				if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+					hops := strings.Split(forwarded[len(forwarded)-1], ",")
+					if hop := strings.TrimSpace(hops[len(hops)-1]); hop != "" {
+						ip = hop
+					}
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// ClientIP returns the address resolved by NewClientIPMW, or the peer address when it did not run.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return peerIP(r)
+}
+
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimitSubjects returns the buckets a request counts against: its address and, once there is a session, its user.
+func RateLimitSubjects(r *http.Request) []string {
+	subjects := []string{"ip:" + ClientIP(r)}
+	if session, ok := r.Context().Value("session").(*domain.Session); ok {
+		subjects = append(subjects, "user:"+strconv.FormatInt(session.User.ID, 10))
+	}
+	return subjects
+}
+
+// NewRateLimitMW counts every request against the limit of action. It has to run after the session is
+// resolved and, so that refused requests are not read, before anything parses the body. Requests over
+// the limit get 429 with a Retry-After header.
+func NewRateLimitMW(limiter RateLimiter, action domain.RateAction) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := limiter.Allow(r.Context(), action, 1, RateLimitSubjects(r)...); err != nil {
+				httperror.WriteError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NewSessionRateLimitMW limits how many sessions an address may create. Requests already carrying a session
+// are not counted, it goes in front of the middleware creating sessions.
+func NewSessionRateLimitMW(limiter RateLimiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value("session").(*domain.Session); !ok {
+				if err := limiter.Allow(r.Context(), domain.RateActionSession, 1, "ip:"+ClientIP(r)); err != nil {
+					httperror.WriteError(w, err)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NewUploadRateLimitMW counts every file of a multipart request against the upload limit. The files are
+// known from the part headers only once the body is read, it runs after the body is capped and reuses the
+// form parsed for the CSRF check.
+func NewUploadRateLimitMW(limiter RateLimiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.MultipartForm == nil && strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+				if err := r.ParseMultipartForm(MultipartMemory); err != nil {
+					httperror.WriteError(w, svcerr.NewError("invalid form data", err, svcerr.ErrBadRequest))
+					return
+				}
+				// the server only cleans up forms parsed on the original request, not on copies
+				defer r.MultipartForm.RemoveAll()
+			}
+
+			files := 0
+			if r.MultipartForm != nil {
+				for _, headers := range r.MultipartForm.File {
+					for _, header := range headers {
+						if header.Size > 0 {
+							files++
+						}
+					}
+				}
+			}
+
+			if err := limiter.Allow(r.Context(), domain.RateActionUpload, files, RateLimitSubjects(r)...); err != nil {
+				httperror.WriteError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
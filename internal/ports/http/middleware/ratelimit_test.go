@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-hex-forum/internal/core/domain"
+	"go-hex-forum/pkg/svcerr"
+)
+
+// fakeRateLimiter refuses subjects it has already seen.
+type fakeRateLimiter struct {
+	seen  map[string]bool
+	costs []int
+}
+
+func (f *fakeRateLimiter) Allow(ctx context.Context, action domain.RateAction, cost int, subjects ...string) error {
+	f.costs = append(f.costs, cost)
+	for _, subject := range subjects {
+		if f.seen[subject] {
+			return svcerr.NewError("too many requests, try again later",
+				&domain.RateLimitExceeded{Action: action, RetryAfter: 1500 * time.Millisecond}, svcerr.ErrTooManyRequests)
+		}
+	}
+	for _, subject := range subjects {
+		f.seen[subject] = true
+	}
+	return nil
+}
+
+func TestRateLimitMW(t *testing.T) {
+	limiter := &fakeRateLimiter{seen: map[string]bool{}}
+	handler := NewRateLimitMW(limiter, domain.RateActionPost)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/post", nil)
+		r.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := request("1.2.3.4:5678"); rec.Code != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", rec.Code)
+	}
+	rec := request("1.2.3.4:9999")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Fatalf("expected 429 with Retry-After 2, got %d and %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+// unreadBody fails the test when a request body is read.
+type unreadBody struct {
+	t *testing.T
+}
+
+func (b unreadBody) Read(p []byte) (int, error) {
+	b.t.Fatalf("expected the body not to be read")
+	return 0, io.EOF
+}
+
+func TestRouteMW_limitsBeforeBody(t *testing.T) {
+	limiter := &fakeRateLimiter{seen: map[string]bool{"ip:1.2.3.4": true}}
+	routed := 0
+	handler := NewMiddlewareChain(
+		NewRouteMW(map[string]Middleware{"POST /post/{id}/comment": NewRateLimitMW(limiter, domain.RateActionComment)}),
+		NewCSRFMW(),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { routed++ }))
+
+	r := httptest.NewRequest(http.MethodPost, "/post/7/comment", io.NopCloser(unreadBody{t}))
+	r.RemoteAddr = "1.2.3.4:5678"
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusTooManyRequests || routed != 0 {
+		t.Fatalf("expected 429 before the body is read, got %d", rec.Code)
+	}
+
+	// other routes are not counted
+	r = httptest.NewRequest(http.MethodGet, "/post/7", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if routed != 1 || len(limiter.costs) != 1 {
+		t.Fatalf("expected the page to pass uncounted, got %d routed and %d counted", routed, len(limiter.costs))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Add("X-Forwarded-For", "6.6.6.6, 1.2.3.4")
+
+	for trustProxy, want := range map[bool]string{false: "10.0.0.1", true: "1.2.3.4"} {
+		var ip string
+		NewClientIPMW(trustProxy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip = ClientIP(r)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		if ip != want {
+			t.Fatalf("trust proxy %v: expected %q, got %q", trustProxy, want, ip)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/webp"
+)
+
+const (
+	MIMEJPEG = "image/jpeg"
+	MIMEPNG  = "image/png"
+	MIMEGIF  = "image/gif"
+	MIMEWebP = "image/webp"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrCorrupted         = errors.New("corrupted image")
+	ErrTooManyFrames     = errors.New("too many animation frames")
+)
+
+// MaxGIFFrames is how many frames an animated GIF may have. Only the first frame is ever decoded,
+// the rest are walked through without decoding their pixels.
+const MaxGIFFrames = 1000
+
+// Source is image data of a known size that can be read more than once, such as *bytes.Reader
+// or an *io.SectionReader over a spooled upload. It lets large files be processed without loading them.
+type Source interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// SniffLen is how many leading bytes Sniff looks at.
+const SniffLen = 512
+
+type Info struct {
+	MIMEType string
+	Width    int
+	Height   int
+	// BytesPerPixel is the size of a pixel once decoded, which depends on the color model of the image.
+	BytesPerPixel int
+}
+
+// DecodeMemory is an upper bound of the memory taken to decode the image and process it: the decoded
+// pixels plus two RGBA working copies, the ones made by Orient and Downscale.
+func (i Info) DecodeMemory() int64 {
+	return int64(i.Width) * int64(i.Height) * int64(i.BytesPerPixel+2*4)
+}
+
+// bytesPerPixel tells how large a pixel of the color model is once decoded. Unknown models are assumed
+// to be the largest one, 16 bits per channel.
+func bytesPerPixel(model color.Model) int {
+	if _, ok := model.(color.Palette); ok {
+		return 1
+	}
+	switch model {
+	case color.GrayModel, color.AlphaModel:
+		return 1
+	case color.Gray16Model:
+		return 2
+	case color.YCbCrModel:
+		return 3
+	case color.RGBAModel, color.NRGBAModel, color.CMYKModel:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// Sniff detects the MIME type of data from its leading bytes, ignoring whatever the client claimed.
+func Sniff(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// sniffSource sniffs the first SniffLen bytes of src.
+func sniffSource(src Source) (string, error) {
+	header := make([]byte, min(src.Size(), SniffLen))
+	if _, err := src.ReadAt(header, 0); err != nil && err != io.EOF {
+		return "", err
+	}
+	return Sniff(header), nil
+}
+
+// reader returns a sequential reader over the whole of src.
+func reader(src Source) io.Reader {
+	return io.NewSectionReader(src, 0, src.Size())
+}
+
+// DecodeConfig reads the format and dimensions from the image header without decoding pixels.
+func DecodeConfig(src Source) (Info, error) {
+	mimeType, err := sniffSource(src)
+	if err != nil {
+		return Info{}, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	switch mimeType {
+	case MIMEJPEG, MIMEPNG, MIMEGIF:
+		cfg, _, err := image.DecodeConfig(reader(src))
+		if err != nil {
+			return Info{}, fmt.Errorf("%w: %v", ErrCorrupted, err)
+		}
+		return Info{MIMEType: mimeType, Width: cfg.Width, Height: cfg.Height, BytesPerPixel: bytesPerPixel(cfg.ColorModel)}, nil
+	case MIMEWebP:
+		width, height, err := webpSize(src)
+		if err != nil {
+			return Info{}, err
+		}
+		return Info{MIMEType: mimeType, Width: width, Height: height, BytesPerPixel: 4}, nil
+	default:
+		return Info{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, mimeType)
+	}
+}
+
+// Verify fully decodes src to make sure it is a well-formed image of the given MIME type.
+func Verify(src Source, mimeType string) error {
+	_, err := Decode(src, mimeType)
+	return err
+}
+
+// Decode decodes src of the given MIME type. Of a GIF only the first frame is decoded, the structure of the
+// others is checked and there may be at most MaxGIFFrames of them.
+func Decode(src Source, mimeType string) (image.Image, error) {
+	var (
+		img image.Image
+		err error
+	)
+	switch mimeType {
+	case MIMEJPEG:
+		img, err = jpeg.Decode(reader(src))
+	case MIMEPNG:
+		img, err = png.Decode(reader(src))
+	case MIMEGIF:
+		if err := checkGIFFrames(newBlockReader(src), MaxGIFFrames); err != nil {
+			return nil, err
+		}
+		img, err = gif.Decode(reader(src))
+	case MIMEWebP:
+		img, err = webp.Decode(reader(src))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, mimeType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	return img, nil
+}
+
+// webpSize parses the RIFF container and the first VP8/VP8L/VP8X chunk header.
+func webpSize(src Source) (int, int, error) {
+	data := make([]byte, 30)
+	if _, err := src.ReadAt(data, 0); err != nil || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, fmt.Errorf("%w: invalid webp header", ErrCorrupted)
+	}
+	riffSize := int64(binary.LittleEndian.Uint32(data[4:8]))
+	if riffSize+8 > src.Size() {
+		return 0, 0, fmt.Errorf("%w: truncated webp", ErrCorrupted)
+	}
+
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8X":
+		width := 1 + (int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16)
+		height := 1 + (int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16)
+		return width, height, nil
+	case "VP8 ":
+		if chunk[3] != 0x9d || chunk[4] != 0x01 || chunk[5] != 0x2a {
+			return 0, 0, fmt.Errorf("%w: invalid vp8 start code", ErrCorrupted)
+		}
+		width := int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3fff)
+		height := int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3fff)
+		return width, height, nil
+	case "VP8L":
+		if chunk[0] != 0x2f {
+			return 0, 0, fmt.Errorf("%w: invalid vp8l signature", ErrCorrupted)
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:5])
+		width := int(bits&0x3fff) + 1
+		height := int((bits>>14)&0x3fff) + 1
+		return width, height, nil
+	default:
+		return 0, 0, fmt.Errorf("%w: unknown webp chunk %q", ErrCorrupted, data[12:16])
+	}
+}
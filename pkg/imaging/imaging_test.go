@@ -0,0 +1,282 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+func encode(t *testing.T, format string, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case MIMEJPEG:
+		err = jpeg.Encode(&buf, img, nil)
+	case MIMEPNG:
+		err = png.Encode(&buf, img)
+	case MIMEGIF:
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encode %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+// bitWriter packs values least significant bit first, the way VP8L expects them.
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	nbit uint
+}
+
+func (b *bitWriter) write(v uint64, n uint) {
+	b.acc |= v << b.nbit
+	b.nbit += n
+	for b.nbit >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.nbit -= 8
+	}
+}
+
+func (b *bitWriter) bytes() []byte {
+	if b.nbit > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+	}
+	return b.buf
+}
+
+// webpSolid builds a lossless WebP of a single color. Every prefix code has one symbol, so the pixels
+// themselves take no bits at all.
+func webpSolid(w, h int, c color.NRGBA) []byte {
+	var bw bitWriter
+	bw.write(0x2f, 8)
+	bw.write(uint64(w-1), 14)
+	bw.write(uint64(h-1), 14)
+	bw.write(1, 1) // alpha is used
+	bw.write(0, 3) // version
+	bw.write(0, 1) // no transform
+	bw.write(0, 1) // no color cache
+	bw.write(0, 1) // no meta prefix codes
+	// green, red, blue, alpha and distance codes, each a simple code with one 8 bit symbol
+	for _, symbol := range []uint8{c.G, c.R, c.B, c.A, 0} {
+		bw.write(1, 1)
+		bw.write(0, 1)
+		bw.write(1, 1)
+		bw.write(uint64(symbol), 8)
+	}
+	vp8l := bw.bytes()
+	size := len(vp8l)
+	if size%2 == 1 {
+		vp8l = append(vp8l, 0)
+	}
+
+	data := []byte("RIFF\x00\x00\x00\x00WEBPVP8L\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint32(data[16:], uint32(size))
+	data = append(data, vp8l...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	return data
+}
+
+func TestDecodeConfig(t *testing.T) {
+	img := testImage(30, 20)
+	for _, format := range []string{MIMEJPEG, MIMEPNG, MIMEGIF} {
+		data := encode(t, format, img)
+		info, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", format, err)
+		}
+		if info.MIMEType != format || info.Width != 30 || info.Height != 20 {
+			t.Fatalf("%s: unexpected info %+v", format, info)
+		}
+		if err := Verify(bytes.NewReader(data), info.MIMEType); err != nil {
+			t.Fatalf("%s: expected valid image, got %v", format, err)
+		}
+	}
+}
+
+func TestDecodeConfig_WebP(t *testing.T) {
+	info, err := DecodeConfig(bytes.NewReader(webpSolid(640, 480, color.NRGBA{R: 200, A: 255})))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if info.MIMEType != MIMEWebP || info.Width != 640 || info.Height != 480 {
+		t.Fatalf("unexpected info %+v", info)
+	}
+
+	truncated := webpSolid(640, 480, color.NRGBA{R: 200, A: 255})
+	binary.LittleEndian.PutUint32(truncated[4:], 1000)
+	if _, err := DecodeConfig(bytes.NewReader(truncated)); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+func TestDecodeConfig_Fail_unsupported(t *testing.T) {
+	if _, err := DecodeConfig(strings.NewReader("just some text")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestVerify_Fail_truncated(t *testing.T) {
+	data := encode(t, MIMEPNG, testImage(30, 20))
+	truncated := data[:len(data)/2]
+
+	info, err := DecodeConfig(bytes.NewReader(truncated))
+	if err != nil {
+		t.Fatalf("expected header to be readable, got %v", err)
+	}
+	if err := Verify(bytes.NewReader(truncated), info.MIMEType); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+func TestDecode_WebP(t *testing.T) {
+	data := webpSolid(30, 20, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	img, err := Decode(bytes.NewReader(data), MIMEWebP)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 30, 20) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	if r, g, b, _ := img.At(5, 5).RGBA(); r>>8 != 10 || g>>8 != 20 || b>>8 != 30 {
+		t.Fatalf("unexpected pixel %v", img.At(5, 5))
+	}
+
+	if _, err := Decode(bytes.NewReader(data[:len(data)-4]), MIMEWebP); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+// animatedGIF encodes an animation of identical 4x4 frames.
+func animatedGIF(t *testing.T, frames int) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), palette))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode_GIFFrames(t *testing.T) {
+	img, err := Decode(bytes.NewReader(animatedGIF(t, 3)), MIMEGIF)
+	if err != nil || img.Bounds().Dx() != 4 {
+		t.Fatalf("expected the first frame, got %v and %v", img, err)
+	}
+
+	if _, err := Decode(bytes.NewReader(animatedGIF(t, MaxGIFFrames+1)), MIMEGIF); !errors.Is(err, ErrTooManyFrames) {
+		t.Fatalf("expected ErrTooManyFrames, got %v", err)
+	}
+
+	// a frame cut short is caught even though only the first one is decoded
+	data := animatedGIF(t, 3)
+	if _, err := Decode(bytes.NewReader(data[:len(data)-8]), MIMEGIF); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+func TestInfo_DecodeMemory(t *testing.T) {
+	for format, bpp := range map[string]int{MIMEJPEG: 3, MIMEPNG: 4, MIMEGIF: 1} {
+		info, err := DecodeConfig(bytes.NewReader(encode(t, format, testImage(30, 20))))
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", format, err)
+		}
+		if info.BytesPerPixel != bpp || info.DecodeMemory() != int64(30*20*(bpp+8)) {
+			t.Fatalf("%s: unexpected %d bytes per pixel, %d bytes in total", format, info.BytesPerPixel, info.DecodeMemory())
+		}
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		maxSide       int
+		wantW, wantH  int
+		wantOK        bool
+	}{
+		{"landscape", 800, 400, 240, 240, 120, true},
+		{"portrait", 300, 900, 240, 80, 240, true},
+		{"fits", 200, 100, 240, 200, 100, false},
+		{"disabled", 800, 400, 0, 800, 400, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, ok := Downscale(testImage(tt.width, tt.height), tt.maxSide)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok %v, got %v", tt.wantOK, ok)
+			}
+			if b := out.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+				t.Fatalf("expected %dx%d, got %dx%d", tt.wantW, tt.wantH, b.Dx(), b.Dy())
+			}
+		})
+	}
+}
+
+func TestDownscale_averagesColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			if x%2 == 0 {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+
+	out, _ := Downscale(img, 2)
+	got := color.RGBAModel.Convert(out.At(0, 0)).(color.RGBA)
+	if got != (color.RGBA{127, 0, 127, 255}) {
+		t.Fatalf("expected averaged color, got %v", got)
+	}
+}
+
+func TestEncode_keepsFormat(t *testing.T) {
+	small, _ := Downscale(testImage(64, 32), 16)
+	for _, format := range []string{MIMEJPEG, MIMEPNG, MIMEGIF} {
+		var buf bytes.Buffer
+		err := Encode(&buf, small, format)
+		data := buf.Bytes()
+		if err != nil {
+			t.Fatalf("encode %s: %v", format, err)
+		}
+		info, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decode %s: %v", format, err)
+		}
+		want := format
+		if format == MIMEGIF {
+			want = MIMEPNG
+		}
+		if info.MIMEType != want || info.Width != 16 || info.Height != 8 {
+			t.Fatalf("unexpected result for %s: %+v", format, info)
+		}
+	}
+}
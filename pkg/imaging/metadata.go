@@ -0,0 +1,512 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StripMetadata copies src to w without its EXIF, XMP, ICC, IPTC and comment blocks, the pixels are left untouched.
+// Only the blocks required to render the image are kept. src is streamed block by block, so memory use
+// does not grow with the size of the image.
+func StripMetadata(w io.Writer, src Source, mimeType string) error {
+	bw := bufio.NewWriter(w)
+	var err error
+	switch mimeType {
+	case MIMEJPEG:
+		err = stripJPEG(bw, newBlockReader(src))
+	case MIMEPNG:
+		err = stripPNG(bw, newBlockReader(src))
+	case MIMEGIF:
+		err = stripGIF(bw, newBlockReader(src))
+	case MIMEWebP:
+		err = stripWebP(bw, src)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, mimeType)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// blockReader reads a container sequentially. Writes go to a *bufio.Writer, whose first error is
+// reported by the final Flush.
+type blockReader struct {
+	r *bufio.Reader
+}
+
+func newBlockReader(src Source) *blockReader {
+	return &blockReader{bufio.NewReaderSize(reader(src), 32<<10)}
+}
+
+func (b *blockReader) read(p []byte, what string) error {
+	if _, err := io.ReadFull(b.r, p); err != nil {
+		return truncated(err, what)
+	}
+	return nil
+}
+
+func (b *blockReader) readByte(what string) (byte, error) {
+	c, err := b.r.ReadByte()
+	if err != nil {
+		return 0, truncated(err, what)
+	}
+	return c, nil
+}
+
+// copy copies the next n bytes to w.
+func (b *blockReader) copy(w *bufio.Writer, n int64, what string) error {
+	copied, err := w.ReadFrom(io.LimitReader(b.r, n))
+	if err != nil {
+		return err
+	}
+	if copied < n {
+		return fmt.Errorf("%w: truncated %s", ErrCorrupted, what)
+	}
+	return nil
+}
+
+// skip drops the next n bytes.
+func (b *blockReader) skip(n int64, what string) error {
+	if _, err := b.r.Discard(int(n)); err != nil {
+		return truncated(err, what)
+	}
+	return nil
+}
+
+func truncated(err error, what string) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated %s", ErrCorrupted, what)
+	}
+	return err
+}
+
+const (
+	jpegSOI  = 0xd8
+	jpegEOI  = 0xd9
+	jpegSOS  = 0xda
+	jpegAPP0 = 0xe0
+	jpegAPP1 = 0xe1
+	jpegAPPE = 0xee
+	jpegAPPF = 0xef
+	jpegCOM  = 0xfe
+)
+
+func stripJPEG(w *bufio.Writer, b *blockReader) error {
+	var soi [2]byte
+	if err := b.read(soi[:], "jpeg header"); err != nil || soi[0] != 0xff || soi[1] != jpegSOI {
+		return fmt.Errorf("%w: invalid jpeg header", ErrCorrupted)
+	}
+	w.Write(soi[:])
+
+	for {
+		c, err := b.r.ReadByte()
+		if err == io.EOF {
+			return fmt.Errorf("%w: missing jpeg end marker", ErrCorrupted)
+		}
+		if err != nil {
+			return err
+		}
+		if c != 0xff {
+			return fmt.Errorf("%w: expected jpeg marker", ErrCorrupted)
+		}
+		marker, err := b.readByte("jpeg marker")
+		if err != nil {
+			return err
+		}
+		switch {
+		case marker == 0xff: // fill byte
+			b.r.UnreadByte()
+			continue
+		case marker == jpegEOI:
+			w.Write([]byte{0xff, jpegEOI})
+			return nil
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7): // markers without a payload
+			w.Write([]byte{0xff, marker})
+			continue
+		}
+
+		header := [4]byte{0xff, marker}
+		if err := b.read(header[2:], "jpeg segment"); err != nil {
+			return err
+		}
+		n := int64(binary.BigEndian.Uint16(header[2:])) - 2
+		if n < 0 {
+			return fmt.Errorf("%w: invalid jpeg segment length", ErrCorrupted)
+		}
+
+		switch {
+		case marker == jpegAPP0:
+			err = b.copyJFIF(w, n)
+		case marker == jpegAPPE && b.hasPrefix(n, "Adobe"):
+			// the Adobe segment tells decoders how the color channels are encoded
+			w.Write(header[:])
+			err = b.copy(w, n, "jpeg segment")
+		case marker >= jpegAPP1 && marker <= jpegAPPF, marker == jpegCOM:
+			// EXIF, XMP, ICC, IPTC, vendor blocks and comments
+			err = b.skip(n, "jpeg segment")
+		case marker == jpegSOS:
+			// the scan header is followed by entropy-coded data that has no length prefix
+			w.Write(header[:])
+			if err = b.copy(w, n, "jpeg scan header"); err == nil {
+				err = b.copyScan(w)
+			}
+		default:
+			w.Write(header[:])
+			err = b.copy(w, n, "jpeg segment")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// hasPrefix reports whether the next n bytes start with prefix, without consuming them.
+func (b *blockReader) hasPrefix(n int64, prefix string) bool {
+	if n < int64(len(prefix)) {
+		return false
+	}
+	p, err := b.r.Peek(len(prefix))
+	return err == nil && string(p) == prefix
+}
+
+// copyJFIF keeps a JFIF header but drops its embedded thumbnail, which may show the uncropped original.
+// Extension (JFXX) and unknown APP0 segments are dropped entirely.
+func (b *blockReader) copyJFIF(w *bufio.Writer, n int64) error {
+	const headerLen = 14 // "JFIF\0", version, units, densities and thumbnail size
+	if n < headerLen || !b.hasPrefix(n, "JFIF\x00") {
+		return b.skip(n, "jpeg segment")
+	}
+	var payload [headerLen]byte
+	if err := b.read(payload[:], "jpeg segment"); err != nil {
+		return err
+	}
+	w.Write([]byte{0xff, jpegAPP0, 0, headerLen + 2})
+	w.Write(payload[:headerLen-2])
+	w.Write([]byte{0, 0})
+	return b.skip(n-headerLen, "jpeg segment")
+}
+
+// copyScan copies the entropy-coded data up to the marker that follows it, the marker is left unread.
+func (b *blockReader) copyScan(w *bufio.Writer) error {
+	for {
+		// a 0xff is only interpreted together with the byte after it
+		if _, err := b.r.Peek(2); err != nil {
+			return truncated(err, "jpeg scan")
+		}
+		p, _ := b.r.Peek(b.r.Buffered())
+
+		i := bytes.IndexByte(p, 0xff)
+		switch {
+		case i < 0:
+		case i > 0:
+			p = p[:i]
+		case p[1] == 0 || p[1] == 0xff || (p[1] >= 0xd0 && p[1] <= 0xd7):
+			// stuffed zero bytes and restart markers belong to the scan
+			p = p[:1]
+		default:
+			return nil
+		}
+		w.Write(p)
+		b.r.Discard(len(p))
+	}
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngKeep lists the chunks needed to render a PNG, animated ones included.
+// Text, eXIf, iCCP, tIME and private chunks are dropped.
+var pngKeep = map[string]bool{
+	"IHDR": true, "PLTE": true, "IDAT": true, "IEND": true,
+	"tRNS": true, "gAMA": true, "cHRM": true, "sRGB": true, "sBIT": true, "bKGD": true,
+	"acTL": true, "fcTL": true, "fdAT": true,
+}
+
+func stripPNG(w *bufio.Writer, b *blockReader) error {
+	signature := make([]byte, len(pngSignature))
+	if err := b.read(signature, "png signature"); err != nil || !bytes.Equal(signature, pngSignature) {
+		return fmt.Errorf("%w: invalid png signature", ErrCorrupted)
+	}
+	w.Write(signature)
+
+	var header [8]byte // length and type
+	for {
+		if err := b.read(header[:], "png chunk"); err != nil {
+			if errors.Is(err, ErrCorrupted) {
+				return fmt.Errorf("%w: missing png end chunk", ErrCorrupted)
+			}
+			return err
+		}
+		n := int64(binary.BigEndian.Uint32(header[:4])) + 4 // data and crc
+		chunkType := string(header[4:8])
+
+		var err error
+		if pngKeep[chunkType] {
+			w.Write(header[:])
+			err = b.copy(w, n, "png chunk")
+		} else {
+			err = b.skip(n, "png chunk")
+		}
+		if err != nil {
+			return err
+		}
+		if chunkType == "IEND" {
+			return nil
+		}
+	}
+}
+
+const (
+	gifExtension  = 0x21
+	gifImage      = 0x2c
+	gifTrailer    = 0x3b
+	gifPlainText  = 0x01
+	gifGraphicCtl = 0xf9
+	gifAppExt     = 0xff
+)
+
+func stripGIF(w *bufio.Writer, b *blockReader) error {
+	header := make([]byte, 13)
+	if err := b.read(header, "gif header"); err != nil || (string(header[:6]) != "GIF87a" && string(header[:6]) != "GIF89a") {
+		return fmt.Errorf("%w: invalid gif header", ErrCorrupted)
+	}
+
+	// header, logical screen descriptor and the optional global color table
+	w.Write(header)
+	if flags := header[10]; flags&0x80 != 0 {
+		if err := b.copy(w, 3<<((flags&0x07)+1), "gif color table"); err != nil {
+			return err
+		}
+	}
+
+	for {
+		block, err := b.r.ReadByte()
+		if err == io.EOF {
+			return fmt.Errorf("%w: missing gif trailer", ErrCorrupted)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch block {
+		case gifTrailer:
+			w.WriteByte(gifTrailer)
+			return nil
+		case gifExtension:
+			label, err := b.readByte("gif extension")
+			if err != nil {
+				return err
+			}
+			// an application extension is identified by its first sub-block
+			id, _ := b.r.Peek(12)
+			if keepGIFExtension(label, id) {
+				w.Write([]byte{gifExtension, label})
+				err = b.subBlocks(w)
+			} else {
+				err = b.subBlocks(nil)
+			}
+			if err != nil {
+				return err
+			}
+		case gifImage:
+			descriptor := make([]byte, 10)
+			descriptor[0] = gifImage
+			if err := b.read(descriptor[1:], "gif image descriptor"); err != nil {
+				return err
+			}
+			w.Write(descriptor)
+			if flags := descriptor[9]; flags&0x80 != 0 {
+				if err := b.copy(w, 3<<((flags&0x07)+1), "gif color table"); err != nil {
+					return err
+				}
+			}
+			if err := b.copy(w, 1, "gif image"); err != nil { // LZW minimum code size
+				return err
+			}
+			if err := b.subBlocks(w); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unexpected gif block 0x%02x", ErrCorrupted, block)
+		}
+	}
+}
+
+// checkGIFFrames walks the blocks of a GIF without decoding any pixels and fails when it has more than limit frames.
+func checkGIFFrames(b *blockReader, limit int) error {
+	header := make([]byte, 13)
+	if err := b.read(header, "gif header"); err != nil || (string(header[:6]) != "GIF87a" && string(header[:6]) != "GIF89a") {
+		return fmt.Errorf("%w: invalid gif header", ErrCorrupted)
+	}
+	if flags := header[10]; flags&0x80 != 0 {
+		if err := b.skip(3<<((flags&0x07)+1), "gif color table"); err != nil {
+			return err
+		}
+	}
+
+	frames := 0
+	for {
+		block, err := b.r.ReadByte()
+		if err == io.EOF {
+			return fmt.Errorf("%w: missing gif trailer", ErrCorrupted)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch block {
+		case gifTrailer:
+			if frames == 0 {
+				return fmt.Errorf("%w: gif without frames", ErrCorrupted)
+			}
+			return nil
+		case gifExtension:
+			if _, err := b.readByte("gif extension"); err != nil {
+				return err
+			}
+			if err := b.subBlocks(nil); err != nil {
+				return err
+			}
+		case gifImage:
+			if frames++; frames > limit {
+				return fmt.Errorf("%w: more than %d", ErrTooManyFrames, limit)
+			}
+			descriptor := make([]byte, 9)
+			if err := b.read(descriptor, "gif image descriptor"); err != nil {
+				return err
+			}
+			if flags := descriptor[8]; flags&0x80 != 0 {
+				if err := b.skip(3<<((flags&0x07)+1), "gif color table"); err != nil {
+					return err
+				}
+			}
+			if err := b.skip(1, "gif image"); err != nil { // LZW minimum code size
+				return err
+			}
+			if err := b.subBlocks(nil); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unexpected gif block 0x%02x", ErrCorrupted, block)
+		}
+	}
+}
+
+// keepGIFExtension keeps frame timing, plain text and the looping extensions.
+// Comments and application blocks such as XMP or ICC are dropped.
+func keepGIFExtension(label byte, blocks []byte) bool {
+	switch label {
+	case gifGraphicCtl, gifPlainText:
+		return true
+	case gifAppExt:
+		if len(blocks) < 12 || blocks[0] != 11 {
+			return false
+		}
+		id := string(blocks[1:12])
+		return id == "NETSCAPE2.0" || id == "ANIMEXTS1.0"
+	default:
+		return false
+	}
+}
+
+// subBlocks copies the sub-block sequence that follows to w, up to and including its terminator.
+// The sequence is skipped when w is nil.
+func (b *blockReader) subBlocks(w *bufio.Writer) error {
+	for {
+		size, err := b.readByte("gif data")
+		if err != nil {
+			return err
+		}
+		if w != nil {
+			w.WriteByte(size)
+			err = b.copy(w, int64(size), "gif data")
+		} else {
+			err = b.skip(int64(size), "gif data")
+		}
+		if err != nil || size == 0 {
+			return err
+		}
+	}
+}
+
+const (
+	webpFlagICC  = 0x20
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// webpKeep lists the chunks needed to render a WebP, EXIF, XMP and ICCP are dropped.
+var webpKeep = map[string]bool{
+	"VP8 ": true, "VP8L": true, "VP8X": true, "ALPH": true, "ANIM": true, "ANMF": true,
+}
+
+func stripWebP(w *bufio.Writer, src Source) error {
+	header := make([]byte, 12)
+	if _, err := src.ReadAt(header, 0); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return fmt.Errorf("%w: invalid webp header", ErrCorrupted)
+	}
+	riffEnd := 8 + int64(binary.LittleEndian.Uint32(header[4:8]))
+	if riffEnd > src.Size() {
+		return fmt.Errorf("%w: truncated webp", ErrCorrupted)
+	}
+
+	// the RIFF header starts with the size of what follows, so the kept chunks are measured first
+	size := int64(4)
+	err := walkWebP(src, riffEnd, func(fourCC string, off, end int64) error {
+		if webpKeep[fourCC] {
+			size += end - off
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(header[4:8], uint32(size))
+	w.Write(header)
+
+	return walkWebP(src, riffEnd, func(fourCC string, off, end int64) error {
+		if !webpKeep[fourCC] {
+			return nil
+		}
+		if fourCC == "VP8X" && end-off > 8 {
+			// the first VP8X byte flags which metadata chunks are present
+			head := make([]byte, 9)
+			if _, err := src.ReadAt(head, off); err != nil {
+				return truncated(err, "webp chunk")
+			}
+			head[8] &^= webpFlagICC | webpFlagEXIF | webpFlagXMP
+			w.Write(head)
+			off += 9
+		}
+		_, err := w.ReadFrom(io.NewSectionReader(src, off, end-off))
+		return err
+	})
+}
+
+// walkWebP calls fn with the bounds of every chunk of the RIFF container ending at riffEnd.
+func walkWebP(src Source, riffEnd int64, fn func(fourCC string, off, end int64) error) error {
+	header := make([]byte, 8)
+	off := int64(12)
+	for off+8 <= riffEnd {
+		if _, err := src.ReadAt(header, off); err != nil {
+			return truncated(err, "webp chunk")
+		}
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		end := off + 8 + size + size&1 // chunks are padded to an even size
+		if end > riffEnd {
+			return fmt.Errorf("%w: truncated webp chunk", ErrCorrupted)
+		}
+		if err := fn(string(header[:4]), off, end); err != nil {
+			return err
+		}
+		off = end
+	}
+	if off != riffEnd {
+		return fmt.Errorf("%w: truncated webp chunk", ErrCorrupted)
+	}
+	return nil
+}
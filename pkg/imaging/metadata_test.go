@@ -0,0 +1,343 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// The corpus below embeds these values the way cameras and editors do. None of them may survive stripping.
+var leaks = []string{
+	"Exif\x00\x00",
+	"SERIAL-0451",
+	"GPS-N-55.7558",
+	"http://ns.adobe.com/xap/1.0/",
+	"ICC_PROFILE",
+	"Photoshop 3.0",
+	"secret comment",
+	"THUMBNAIL-OF-UNCROPPED",
+}
+
+// exifTIFF builds a little-endian TIFF structure with an orientation, a camera serial and a GPS IFD.
+func exifTIFF(orientation uint16) []byte {
+	le := binary.LittleEndian
+	serial := "SERIAL-0451\x00"
+	latitude := "GPS-N-55.7558\x00"
+
+	const ifd0 = 8
+	serialOff := ifd0 + 2 + 3*12 + 4
+	gpsIFD := serialOff + len(serial)
+	latitudeOff := gpsIFD + 2 + 12 + 4
+
+	tiff := make([]byte, latitudeOff+len(latitude))
+	copy(tiff, "II*\x00")
+	le.PutUint32(tiff[4:], ifd0)
+
+	entry := func(at int, tag, typ uint16, count, value uint32) {
+		le.PutUint16(tiff[at:], tag)
+		le.PutUint16(tiff[at+2:], typ)
+		le.PutUint32(tiff[at+4:], count)
+		le.PutUint32(tiff[at+8:], value)
+	}
+	le.PutUint16(tiff[ifd0:], 3)
+	entry(ifd0+2, exifOrientationTag, 3, 1, uint32(orientation))
+	entry(ifd0+14, 0xa431, 2, uint32(len(serial)), uint32(serialOff)) // BodySerialNumber
+	entry(ifd0+26, 0x8825, 4, 1, uint32(gpsIFD))                      // GPSInfo
+	copy(tiff[serialOff:], serial)
+
+	le.PutUint16(tiff[gpsIFD:], 1)
+	entry(gpsIFD+2, 0x0002, 2, uint32(len(latitude)), uint32(latitudeOff)) // GPSLatitude
+	copy(tiff[latitudeOff:], latitude)
+
+	return tiff
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	seg := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// jpegWithMetadata inserts every common metadata segment right after SOI.
+func jpegWithMetadata(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	plain := encode(t, MIMEJPEG, img)
+
+	jfif := append([]byte("JFIF\x00\x01\x02\x00\x00\x48\x00\x48\x01\x01"), "THUMBNAIL-OF-UNCROPPED"...)
+	segments := [][]byte{
+		jpegSegment(jpegAPP0, jfif),
+		jpegSegment(jpegAPP1, append([]byte("Exif\x00\x00"), exifTIFF(orientation)...)),
+		jpegSegment(jpegAPP1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta><exif:GPSLatitude>GPS-N-55.7558</exif:GPSLatitude></x:xmpmeta>")),
+		jpegSegment(jpegAPP1+1, []byte("ICC_PROFILE\x00\x01\x01fake profile")),
+		jpegSegment(jpegAPP1+12, []byte("Photoshop 3.0\x008BIM iptc")),
+		jpegSegment(jpegAPPE, []byte("Adobe\x00\x64\x00\x00\x00\x00\x01")),
+		jpegSegment(jpegCOM, []byte("secret comment")),
+	}
+
+	out := append([]byte{}, plain[:2]...)
+	for _, seg := range segments {
+		out = append(out, seg...)
+	}
+	return append(out, plain[2:]...)
+}
+
+func pngChunk(chunkType string, payload []byte) []byte {
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// pngWithMetadata inserts metadata chunks right after IHDR.
+func pngWithMetadata(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	plain := encode(t, MIMEPNG, img)
+	afterIHDR := len(pngSignature) + 12 + 13
+
+	chunks := [][]byte{
+		pngChunk("eXIf", exifTIFF(1)),
+		pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00http://ns.adobe.com/xap/1.0/ GPS-N-55.7558")),
+		pngChunk("tEXt", []byte("Comment\x00secret comment")),
+		pngChunk("iCCP", []byte("ICC_PROFILE\x00\x00fake")),
+		pngChunk("tIME", []byte{0x07, 0xe9, 1, 2, 3, 4, 5}),
+		pngChunk("prVt", []byte("SERIAL-0451")),
+	}
+
+	out := append([]byte{}, plain[:afterIHDR]...)
+	for _, chunk := range chunks {
+		out = append(out, chunk...)
+	}
+	return append(out, plain[afterIHDR:]...)
+}
+
+func gifExtensionBlock(label byte, blocks ...[]byte) []byte {
+	out := []byte{gifExtension, label}
+	for _, block := range blocks {
+		out = append(out, byte(len(block)))
+		out = append(out, block...)
+	}
+	return append(out, 0)
+}
+
+// gifWithMetadata inserts a comment, an XMP block and a looping extension before the first frame.
+func gifWithMetadata(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	plain := encode(t, MIMEGIF, img)
+	first := 13
+	if flags := plain[10]; flags&0x80 != 0 {
+		first += 3 << ((flags & 0x07) + 1)
+	}
+
+	out := append([]byte{}, plain[:first]...)
+	out = append(out, gifExtensionBlock(gifAppExt, []byte("NETSCAPE2.0"), []byte{1, 0, 0})...)
+	out = append(out, gifExtensionBlock(0xfe, []byte("secret comment"))...)
+	out = append(out, gifExtensionBlock(gifAppExt, []byte("XMP DataXMP"), []byte("http://ns.adobe.com/xap/1.0/ GPS-N-55.7558"))...)
+	out = append(out, gifExtensionBlock(gifAppExt, []byte("ICCRGBG1012"), []byte("ICC_PROFILE"))...)
+	return append(out, plain[first:]...)
+}
+
+func webpChunk(fourCC string, payload []byte) []byte {
+	chunk := make([]byte, 8, 9+len(payload))
+	copy(chunk, fourCC)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// webpWithMetadata builds an extended WebP with ICC, EXIF and XMP chunks around a lossless frame.
+func webpWithMetadata(w, h int) []byte {
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagICC | webpFlagEXIF | webpFlagXMP
+	vp8x[4], vp8x[5], vp8x[6] = byte(w-1), byte((w-1)>>8), byte((w-1)>>16)
+	vp8x[7], vp8x[8], vp8x[9] = byte(h-1), byte((h-1)>>8), byte((h-1)>>16)
+
+	vp8l := make([]byte, 10)
+	vp8l[0] = 0x2f
+	binary.LittleEndian.PutUint32(vp8l[1:], uint32(w-1)|uint32(h-1)<<14)
+
+	body := []byte("WEBP")
+	body = append(body, webpChunk("VP8X", vp8x)...)
+	body = append(body, webpChunk("ICCP", []byte("ICC_PROFILE fake"))...)
+	body = append(body, webpChunk("VP8L", vp8l)...)
+	body = append(body, webpChunk("EXIF", append([]byte("Exif\x00\x00"), exifTIFF(6)...))...)
+	body = append(body, webpChunk("XMP ", []byte("http://ns.adobe.com/xap/1.0/ GPS-N-55.7558!"))...)
+
+	out := []byte("RIFF\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(body)))
+	return append(out, body...)
+}
+
+// stripMetadata strips data in memory.
+func stripMetadata(data []byte, mimeType string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := StripMetadata(&buf, bytes.NewReader(data), mimeType)
+	return buf.Bytes(), err
+}
+
+func assertNoLeaks(t *testing.T, data []byte) {
+	t.Helper()
+	for _, leak := range leaks {
+		if bytes.Contains(data, []byte(leak)) {
+			t.Fatalf("metadata %q survived stripping", leak)
+		}
+	}
+}
+
+func assertSamePixels(t *testing.T, want, got image.Image) {
+	t.Helper()
+	if want.Bounds() != got.Bounds() {
+		t.Fatalf("expected bounds %v, got %v", want.Bounds(), got.Bounds())
+	}
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.RGBAModel.Convert(want.At(x, y)) != color.RGBAModel.Convert(got.At(x, y)) {
+				t.Fatalf("pixel %d,%d changed", x, y)
+			}
+		}
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	img := testImage(30, 20)
+	corpus := map[string][]byte{
+		MIMEJPEG: jpegWithMetadata(t, img, 1),
+		MIMEPNG:  pngWithMetadata(t, img),
+		MIMEGIF:  gifWithMetadata(t, img),
+	}
+
+	for mimeType, dirty := range corpus {
+		t.Run(mimeType, func(t *testing.T) {
+			original, err := Decode(bytes.NewReader(dirty), mimeType)
+			if err != nil {
+				t.Fatalf("corpus image does not decode: %v", err)
+			}
+
+			clean, err := stripMetadata(dirty, mimeType)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			assertNoLeaks(t, clean)
+
+			stripped, err := Decode(bytes.NewReader(clean), mimeType)
+			if err != nil {
+				t.Fatalf("stripped image does not decode: %v", err)
+			}
+			assertSamePixels(t, original, stripped)
+		})
+	}
+}
+
+func TestStripMetadata_JPEGKeepsRenderingSegments(t *testing.T) {
+	clean, err := stripMetadata(jpegWithMetadata(t, testImage(8, 8), 1), MIMEJPEG)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !bytes.Contains(clean, []byte("JFIF\x00")) || !bytes.Contains(clean, []byte("Adobe")) {
+		t.Fatalf("expected JFIF and Adobe segments to be kept")
+	}
+}
+
+func TestStripMetadata_GIFKeepsLooping(t *testing.T) {
+	clean, err := stripMetadata(gifWithMetadata(t, testImage(8, 8)), MIMEGIF)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !bytes.Contains(clean, []byte("NETSCAPE2.0")) {
+		t.Fatalf("expected the looping extension to be kept")
+	}
+}
+
+func TestStripMetadata_WebP(t *testing.T) {
+	clean, err := stripMetadata(webpWithMetadata(640, 480), MIMEWebP)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	assertNoLeaks(t, clean)
+
+	for _, fourCC := range []string{"EXIF", "XMP ", "ICCP"} {
+		if bytes.Contains(clean, []byte(fourCC)) {
+			t.Fatalf("chunk %q survived stripping", fourCC)
+		}
+	}
+	if flags := clean[20]; flags&(webpFlagICC|webpFlagEXIF|webpFlagXMP) != 0 {
+		t.Fatalf("expected metadata flags to be cleared, got %08b", flags)
+	}
+	if size := binary.LittleEndian.Uint32(clean[4:8]); int(size) != len(clean)-8 {
+		t.Fatalf("expected RIFF size %d, got %d", len(clean)-8, size)
+	}
+
+	info, err := DecodeConfig(bytes.NewReader(clean))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if info.Width != 640 || info.Height != 480 {
+		t.Fatalf("unexpected dimensions %dx%d", info.Width, info.Height)
+	}
+}
+
+func TestStripMetadata_Fail_truncated(t *testing.T) {
+	corpus := map[string][]byte{
+		MIMEJPEG: jpegWithMetadata(t, testImage(8, 8), 1),
+		MIMEPNG:  pngWithMetadata(t, testImage(8, 8)),
+		MIMEGIF:  gifWithMetadata(t, testImage(8, 8)),
+		MIMEWebP: webpWithMetadata(8, 8),
+	}
+
+	for mimeType, data := range corpus {
+		if _, err := stripMetadata(data[:40], mimeType); err == nil {
+			t.Fatalf("%s: expected error, got none", mimeType)
+		}
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	for _, orientation := range []uint16{1, 3, 6, 8} {
+		data := jpegWithMetadata(t, testImage(8, 8), orientation)
+		if got := Orientation(bytes.NewReader(data)); got != int(orientation) {
+			t.Fatalf("expected orientation %d, got %d", orientation, got)
+		}
+	}
+	if got := Orientation(bytes.NewReader(encode(t, MIMEJPEG, testImage(8, 8)))); got != 1 {
+		t.Fatalf("expected default orientation, got %d", got)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// a 3x2 image whose top-left pixel is marked
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	marked := color.RGBA{255, 0, 0, 255}
+	img.Set(0, 0, marked)
+
+	tests := []struct {
+		orientation  int
+		wantW, wantH int
+		markX, markY int
+	}{
+		{1, 3, 2, 0, 0},
+		{2, 3, 2, 2, 0},
+		{3, 3, 2, 2, 1},
+		{4, 3, 2, 0, 1},
+		{5, 2, 3, 0, 0},
+		{6, 2, 3, 1, 0},
+		{7, 2, 3, 1, 2},
+		{8, 2, 3, 0, 2},
+	}
+
+	for _, tt := range tests {
+		out := Orient(img, tt.orientation)
+		if b := out.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+			t.Fatalf("orientation %d: expected %dx%d, got %dx%d", tt.orientation, tt.wantW, tt.wantH, b.Dx(), b.Dy())
+		}
+		if got := color.RGBAModel.Convert(out.At(tt.markX, tt.markY)); got != marked {
+			t.Fatalf("orientation %d: expected marked pixel at %d,%d", tt.orientation, tt.markX, tt.markY)
+		}
+	}
+}
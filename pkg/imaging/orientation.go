@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+const exifOrientationTag = 0x0112
+
+// Orientation reads the EXIF orientation (1-8) of a JPEG. It returns 1, the upright default,
+// when the tag is missing or unreadable. Only the segment headers and the EXIF block are read.
+func Orientation(src Source) int {
+	var header [4]byte
+	if _, err := src.ReadAt(header[:2], 0); err != nil || header[0] != 0xff || header[1] != jpegSOI {
+		return 1
+	}
+
+	for off := int64(2); ; {
+		if _, err := src.ReadAt(header[:], off); err != nil || header[0] != 0xff {
+			break
+		}
+		marker := header[1]
+		if marker == jpegSOS || marker == jpegEOI {
+			break
+		}
+		length := int64(binary.BigEndian.Uint16(header[2:4]))
+		if marker == jpegAPP1 {
+			payload := make([]byte, max(length-2, 0))
+			if _, err := src.ReadAt(payload, off+4); err != nil {
+				break
+			}
+			if bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				return tiffOrientation(payload[6:])
+			}
+		}
+		off += 2 + length
+	}
+
+	return 1
+}
+
+// tiffOrientation looks the orientation tag up in the first IFD of an EXIF TIFF structure.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) != exifOrientationTag {
+			continue
+		}
+		if o := int(order.Uint16(tiff[entry+8 : entry+10])); o >= 1 && o <= 8 {
+			return o
+		}
+		return 1
+	}
+
+	return 1
+}
+
+// Orient applies an EXIF orientation to img so that it displays upright without the tag.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+
+	return dst
+}
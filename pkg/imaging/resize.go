@@ -0,0 +1,96 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// JPEGQuality is used whenever an image has to be re-encoded as JPEG.
+const JPEGQuality = 85
+
+// Downscale shrinks img so that its longest side is at most maxSide, keeping the aspect ratio.
+// ok is false when img already fits and no copy was made.
+func Downscale(img image.Image, maxSide int) (out image.Image, ok bool) {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if maxSide <= 0 || (sw <= maxSide && sh <= maxSide) {
+		return img, false
+	}
+
+	dw, dh := maxSide, maxSide
+	if sw >= sh {
+		dh = max(1, sh*maxSide/sw)
+	} else {
+		dw = max(1, sw*maxSide/sh)
+	}
+
+	return boxResize(toRGBA(img), dw, dh), true
+}
+
+// toRGBA returns img as a premultiplied RGBA image whose bounds start at the origin.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// boxResize averages every source pixel covered by a destination pixel. Colors are premultiplied,
+// so transparent areas do not bleed into their neighbours.
+func boxResize(src *image.RGBA, dw, dh int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for dy := 0; dy < dh; dy++ {
+		y0 := dy * sh / dh
+		y1 := max((dy+1)*sh/dh, y0+1)
+		for dx := 0; dx < dw; dx++ {
+			x0 := dx * sw / dw
+			x1 := max((dx+1)*sw/dw, x0+1)
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride+x0*4 : y*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint64(row[i])
+					g += uint64(row[i+1])
+					b += uint64(row[i+2])
+					a += uint64(row[i+3])
+					n++
+				}
+			}
+
+			off := dy*dst.Stride + dx*4
+			dst.Pix[off] = uint8(r / n)
+			dst.Pix[off+1] = uint8(g / n)
+			dst.Pix[off+2] = uint8(b / n)
+			dst.Pix[off+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// Encode writes img to w as JPEG when mimeType is JPEG and as lossless PNG otherwise.
+func Encode(w io.Writer, img image.Image, mimeType string) error {
+	switch mimeType {
+	case MIMEJPEG:
+		if err := jpeg.Encode(w, img, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+			return fmt.Errorf("encode jpeg: %w", err)
+		}
+		return nil
+	default:
+		// GIF keeps transparency and avoids re-quantizing the palette as PNG
+		if err := png.Encode(w, img); err != nil {
+			return fmt.Errorf("encode png: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,66 @@
+// Package passhash hashes passwords with PBKDF2-SHA256 into self-describing strings of the form
+// pbkdf2-sha256$<iterations>$<salt>$<key>, salt and key in unpadded base64.
+package passhash
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	scheme = "pbkdf2-sha256"
+	// Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+	Iterations = 600_000
+	saltLength = 16
+	keyLength  = 32
+)
+
+var ErrMalformed = errors.New("malformed password hash")
+
+// Hash derives a new hash of password with a random salt.
+func Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, Iterations, keyLength)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", scheme, Iterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether password matches encoded. The iterations stored in encoded are used, so hashes
+// made before a change of Iterations keep working.
+func Verify(encoded, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != scheme {
+		return false, ErrMalformed
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false, ErrMalformed
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, ErrMalformed
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false, ErrMalformed
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(key, want) == 1, nil
+}
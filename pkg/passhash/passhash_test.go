@@ -0,0 +1,37 @@
+package passhash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	encoded, err := Hash("correct horse")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "pbkdf2-sha256$600000$") {
+		t.Fatalf("unexpected encoding %q", encoded)
+	}
+
+	if ok, err := Verify(encoded, "correct horse"); !ok || err != nil {
+		t.Fatalf("expected the password to match, got %v (%v)", ok, err)
+	}
+	if ok, _ := Verify(encoded, "battery staple"); ok {
+		t.Fatalf("expected another password not to match")
+	}
+
+	other, _ := Hash("correct horse")
+	if other == encoded {
+		t.Fatalf("expected every hash to get its own salt")
+	}
+}
+
+func TestVerify_malformed(t *testing.T) {
+	for _, encoded := range []string{"", "plain", "bcrypt$10$c2FsdA$a2V5", "pbkdf2-sha256$x$c2FsdA$a2V5", "pbkdf2-sha256$1$c2FsdA$"} {
+		if _, err := Verify(encoded, "password"); !errors.Is(err, ErrMalformed) {
+			t.Fatalf("%q: expected ErrMalformed, got %v", encoded, err)
+		}
+	}
+}
@@ -3,11 +3,13 @@ package svcerr
 type apiErr string
 
 const (
-	ErrNotAuthorized apiErr = "not authorized"
-	ErrBadRequest    apiErr = "bad request"
-	ErrNotFound      apiErr = "not found"
-	ErrConflict      apiErr = "conflict"
-	ErrInternal      apiErr = "internal error"
+	ErrNotAuthorized   apiErr = "not authorized"
+	ErrForbidden       apiErr = "forbidden"
+	ErrBadRequest      apiErr = "bad request"
+	ErrNotFound        apiErr = "not found"
+	ErrConflict        apiErr = "conflict"
+	ErrTooManyRequests apiErr = "too many requests"
+	ErrInternal        apiErr = "internal error"
 )
 
 type Error struct {
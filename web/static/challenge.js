@@ -0,0 +1,133 @@
+// Fetches the challenge of a form once the form is used and fills it into the form's .challenge slot.
+// A proof of work is solved before the form is submitted: a nonce such that SHA-256(seed + nonce) starts
+// with the required number of zero bits. A captcha is shown for the reader to type.
+(function () {
+    const encoder = new TextEncoder();
+    const challenges = new WeakMap();
+
+    function leadingZeroBits(bytes) {
+        let n = 0;
+        for (const b of bytes) {
+            if (b === 0) {
+                n += 8;
+                continue;
+            }
+            return n + Math.clz32(b) - 24;
+        }
+        return n;
+    }
+
+    async function solve(seed, difficulty) {
+        for (let nonce = 0; ; nonce++) {
+            const digest = await crypto.subtle.digest('SHA-256', encoder.encode(seed + nonce));
+            if (leadingZeroBits(new Uint8Array(digest)) >= difficulty) {
+                return String(nonce);
+            }
+        }
+    }
+
+    function input(type, name, value) {
+        const field = document.createElement('input');
+        field.type = type;
+        field.name = name;
+        field.value = value;
+        return field;
+    }
+
+    async function fetchChallenge(slot) {
+        const resp = await fetch('/api/challenge', {credentials: 'same-origin'});
+        if (resp.status === 204) {
+            return null;
+        }
+        if (!resp.ok) {
+            throw new Error('challenge unavailable: ' + resp.status);
+        }
+        const challenge = await resp.json();
+
+        slot.replaceChildren(input('hidden', 'challenge_id', challenge.id));
+        if (challenge.kind === 'captcha') {
+            const image = document.createElement('img');
+            image.src = challenge.image;
+            image.alt = 'Type the digits shown';
+            const answer = input('text', 'challenge_answer', '');
+            answer.inputMode = 'numeric';
+            answer.autocomplete = 'off';
+            answer.maxLength = challenge.difficulty;
+            answer.placeholder = 'Digits above';
+            answer.required = true;
+            slot.append(image, answer);
+        } else {
+            const answer = input('hidden', 'challenge_answer', '');
+            answer.className = 'pow-answer';
+            answer.dataset.seed = challenge.seed;
+            answer.dataset.difficulty = challenge.difficulty;
+            slot.append(answer);
+        }
+        return challenge;
+    }
+
+    // load fetches the challenge of form once, a failed fetch is tried again on the next use.
+    function load(form) {
+        const slot = form.querySelector('.challenge');
+        if (!slot) {
+            return Promise.resolve(null);
+        }
+        if (!challenges.has(form)) {
+            const challenge = fetchChallenge(slot).catch(function (err) {
+                challenges.delete(form);
+                slot.textContent = 'Could not load the challenge, try again.';
+                throw err;
+            });
+            challenges.set(form, challenge);
+        }
+        return challenges.get(form);
+    }
+
+    document.addEventListener('focusin', function (event) {
+        if (event.target.form) {
+            load(event.target.form).catch(function () {});
+        }
+    });
+
+    document.addEventListener('submit', async function (event) {
+        const form = event.target;
+        const slot = form.querySelector('.challenge');
+        if (!slot) {
+            return;
+        }
+        // a typed captcha or a solved proof of work goes out as it is
+        const answer = form.querySelector('.pow-answer');
+        if (slot.querySelector('input[name="challenge_id"]') && (!answer || answer.value !== '')) {
+            return;
+        }
+        event.preventDefault();
+
+        let challenge;
+        try {
+            challenge = await load(form);
+        } catch (err) {
+            return;
+        }
+        if (challenge && challenge.kind === 'captcha') {
+            // the reader has to type it first
+            form.querySelector('input[name="challenge_answer"]').focus();
+            return;
+        }
+
+        const submit = form.querySelector('input[type="submit"]');
+        const label = submit ? submit.value : '';
+        if (challenge) {
+            if (submit) {
+                submit.disabled = true;
+                submit.value = 'Working...';
+            }
+            const pow = form.querySelector('.pow-answer');
+            pow.value = await solve(pow.dataset.seed, Number(pow.dataset.difficulty));
+        }
+        if (submit) {
+            submit.disabled = false;
+            submit.value = label;
+        }
+        form.submit();
+    });
+})();